@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SeatHoldDuration is how long selected seats stay locked for a customer before payment.
+// Override with SEAT_HOLD_MINUTES.
+var SeatHoldDuration = positiveMinutesFromEnv("SEAT_HOLD_MINUTES", 10)
+
+// IdempotencyKeyTTL is how long a stored response is replayed for a repeated Idempotency-Key.
+// Override with IDEMPOTENCY_TTL_MINUTES.
+var IdempotencyKeyTTL = positiveMinutesFromEnv("IDEMPOTENCY_TTL_MINUTES", 24*60)
+
+// IdealRowPercent is where the best viewing row sits, as a percentage of the rows counted from the screen.
+// Override with IDEAL_ROW_PERCENT.
+var IdealRowPercent = intFromEnv("IDEAL_ROW_PERCENT", 66)
+
+// PaymentWindow is how long a PENDING booking may wait for payment before it expires.
+// Override with PAYMENT_WINDOW_MINUTES.
+var PaymentWindow = positiveMinutesFromEnv("PAYMENT_WINDOW_MINUTES", 15)
+
+// ExpirySweepInterval is how often the background worker looks for expired bookings and holds.
+// Override with EXPIRY_SWEEP_SECONDS.
+var ExpirySweepInterval = time.Duration(positiveIntFromEnv("EXPIRY_SWEEP_SECONDS", 30)) * time.Second
+
+// Default purchase limits; 0 means unlimited. Admins can override them per user for group sales.
+// Override with MAX_SEATS_PER_BOOKING, MAX_SEATS_PER_SCHEDULE and MAX_ACTIVE_BOOKINGS.
+var (
+	MaxSeatsPerBooking  = intFromEnv("MAX_SEATS_PER_BOOKING", 10)
+	MaxSeatsPerSchedule = intFromEnv("MAX_SEATS_PER_SCHEDULE", 10)
+	MaxActiveBookings   = intFromEnv("MAX_ACTIVE_BOOKINGS", 5)
+)
+
+// Guest checkouts allowed per hour from one client address, and with one email or phone.
+// Override with GUEST_BOOKINGS_PER_IP_HOUR and GUEST_BOOKINGS_PER_CONTACT_HOUR.
+var (
+	GuestBookingsPerIP      = positiveIntFromEnv("GUEST_BOOKINGS_PER_IP_HOUR", 20)
+	GuestBookingsPerContact = positiveIntFromEnv("GUEST_BOOKINGS_PER_CONTACT_HOUR", 5)
+)
+
+// Default charges added to ticket prices, for theaters without their own rates. VATRate is in
+// basis points (2000 is 20%) and BookingFee is per ticket in minor currency units.
+// Override with VAT_RATE_BASIS_POINTS and BOOKING_FEE_MINOR.
+var (
+	VATRate    = intFromEnv("VAT_RATE_BASIS_POINTS", 0)
+	BookingFee = intFromEnv("BOOKING_FEE_MINOR", 0)
+)
+
+// Dynamic pricing moves a schedule's fare from its floor to its ceiling as demand grows. Demand mixes
+// sell-through, weighted DynamicOccupancyWeight percent, with how close the show is, which counts
+// over the last DynamicPricingWindow before showtime.
+// Override with DYNAMIC_OCCUPANCY_WEIGHT and DYNAMIC_PRICING_WINDOW_HOURS.
+var (
+	DynamicOccupancyWeight = intFromEnv("DYNAMIC_OCCUPANCY_WEIGHT", 70)
+	DynamicPricingWindow   = time.Duration(intFromEnv("DYNAMIC_PRICING_WINDOW_HOURS", 72)) * time.Hour
+)
+
+// Loyalty programme. Paid bookings earn LoyaltyPointsPerUnit points per currency unit unless an earn
+// rate is set for the movie or theater. At checkout a point takes LoyaltyPointValue minor units off,
+// and LoyaltyFreeTicketPoints buy one free ticket.
+// Override with LOYALTY_POINTS_PER_UNIT, LOYALTY_POINT_VALUE_MINOR and LOYALTY_FREE_TICKET_POINTS.
+var (
+	LoyaltyPointsPerUnit    = intFromEnv("LOYALTY_POINTS_PER_UNIT", 1)
+	LoyaltyPointValue       = intFromEnv("LOYALTY_POINT_VALUE_MINOR", 1)
+	LoyaltyFreeTicketPoints = intFromEnv("LOYALTY_FREE_TICKET_POINTS", 1000)
+)
+
+// MembershipRenewalInterval is how often the background worker renews memberships whose month has run out.
+// Override with MEMBERSHIP_RENEWAL_SWEEP_MINUTES.
+var MembershipRenewalInterval = positiveMinutesFromEnv("MEMBERSHIP_RENEWAL_SWEEP_MINUTES", 15)
+
+// PaymentProvider names the card payment provider. Card payments and membership charges are refused
+// while it is unset; "fake" approves everything without taking money, for local development only.
+// Set with PAYMENT_PROVIDER.
+var PaymentProvider = os.Getenv("PAYMENT_PROVIDER")
+
+// PaymentWebhookSecret signs the payment provider's callbacks. Callbacks are refused while it is unset.
+// Set with PAYMENT_WEBHOOK_SECRET.
+var PaymentWebhookSecret = os.Getenv("PAYMENT_WEBHOOK_SECRET")
+
+// minutesFromEnv reads a whole number of minutes from the environment, falling back to a default
+func minutesFromEnv(key string, fallback int) time.Duration {
+	return time.Duration(intFromEnv(key, fallback)) * time.Minute
+}
+
+// positiveMinutesFromEnv is minutesFromEnv for durations that cannot be zero, such as intervals
+func positiveMinutesFromEnv(key string, fallback int) time.Duration {
+	return time.Duration(positiveIntFromEnv(key, fallback)) * time.Minute
+}
+
+// positiveIntFromEnv reads an integer above zero from the environment, falling back to a default
+func positiveIntFromEnv(key string, fallback int) int {
+	value := intFromEnv(key, fallback)
+	if value == 0 {
+		log.Printf("Invalid value %q for %s, using default %d", os.Getenv(key), key, fallback)
+		return fallback
+	}
+	return value
+}
+
+// intFromEnv reads an integer from the environment, falling back to a default
+func intFromEnv(key string, fallback int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		log.Printf("Invalid value %q for %s, using default %d", raw, key, fallback)
+		return fallback
+	}
+	return value
+}
+
+// CancellationCutoff is how long before showtime customers can still cancel their own booking.
+// Override with CANCELLATION_CUTOFF_MINUTES.
+var CancellationCutoff = minutesFromEnv("CANCELLATION_CUTOFF_MINUTES", 120)
+
+// RefundTier refunds Percent of the amount paid when cancelling at least Before ahead of showtime
+type RefundTier struct {
+	Before  time.Duration
+	Percent int
+}
+
+// RefundTiers are checked in order, longest notice first.
+// Override with REFUND_TIERS as "hours:percent" pairs, e.g. "24:100,2:50".
+var RefundTiers = refundTiersFromEnv("REFUND_TIERS", "24:100,2:50")
+
+func refundTiersFromEnv(key, fallback string) []RefundTier {
+	raw := os.Getenv(key)
+	if raw == "" {
+		raw = fallback
+	}
+	tiers, err := parseRefundTiers(raw)
+	if err != nil {
+		log.Printf("Invalid value %q for %s (%v), using default %q", raw, key, err, fallback)
+		tiers, _ = parseRefundTiers(fallback)
+	}
+	return tiers
+}
+
+func parseRefundTiers(raw string) ([]RefundTier, error) {
+	var tiers []RefundTier
+	for _, part := range strings.Split(raw, ",") {
+		hours, percent, found := strings.Cut(strings.TrimSpace(part), ":")
+		if !found {
+			return nil, fmt.Errorf("tier %q is not hours:percent", part)
+		}
+		h, err := strconv.ParseFloat(hours, 64)
+		if err != nil {
+			return nil, err
+		}
+		p, err := strconv.Atoi(percent)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid refund percent %q", percent)
+		}
+		tiers = append(tiers, RefundTier{Before: time.Duration(h * float64(time.Hour)), Percent: p})
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Before > tiers[j].Before })
+	return tiers, nil
+}
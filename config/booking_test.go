@@ -0,0 +1,17 @@
+package config
+
+import "testing"
+
+func TestPositiveIntFromEnvRejectsZeroAndNegatives(t *testing.T) {
+	for _, raw := range []string{"0", "-5", "soon"} {
+		t.Setenv("TEST_SWEEP_SECONDS", raw)
+		if got := positiveIntFromEnv("TEST_SWEEP_SECONDS", 30); got != 30 {
+			t.Errorf("%q: got %d, want the default 30", raw, got)
+		}
+	}
+
+	t.Setenv("TEST_SWEEP_SECONDS", "5")
+	if got := positiveIntFromEnv("TEST_SWEEP_SECONDS", 30); got != 5 {
+		t.Errorf("got %d, want 5", got)
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,16 +17,34 @@ import (
 const MAX_LOGIN_ATTEMPTS = 5
 
 func Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var request struct {
+		models.User
+		BookingAccessToken string `json:"booking_access_token"` // Keeps the bookings of the guest checkout it was issued for
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
+	user := request.User
 
 	// Set default role to "user"
 	user.Role = "user"
 
-	err := models.RegisterUser(user)
+	guestID := 0
+	if request.BookingAccessToken != "" {
+		claims, err := utils.ValidateBookingAccessToken(request.BookingAccessToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid booking access token"})
+			return
+		}
+		guestID = int(claims.UserID)
+	}
+
+	err := models.RegisterUser(user, guestID)
+	if errors.Is(err, models.ErrGuestNotClaimed) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -153,7 +172,23 @@ func UserProfile(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "Welcome!"})
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	loyalty, err := models.GetLoyaltyAccount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Welcome!", "user": user, "loyalty": loyalty})
 }
 
 // AdminDashboard - accessible only by admins
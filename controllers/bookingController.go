@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"my-app/models"
 	"net/http"
 	"strconv"
@@ -8,30 +9,38 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// BookTickets books seats
-func BookTickets(c *gin.Context) {
+// getUserID reads the authenticated user's ID set by JWTAuthMiddleware
+func getUserID(c *gin.Context) (int, bool) {
 	userIDInterface, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
+		return 0, false
 	}
 
-	var userID int
 	switch v := userIDInterface.(type) {
 	case int:
-		userID = v
+		return v, true
 	case uint:
-		userID = int(v)
+		return int(v), true
 	case float64:
-		userID = int(v)
+		return int(v), true
 	default:
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID type"})
+		return 0, false
+	}
+}
+
+// BookTickets holds the selected seats for the user until payment or expiry
+func BookTickets(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
 	var request struct {
 		ScheduleID int   `json:"schedule_id"`
 		Seats      []int `json:"seats"`
+		PartySize  int   `json:"party_size"` // Let the server pick the best seats when no seats are given
+		models.CheckoutOptions
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -39,13 +48,76 @@ func BookTickets(c *gin.Context) {
 		return
 	}
 
-	bookingID, err := models.BookSeats(userID, request.ScheduleID, request.Seats)
+	hold, err := holdRequestedSeats(userID, request.ScheduleID, request.Seats, request.PartySize, request.CheckoutOptions)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Seats held, complete payment before the hold expires", "hold_id": hold.HoldID, "expires_at": hold.ExpiresAt, "seats": hold.SeatIDs, "warnings": hold.Warnings, "quote": hold.Quote})
+}
+
+// holdRequestedSeats holds the chosen seats, or lets the server pick partySize seats when none are chosen
+func holdRequestedSeats(userID, scheduleID int, seats []int, partySize int, options models.CheckoutOptions) (*models.SeatHold, error) {
+	if len(seats) == 0 && partySize > 0 {
+		return models.HoldBestSeats(userID, scheduleID, partySize, options)
+	}
+	return models.HoldSeats(userID, scheduleID, seats, options)
+}
+
+// GetHold returns one of the user's active seat holds
+func GetHold(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	hold, err := models.GetUserHold(userID, c.Param("hold_id"))
+	if err != nil {
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hold": hold})
+}
+
+// ReleaseHold gives held seats back before the hold expires
+func ReleaseHold(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "Booking successful", "booking_id": bookingID})
+	if err := models.ReleaseHold(userID, c.Param("hold_id")); err != nil {
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Hold released"})
+}
+
+// holdErrorStatus maps hold and booking errors to HTTP status codes
+func holdErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrSeatsUnavailable), errors.Is(err, models.ErrNoContiguousSeats):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrHoldNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrHoldNotOwned):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrSeatGap), errors.Is(err, models.ErrPurchaseLimit), errors.Is(err, models.ErrPromoNotApplicable):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, models.ErrPromoNotFound), errors.Is(err, models.ErrConcessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrPromoExhausted), errors.Is(err, models.ErrConcessionUnavailable):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrInsufficientPoints):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusBadRequest
+	}
 }
 
 // GetBookingsByUserID retrieves all bookings for a given user
@@ -77,8 +149,20 @@ func GetAllBookings(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "Success", "bookings": bookings})
 }
 
-// DeleteBooking deletes a booking by ID
-func DeleteBooking(c *gin.Context) {
+// getActor builds the status-change actor from the authenticated user
+func getActor(c *gin.Context) (models.Actor, bool) {
+	userID, ok := getUserID(c)
+	if !ok {
+		return models.Actor{}, false
+	}
+	return models.Actor{UserID: userID, Role: c.GetString("role")}, true
+}
+
+// CancelBooking lets a customer cancel their own booking up to the cutoff before showtime.
+// The seats are freed and a refund is recorded according to the refund tiers. It also serves
+// DELETE /user/bookings/:booking_id, which used to delete the row: the booking is now kept,
+// CANCELLED or REFUNDED, so that its payments and history stay traceable.
+func CancelBooking(c *gin.Context) {
 	bookingIDParam := c.Param("booking_id")
 	bookingID, err := strconv.ParseInt(bookingIDParam, 10, 64)
 	if err != nil {
@@ -86,11 +170,130 @@ func DeleteBooking(c *gin.Context) {
 		return
 	}
 
-	err = models.DeleteBooking(bookingID)
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	refund, err := models.CancelBookingWithRefund(bookingID, actor)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Booking cancelled successfully", "refund": refund})
+}
+
+// UpdateBookingStatus lets an admin move a booking through its lifecycle (check-in, refund, expire...)
+func UpdateBookingStatus(c *gin.Context) {
+	bookingID, err := strconv.ParseInt(c.Param("booking_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	var request struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := models.TransitionBooking(bookingID, request.Status, actor); err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Booking status updated", "booking_status": request.Status})
+}
+
+// GetBookingHistory returns the status history of a booking. Users may only see their own bookings.
+func GetBookingHistory(c *gin.Context) {
+	bookingID, err := strconv.ParseInt(c.Param("booking_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	booking, err := models.GetBookingByID(int(bookingID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if booking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": models.ErrBookingNotFound.Error()})
+		return
+	}
+	if actor.Role != "admin" && booking.UserID != actor.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": models.ErrBookingNotOwned.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "Booking deleted successfully"})
+	history, err := models.GetBookingStatusHistory(bookingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"booking_id": bookingID, "status": booking.Status, "history": history})
+}
+
+// GetBookingReceipt returns the itemized receipt of a booking to its owner or an admin
+func GetBookingReceipt(c *gin.Context) {
+	bookingID, err := strconv.ParseInt(c.Param("booking_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	booking, err := models.GetBookingDetails(bookingID)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if actor.Role != "admin" && booking.UserID != actor.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": models.ErrBookingNotOwned.Error()})
+		return
+	}
+
+	receipt, err := models.GetReceipt(bookingID)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"receipt": receipt})
+}
+
+// bookingErrorStatus maps booking lifecycle errors to HTTP status codes
+func bookingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrBookingNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrBookingNotOwned):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, models.ErrCancellationClosed), errors.Is(err, models.ErrBookingUnscheduled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
 }
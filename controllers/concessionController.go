@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"errors"
+	"my-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetConcessionMenu lists the items and combos on sale at a theater
+func GetConcessionMenu(c *gin.Context) {
+	theaterID, err := strconv.Atoi(c.Param("theaterID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid theater ID"})
+		return
+	}
+
+	items, err := models.GetConcessionItems(theaterID, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// GetConcessionCatalogue lists every item of a theater, including those taken off sale
+func GetConcessionCatalogue(c *gin.Context) {
+	theaterID, err := strconv.Atoi(c.Param("theaterID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid theater ID"})
+		return
+	}
+
+	items, err := models.GetConcessionItems(theaterID, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// GetConcessionItem returns one item with its combo components
+func GetConcessionItem(c *gin.Context) {
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	item, err := models.GetConcessionItem(itemID)
+	if err != nil {
+		c.JSON(concessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// CreateConcessionItem adds an item or combo to a theater's catalogue
+func CreateConcessionItem(c *gin.Context) {
+	var item models.ConcessionItem
+	if err := c.ShouldBindJSON(&item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := models.CreateConcessionItem(&item); err != nil {
+		c.JSON(concessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Concession item created successfully", "item": item})
+}
+
+// UpdateConcessionItem changes an item's details, price, stock or combo components
+func UpdateConcessionItem(c *gin.Context) {
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var item models.ConcessionItem
+	if err := c.ShouldBindJSON(&item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	item.ItemID = itemID
+
+	if err := models.UpdateConcessionItem(&item); err != nil {
+		c.JSON(concessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Concession item updated successfully"})
+}
+
+// DeactivateConcessionItem takes an item off sale
+func DeactivateConcessionItem(c *gin.Context) {
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	if err := models.DeactivateConcessionItem(itemID); err != nil {
+		c.JSON(concessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Concession item deactivated"})
+}
+
+// RedeemPickupCode hands a paid concession order over at the counter
+func RedeemPickupCode(c *gin.Context) {
+	var req struct {
+		PickupCode string `json:"pickup_code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	order, err := models.RedeemPickupCode(req.PickupCode, actor)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, models.ErrPickupNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, models.ErrPickupAlreadyRedeemed), errors.Is(err, models.ErrPickupNotValid):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "order": order})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+func concessionErrorStatus(err error) int {
+	if errors.Is(err, models.ErrConcessionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"errors"
+	"my-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetGiftCards lists every gift card with its balance
+func GetGiftCards(c *gin.Context) {
+	cards, err := models.GetGiftCards()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"gift_cards": cards})
+}
+
+// GetGiftCard returns one gift card with every debit and credit made to it
+func GetGiftCard(c *gin.Context) {
+	giftCardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid gift card ID"})
+		return
+	}
+
+	card, err := models.GetGiftCard(giftCardID)
+	if err != nil {
+		c.JSON(giftCardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"gift_card": card})
+}
+
+// IssueGiftCard creates a gift card with its opening balance
+func IssueGiftCard(c *gin.Context) {
+	var card models.GiftCard
+	if err := c.ShouldBindJSON(&card); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := models.IssueGiftCard(&card, actor); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Gift card issued", "gift_card": card})
+}
+
+// VoidGiftCard cancels a gift card and writes off its remaining balance
+func VoidGiftCard(c *gin.Context) {
+	giftCardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid gift card ID"})
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	_ = c.ShouldBindJSON(&req)
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := models.VoidGiftCard(giftCardID, actor, req.Note); err != nil {
+		c.JSON(giftCardErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Gift card voided"})
+}
+
+// GetMyWallet shows the signed-in user's wallet balance and ledger
+func GetMyWallet(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	wallet, err := models.GetWallet(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"wallet": wallet})
+}
+
+// GetUserWallet shows a user's wallet balance and ledger to an admin
+func GetUserWallet(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	wallet, err := models.GetWallet(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"wallet": wallet})
+}
+
+// CreditUserWallet adds to a user's wallet balance
+func CreditUserWallet(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var req struct {
+		Amount models.Money `json:"amount" binding:"required"`
+		Note   string       `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	balance, err := models.CreditWallet(userID, req.Amount, actor, req.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Wallet credited", "balance": balance})
+}
+
+func giftCardErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrGiftCardNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrGiftCardNotUsable):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// paymentErrorStatus maps payment failures, falling back to the hold statuses when paying for a hold
+func paymentErrorStatus(err error, payment models.Payment) int {
+	switch {
+	case errors.Is(err, models.ErrGiftCardNotFound), errors.Is(err, models.ErrPaymentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrGiftCardNotUsable), errors.Is(err, models.ErrInsufficientBalance), errors.Is(err, models.ErrPaymentMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, models.ErrPaymentInProgress), errors.Is(err, models.ErrPaymentSettled), errors.Is(err, models.ErrBookingNotPayable):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrPaymentDeclined):
+		return http.StatusPaymentRequired
+	case errors.Is(err, models.ErrNoPaymentProvider):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, models.ErrInvalidPaymentStatus):
+		return http.StatusBadRequest
+	case payment.HoldID != "":
+		return holdErrorStatus(err)
+	default:
+		return http.StatusInternalServerError
+	}
+}
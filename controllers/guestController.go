@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"my-app/models"
+	"my-app/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GuestBookTickets holds seats for a customer who has no account, identified only by email and phone.
+// It returns a short-lived guest token used to pay for the hold.
+func GuestBookTickets(c *gin.Context) {
+	var request struct {
+		Email      string `json:"email" binding:"required,email"`
+		Phone      string `json:"phone" binding:"required"`
+		Name       string `json:"name"`
+		ScheduleID int    `json:"schedule_id"`
+		Seats      []int  `json:"seats"`
+		PartySize  int    `json:"party_size"`
+		models.CheckoutOptions
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	if !models.AllowGuestCheckout(request.Email, request.Phone) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many checkouts with this email or phone, please try again later"})
+		return
+	}
+
+	guest, err := models.CreateGuest(request.Email, request.Phone, request.Name)
+	if errors.Is(err, models.ErrAccountExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrInvalidPhone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hold, err := holdRequestedSeats(guest.ID, request.ScheduleID, request.Seats, request.PartySize, request.CheckoutOptions)
+	if err != nil {
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := utils.GenerateToken(uint(guest.ID), models.RoleGuest, guest.Name)
+	if err != nil {
+		log.Printf("Error generating guest token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Seats held, complete payment before the hold expires", "guest_token": token, "hold_id": hold.HoldID, "expires_at": hold.ExpiresAt, "seats": hold.SeatIDs, "warnings": hold.Warnings, "quote": hold.Quote})
+}
+
+// GuestPayment pays for a guest's hold, or again for its booking after the provider refused the card,
+// and returns the booking-access token, which a guest also needs to follow a card payment still with
+// the provider
+func GuestPayment(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var payment models.Payment
+	if err := c.ShouldBindJSON(&payment); err != nil || (payment.HoldID == "" && payment.BookingID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment details"})
+		return
+	}
+
+	result, err := models.ProcessPayment(userID, payment)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err, payment), gin.H{"error": err.Error()})
+		return
+	}
+
+	accessToken, err := utils.GenerateBookingAccessToken(result.BookingID, uint(userID))
+	if err != nil {
+		log.Printf("Error generating booking access token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Payment recorded but the booking link could not be generated", "booking_id": result.BookingID})
+		return
+	}
+
+	c.JSON(paymentResponse(result, gin.H{"booking_access_token": accessToken}))
+}
+
+// getGuestBooking loads the booking named by the booking-access token
+func getGuestBooking(c *gin.Context) (*models.BookingDetails, bool) {
+	userID, ok := getUserID(c)
+	bookingID := c.GetInt64("booking_id")
+	if !ok || bookingID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid booking access token"})
+		return nil, false
+	}
+
+	booking, err := models.GetBookingDetails(bookingID)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if booking.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": models.ErrBookingNotOwned.Error()})
+		return nil, false
+	}
+	return booking, true
+}
+
+// GetGuestBooking shows the booking a guest holds an access token for
+func GetGuestBooking(c *gin.Context) {
+	booking, ok := getGuestBooking(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"booking": booking})
+}
+
+// GetGuestReceipt returns the itemized receipt of a guest's booking
+func GetGuestReceipt(c *gin.Context) {
+	booking, ok := getGuestBooking(c)
+	if !ok {
+		return
+	}
+
+	receipt, err := models.GetReceipt(booking.BookingID)
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"receipt": receipt})
+}
+
+// GetGuestTickets returns the tickets of a guest's paid booking, issuing them on first download
+func GetGuestTickets(c *gin.Context) {
+	booking, ok := getGuestBooking(c)
+	if !ok {
+		return
+	}
+	if booking.Status != models.BookingStatusConfirmed && booking.Status != models.BookingStatusCheckedIn {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tickets can only be issued for confirmed bookings"})
+		return
+	}
+
+	tickets, err := models.GetTicketsByBookingID(int(booking.BookingID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tickets"})
+		return
+	}
+	if len(tickets) == 0 {
+		full, err := models.GetBookingByID(int(booking.BookingID))
+		if err == nil && full != nil {
+			tickets, err = models.IssueTickets(full)
+		}
+		if err != nil {
+			log.Printf("Error creating tickets for booking ID %d: %v", booking.BookingID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tickets"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tickets": tickets})
+}
+
+// CancelGuestBooking cancels a guest's booking under the same cutoff and refund rules as registered users
+func CancelGuestBooking(c *gin.Context) {
+	booking, ok := getGuestBooking(c)
+	if !ok {
+		return
+	}
+
+	refund, err := models.CancelBookingWithRefund(booking.BookingID, models.Actor{UserID: booking.UserID, Role: models.RoleGuest})
+	if err != nil {
+		c.JSON(bookingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Booking cancelled successfully", "refund": refund})
+}
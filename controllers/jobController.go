@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"my-app/workers"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJobMetrics reports what each background job has done in this process
+func GetJobMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"jobs": workers.SchedulerInstance.Metrics()})
+}
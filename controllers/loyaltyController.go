@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"my-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserLoyalty shows a user's points balance and history to an admin
+func GetUserLoyalty(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	account, err := models.GetLoyaltyAccount(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"loyalty": account})
+}
+
+// GetEarnRates lists the movie and theater earn rates
+func GetEarnRates(c *gin.Context) {
+	rates, err := models.GetEarnRates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"earn_rates": rates})
+}
+
+// CreateEarnRate sets the points earned per currency unit for a movie or theater
+func CreateEarnRate(c *gin.Context) {
+	var rate models.EarnRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	rate.RateID = 0
+
+	if err := models.SaveEarnRate(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Earn rate created", "earn_rate": rate})
+}
+
+// UpdateEarnRate replaces an earn rate
+func UpdateEarnRate(c *gin.Context) {
+	rateID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid earn rate ID"})
+		return
+	}
+	var rate models.EarnRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	rate.RateID = rateID
+
+	if err := models.SaveEarnRate(&rate); err != nil {
+		c.JSON(earnRateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Earn rate updated", "earn_rate": rate})
+}
+
+// DeleteEarnRate removes an earn rate so the theater or default rate applies again
+func DeleteEarnRate(c *gin.Context) {
+	rateID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid earn rate ID"})
+		return
+	}
+
+	if err := models.DeleteEarnRate(rateID); err != nil {
+		c.JSON(earnRateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Earn rate deleted"})
+}
+
+func earnRateErrorStatus(err error) int {
+	if errors.Is(err, models.ErrEarnRateNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
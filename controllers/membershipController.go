@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"errors"
+	"my-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMembershipPlans lists the plans open to new members
+func GetMembershipPlans(c *gin.Context) {
+	plans, err := models.GetMembershipPlans(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// GetAllMembershipPlans lists every plan, including those withdrawn
+func GetAllMembershipPlans(c *gin.Context) {
+	plans, err := models.GetMembershipPlans(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"plans": plans})
+}
+
+// CreateMembershipPlan adds a plan
+func CreateMembershipPlan(c *gin.Context) {
+	var plan models.MembershipPlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := models.CreateMembershipPlan(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Membership plan created", "plan": plan})
+}
+
+// UpdateMembershipPlan replaces a plan's price and benefits
+func UpdateMembershipPlan(c *gin.Context) {
+	planID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan ID"})
+		return
+	}
+	var plan models.MembershipPlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	plan.PlanID = planID
+
+	if err := models.UpdateMembershipPlan(&plan); err != nil {
+		c.JSON(membershipErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Membership plan updated", "plan": plan})
+}
+
+// DeactivateMembershipPlan closes a plan to new members and renewals
+func DeactivateMembershipPlan(c *gin.Context) {
+	planID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid plan ID"})
+		return
+	}
+
+	if err := models.DeactivateMembershipPlan(planID); err != nil {
+		c.JSON(membershipErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Membership plan withdrawn"})
+}
+
+// GetMyMembership shows the signed-in user's membership with its charges
+func GetMyMembership(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	membership, err := models.GetMembership(userID)
+	if err != nil {
+		c.JSON(membershipErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"membership": membership})
+}
+
+// SubscribeMembership charges the first month of a plan and makes the user a member
+func SubscribeMembership(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	var req struct {
+		PlanID int `json:"plan_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	membership, err := models.SubscribeMembership(userID, req.PlanID)
+	if err != nil {
+		c.JSON(membershipErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Membership started", "membership": membership})
+}
+
+// RenewMembership turns renewal back on, or restarts a lapsed or ended membership
+func RenewMembership(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	membership, err := models.RenewMembership(userID)
+	if err != nil {
+		c.JSON(membershipErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Membership renewed", "membership": membership})
+}
+
+// CancelMembership stops the membership renewing at the end of its month
+func CancelMembership(c *gin.Context) {
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	membership, err := models.CancelMembership(userID)
+	if err != nil {
+		c.JSON(membershipErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Membership cancelled", "membership": membership})
+}
+
+func membershipErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrPlanNotFound), errors.Is(err, models.ErrMembershipNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrAlreadyMember):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrPaymentDeclined):
+		return http.StatusPaymentRequired
+	case errors.Is(err, models.ErrNoPaymentProvider):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
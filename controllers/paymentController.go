@@ -1,15 +1,24 @@
 package controllers
 
 import (
+	"bytes"
+	"io"
+	"my-app/config"
 	"my-app/models"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Process payment (C)
+// Process payment (C). The amount due is worked out by the server; the request only says how to pay it.
 func ProcessPayment(c *gin.Context) {
-	userID := c.GetInt("user_id") // Assuming you have user_id from a middleware or JWT context
+	userID, ok := getUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	var payment models.Payment
 	if err := c.ShouldBindJSON(&payment); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment details"})
@@ -17,11 +26,110 @@ func ProcessPayment(c *gin.Context) {
 	}
 
 	// Call the ProcessPayment function from the models package
-	err := models.ProcessPayment(userID, payment)
+	result, err := models.ProcessPayment(userID, payment)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err, payment), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(paymentResponse(result, gin.H{}))
+}
+
+// paymentResponse adds a payment's outcome and the booking's price to a response. A card payment
+// still with the provider is accepted rather than successful; one the provider refused needs paying again.
+func paymentResponse(result *models.PaymentResult, response gin.H) (int, gin.H) {
+	response["booking_id"] = result.BookingID
+	response["payment_status"] = result.Status
+	response["amount_due"] = result.AmountDue
+	response["payments"] = result.Payments
+	if booking, err := models.GetBookingDetails(result.BookingID); err == nil {
+		response["price"] = booking.PriceBreakdown
+	}
+	if result.Status == models.PaymentFailed {
+		response["error"] = result.FailureReason
+		return http.StatusPaymentRequired, response
+	}
+	if result.Status == models.PaymentPending {
+		response["status"] = "Payment started, complete it with the payment provider"
+		return http.StatusAccepted, response
+	}
+	response["status"] = "Payment successful"
+	return http.StatusOK, response
+}
+
+// PaymentCallback receives the payment provider's outcome for a card payment. The body must be
+// signed with the shared webhook secret in the X-Payment-Signature header.
+func PaymentCallback(c *gin.Context) {
+	if config.PaymentWebhookSecret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payment callbacks are not configured"})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid callback"})
+		return
+	}
+	if !models.VerifyPaymentCallback(body, c.GetHeader("X-Payment-Signature")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	var callback struct {
+		Reference string `json:"reference" binding:"required"`
+		Status    string `json:"status" binding:"required"`
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err := c.ShouldBindJSON(&callback); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid callback", "details": err.Error()})
+		return
+	}
+
+	if err := models.SettleProviderPayment(callback.Reference, callback.Status); err != nil {
+		c.JSON(paymentErrorStatus(err, models.Payment{}), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Payment updated"})
+}
+
+// GetBookingPayments lists every part of a booking's payments for an admin
+func GetBookingPayments(c *gin.Context) {
+	bookingID, err := strconv.ParseInt(c.Param("booking_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	payments, err := models.GetBookingPayments(bookingID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"payments": payments})
+}
 
-	c.JSON(http.StatusOK, gin.H{"status": "Payment successful"})
+// SettlePayment lets an admin mark a pending card payment PAID or FAILED, e.g. after checking with the provider
+func SettlePayment(c *gin.Context) {
+	paymentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := models.SettlePayment(paymentID, req.Status, actor); err != nil {
+		c.JSON(paymentErrorStatus(err, models.Payment{}), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Payment updated"})
 }
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"my-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTicketCategories lists the ticket categories a seat can be booked under,
+// with the eligibility note shown at checkout and at the door.
+func GetTicketCategories(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"categories": models.TicketCategories()})
+}
+
+// QuotePrice returns the price breakdown of the selected seats before checkout.
+// A promo code is previewed without being reserved, and the prices are only guaranteed once the seats are held.
+func QuotePrice(c *gin.Context) {
+	var request struct {
+		ScheduleID int   `json:"schedule_id" binding:"required"`
+		Seats      []int `json:"seats" binding:"required"`
+		models.CheckoutOptions
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	quote, err := models.QuoteSeats(0, request.ScheduleID, request.Seats, request.CheckoutOptions)
+	if err != nil {
+		c.JSON(holdErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quote": quote})
+}
+
+// GetPriceRuleSets lists every pricing rule set with its rules
+func GetPriceRuleSets(c *gin.Context) {
+	sets, err := models.GetPriceRuleSets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rule_sets": sets})
+}
+
+// GetPriceRuleSet returns one pricing rule set
+func GetPriceRuleSet(c *gin.Context) {
+	ruleSetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule set ID"})
+		return
+	}
+
+	set, err := models.GetPriceRuleSet(ruleSetID)
+	if err != nil {
+		c.JSON(ruleSetErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rule_set": set})
+}
+
+// CreatePriceRuleSet saves a new rule set together with its rules
+func CreatePriceRuleSet(c *gin.Context) {
+	var set models.PriceRuleSet
+	if err := c.ShouldBindJSON(&set); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := models.CreatePriceRuleSet(&set); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Rule set created successfully", "rule_set": set})
+}
+
+// UpdatePriceRuleSet replaces a rule set's settings and rules
+func UpdatePriceRuleSet(c *gin.Context) {
+	ruleSetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule set ID"})
+		return
+	}
+
+	var set models.PriceRuleSet
+	if err := c.ShouldBindJSON(&set); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	set.RuleSetID = ruleSetID
+
+	if err := models.UpdatePriceRuleSet(&set); err != nil {
+		c.JSON(ruleSetErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Rule set updated successfully", "rule_set": set})
+}
+
+// DeletePriceRuleSet removes a rule set and its rules
+func DeletePriceRuleSet(c *gin.Context) {
+	ruleSetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule set ID"})
+		return
+	}
+
+	if err := models.DeletePriceRuleSet(ruleSetID); err != nil {
+		c.JSON(ruleSetErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Rule set deleted successfully"})
+}
+
+// GetHolidays lists holiday dates, optionally for one ?calendar=
+func GetHolidays(c *gin.Context) {
+	holidays, err := models.GetHolidays(c.Query("calendar"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"holidays": holidays})
+}
+
+// CreateHoliday adds a date to a holiday calendar used by HOLIDAY pricing rules
+func CreateHoliday(c *gin.Context) {
+	var holiday models.Holiday
+	if err := c.ShouldBindJSON(&holiday); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := models.CreateHoliday(&holiday); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Holiday created successfully", "holiday": holiday})
+}
+
+// DeleteHoliday removes a date from its holiday calendar
+func DeleteHoliday(c *gin.Context) {
+	holidayID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid holiday ID"})
+		return
+	}
+
+	if err := models.DeleteHoliday(holidayID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Holiday deleted successfully"})
+}
+
+func ruleSetErrorStatus(err error) int {
+	if errors.Is(err, models.ErrRuleSetNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
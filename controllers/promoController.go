@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"my-app/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPromoCodes lists every promo code with its usage
+func GetPromoCodes(c *gin.Context) {
+	promos, err := models.GetPromoCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"promo_codes": promos})
+}
+
+// GetPromoCode returns one promo code
+func GetPromoCode(c *gin.Context) {
+	promoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo ID"})
+		return
+	}
+
+	promo, err := models.GetPromoCode(promoID)
+	if err != nil {
+		c.JSON(promoErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"promo_code": promo})
+}
+
+// CreatePromoCode saves a new promo code
+func CreatePromoCode(c *gin.Context) {
+	var promo models.PromoCode
+	if err := c.ShouldBindJSON(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+
+	if err := models.CreatePromoCode(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Promo code created successfully", "promo_code": promo})
+}
+
+// UpdatePromoCode changes a promo code's settings
+func UpdatePromoCode(c *gin.Context) {
+	promoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo ID"})
+		return
+	}
+
+	var promo models.PromoCode
+	if err := c.ShouldBindJSON(&promo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "details": err.Error()})
+		return
+	}
+	promo.PromoID = promoID
+
+	if err := models.UpdatePromoCode(&promo); err != nil {
+		c.JSON(promoErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Promo code updated successfully"})
+}
+
+// DeactivatePromoCode stops a promo code from being used
+func DeactivatePromoCode(c *gin.Context) {
+	promoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo ID"})
+		return
+	}
+
+	if err := models.DeactivatePromoCode(promoID); err != nil {
+		c.JSON(promoErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Promo code deactivated"})
+}
+
+func promoErrorStatus(err error) int {
+	if errors.Is(err, models.ErrPromoNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -100,10 +102,18 @@ func DeleteScheduleHandler(c *gin.Context) {
 	}
 
 	err = models.DeleteSchedule(id)
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
 		return
 	}
+	if errors.Is(err, models.ErrScheduleHasBookings) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule", "details": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted successfully"})
 }
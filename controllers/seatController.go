@@ -1,7 +1,9 @@
 package controllers // Ensure the package is declared
 
 import (
+	"errors"
 	"my-app/models"
+	"my-app/sockets"
 	"net/http"
 	"strconv"
 
@@ -34,8 +36,8 @@ func GetAllSeats(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"seats": seats})
 }
 
-// UpdateSeat updates an existing seat
-// UpdateSeatHandler - Handler for updating a seat
+// UpdateSeatHandler - Handler for updating a seat.
+// When schedule_id is given, only the seat's status for that showtime is changed (AVAILABLE or BLOCKED).
 func UpdateSeatHandler(c *gin.Context) {
 	seatIDStr := c.Param("id")
 	seatID, err := strconv.Atoi(seatIDStr)
@@ -44,12 +46,26 @@ func UpdateSeatHandler(c *gin.Context) {
 		return
 	}
 
-	var seat models.Seat
-	if err := c.ShouldBindJSON(&seat); err != nil {
+	var request struct {
+		models.Seat
+		ScheduleID int    `json:"schedule_id"`
+		Status     string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
+	if request.ScheduleID != 0 {
+		if err := models.SetScheduleSeatStatus(request.ScheduleID, seatID, request.Status); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Seat status updated successfully"})
+		return
+	}
+
+	seat := request.Seat
 	seat.SeatID = seatID // Ensure the seat ID from the URL is assigned
 
 	if err := models.UpdateSeat(&seat); err != nil {
@@ -77,7 +93,8 @@ func DeleteSeat(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Seat deleted successfully"})
 }
 
-// Example function to get seats (R)
+// GetSeatsByScreenID lists the seats of a screen.
+// With ?schedule_id= it reports each seat's availability for that showtime.
 func GetSeatsByScreenID(c *gin.Context) {
 	screenIDStr := c.Param("screenID")
 	screenID, err := strconv.Atoi(screenIDStr)
@@ -86,6 +103,29 @@ func GetSeatsByScreenID(c *gin.Context) {
 		return
 	}
 
+	if scheduleIDStr := c.Query("schedule_id"); scheduleIDStr != "" {
+		scheduleID, err := strconv.Atoi(scheduleIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+			return
+		}
+
+		schedule, err := models.GetScheduleByID(scheduleID)
+		if err != nil || schedule.ScreenID != screenID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found for this screen"})
+			return
+		}
+
+		seats, err := models.GetSeatsByScheduleID(scheduleID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"seats": seats, "schedule_id": scheduleID, "available_seats": schedule.AvailableSeats})
+		return
+	}
+
 	seats, err := models.GetSeatsByScreenID(screenID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -94,3 +134,85 @@ func GetSeatsByScreenID(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"seats": seats})
 }
+
+// GetScreenLayout returns the seat map of a screen including aisles and blocked cells
+func GetScreenLayout(c *gin.Context) {
+	screenID, err := strconv.Atoi(c.Param("screenID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid screen ID"})
+		return
+	}
+
+	layout, err := models.GetScreenLayout(screenID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"layout": layout})
+}
+
+// SaveScreenLayout replaces the seat map of a screen in one request
+func SaveScreenLayout(c *gin.Context) {
+	screenID, err := strconv.Atoi(c.Param("screenID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid screen ID"})
+		return
+	}
+
+	var layout models.ScreenLayout
+	if err := c.ShouldBindJSON(&layout); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	layout.ScreenID = screenID
+
+	if err := models.SaveScreenLayout(&layout); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, models.ErrLayoutHasBookings) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Layout saved successfully", "layout": layout})
+}
+
+// GenerateSeatGrid creates every seat of a screen from a row/column template
+func GenerateSeatGrid(c *gin.Context) {
+	screenID, err := strconv.Atoi(c.Param("screenID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid screen ID"})
+		return
+	}
+
+	var template models.SeatGridTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	layout, err := models.GenerateSeatGrid(screenID, template)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, models.ErrLayoutHasBookings) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Seats generated successfully", "seat_count": len(layout.Seats), "layout": layout})
+}
+
+// ScheduleSeatsWebSocketHandler streams seat status changes of a schedule to seat-picker screens
+func ScheduleSeatsWebSocketHandler(c *gin.Context) {
+	scheduleID, err := strconv.Atoi(c.Param("scheduleID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return
+	}
+
+	sockets.ServeScheduleWs(c.Writer, c.Request, scheduleID)
+}
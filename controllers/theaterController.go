@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"my-app/models"
 	"net/http"
 	"strconv"
@@ -17,6 +18,10 @@ func CreateTheater(c *gin.Context) {
 	}
 
 	err := models.CreateTheater(theater)
+	if errors.Is(err, models.ErrInvalidSeatGapPolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -56,6 +61,10 @@ func UpdateTheater(c *gin.Context) {
 
 	// Call the model function to update the theater
 	err = models.UpdateTheater(theater)
+	if errors.Is(err, models.ErrInvalidSeatGapPolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -80,3 +89,56 @@ func DeleteTheater(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"status": "Theater deleted successfully"})
 }
+
+// GetTheaterTaxRate shows the VAT rate and booking fee charged at a theater
+func GetTheaterTaxRate(c *gin.Context) {
+	theaterID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid theater ID"})
+		return
+	}
+
+	rate, err := models.GetTheaterTaxRate(theaterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tax_rate": rate, "defaults": models.DefaultTaxRate()})
+}
+
+// SetTheaterTaxRate sets a theater's own VAT rate and booking fee
+func SetTheaterTaxRate(c *gin.Context) {
+	theaterID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid theater ID"})
+		return
+	}
+
+	var rate models.TaxRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	rate.TheaterID = theaterID
+
+	if err := models.SaveTheaterTaxRate(rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Tax rate updated", "tax_rate": rate})
+}
+
+// DeleteTheaterTaxRate puts a theater back on the default VAT rate and booking fee
+func DeleteTheaterTaxRate(c *gin.Context) {
+	theaterID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid theater ID"})
+		return
+	}
+
+	if err := models.DeleteTheaterTaxRate(theaterID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Tax rate reset to defaults"})
+}
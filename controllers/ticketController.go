@@ -1,17 +1,17 @@
 package controllers
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
 	"log"
 	"my-app/models"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// CreateTicketsForBookingHandler issues the tickets of a confirmed booking to its owner or an admin.
+// Tickets already issued are returned as they are.
 func CreateTicketsForBookingHandler(c *gin.Context) {
 	var req struct {
 		BookingID int `json:"booking_id" binding:"required"`
@@ -22,45 +22,42 @@ func CreateTicketsForBookingHandler(c *gin.Context) {
 		return
 	}
 
-	// Retrieve booking details
-	booking, err := models.GetBookingByID(req.BookingID)
-	if err != nil {
-		log.Printf("Database error while retrieving booking: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	booking, ok := getOwnedBooking(c, req.BookingID)
+	if !ok {
 		return
 	}
-	if booking == nil {
-		log.Printf("Invalid booking ID: %d", req.BookingID)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+
+	// Only paid bookings get tickets
+	if booking.Status != models.BookingStatusConfirmed && booking.Status != models.BookingStatusCheckedIn {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tickets can only be issued for confirmed bookings"})
 		return
 	}
 
-	// Create tickets based on seatsBooked
-	var tickets []models.Ticket
-	for i := 1; i <= booking.SeatsBooked; i++ {
-		ticket := models.Ticket{
-			BookingID: booking.BookingID,
-			SeatID:    i,      // Increment seat ID for each ticket
-			Fare:      1000.0, // Set fare as needed or retrieve dynamically
-			IssuedAt:  sql.NullTime{Time: time.Now(), Valid: true},
-			QRCode:    fmt.Sprintf("QR_%d_%d", booking.BookingID, i),
-		}
+	tickets, err := models.GetTicketsByBookingID(booking.BookingID)
+	if err != nil {
+		log.Printf("Error retrieving tickets for booking ID %d: %v", booking.BookingID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tickets"})
+		return
+	}
+	if len(tickets) > 0 {
+		c.JSON(http.StatusOK, gin.H{"tickets": tickets})
+		return
+	}
 
-		// Insert ticket into database
-		ticketID, err := models.CreateTicket(&ticket)
-		if err != nil {
-			log.Printf("Error creating ticket for seat ID %d: %v", i, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tickets"})
-			return
-		}
-		ticket.TicketID = ticketID
-		tickets = append(tickets, ticket)
+	// Create one ticket for each seat recorded on the booking
+	tickets, err = models.IssueTickets(booking)
+	if err != nil {
+		log.Printf("Error creating tickets for booking ID %d: %v", booking.BookingID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tickets"})
+		return
 	}
 
 	// Return the created tickets
-	log.Printf("Successfully created %d tickets for booking ID %d", booking.SeatsBooked, booking.BookingID)
+	log.Printf("Successfully created %d tickets for booking ID %d", len(tickets), booking.BookingID)
 	c.JSON(http.StatusCreated, gin.H{"tickets": tickets})
 }
+
+// GetTicketsByBookingIDHandler returns the tickets issued for a booking to its owner or an admin
 func GetTicketsByBookingIDHandler(c *gin.Context) {
 	bookingID, err := strconv.Atoi(c.Param("bookingID"))
 	if err != nil {
@@ -68,7 +65,11 @@ func GetTicketsByBookingIDHandler(c *gin.Context) {
 		return
 	}
 
-	tickets, err := models.GetBookingByID(bookingID)
+	if _, ok := getOwnedBooking(c, bookingID); !ok {
+		return
+	}
+
+	tickets, err := models.GetTicketsByBookingID(bookingID)
 	if err != nil {
 		log.Printf("Error retrieving tickets for booking ID %d: %v", bookingID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tickets"})
@@ -77,3 +78,61 @@ func GetTicketsByBookingIDHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"tickets": tickets})
 }
+
+// getOwnedBooking loads a booking for its owner or an admin, writing the error response otherwise
+func getOwnedBooking(c *gin.Context, bookingID int) (*models.Booking, bool) {
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	booking, err := models.GetBookingByID(bookingID)
+	if err != nil {
+		log.Printf("Database error while retrieving booking: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	if booking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": models.ErrBookingNotFound.Error()})
+		return nil, false
+	}
+	if actor.Role != "admin" && booking.UserID != actor.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": models.ErrBookingNotOwned.Error()})
+		return nil, false
+	}
+	return booking, true
+}
+
+// ScanTicket checks a ticket in at the door by its QR code.
+// The first scan checks the booking in; a second scan of the same ticket is rejected.
+func ScanTicket(c *gin.Context) {
+	var req struct {
+		QRCode string `json:"qr_code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+
+	actor, ok := getActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	ticket, err := models.ScanTicket(req.QRCode, actor)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, models.ErrTicketNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, models.ErrTicketAlreadyScanned), errors.Is(err, models.ErrTicketNotValid):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "ticket": ticket})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ticket": ticket})
+}
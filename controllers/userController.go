@@ -59,3 +59,64 @@ func GetAllUsers(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"users": users})
 }
+
+// GetUserPurchaseLimits shows the purchase limits in force for a user and any admin override
+func GetUserPurchaseLimits(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	limits, err := models.GetPurchaseLimits(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	override, err := models.GetPurchaseLimitOverride(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"limits": limits, "override": override, "defaults": models.DefaultPurchaseLimits()})
+}
+
+// SetUserPurchaseLimits lets an admin raise or lower a user's purchase limits, e.g. for group sales
+func SetUserPurchaseLimits(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var override models.PurchaseLimitOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	override.UserID = userID
+
+	if err := models.SavePurchaseLimitOverride(override); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Purchase limits updated", "override": override})
+}
+
+// DeleteUserPurchaseLimits puts a user back on the default purchase limits
+func DeleteUserPurchaseLimits(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := models.DeletePurchaseLimitOverride(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Purchase limits reset to defaults"})
+}
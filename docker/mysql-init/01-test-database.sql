@@ -0,0 +1,3 @@
+-- Scratch database for the tests, which migrate it and leave their rows behind
+CREATE DATABASE IF NOT EXISTS ticket_booking_test;
+GRANT ALL PRIVILEGES ON ticket_booking_test.* TO 'user'@'%';
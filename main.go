@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"my-app/config"
 	_ "my-app/docs" // Import Swagger docs
+	"my-app/migrations"
+	"my-app/models"
 	"my-app/routes"
-
-	// Import the sockets package
+	"my-app/sockets" // Import the sockets package
+	"my-app/workers"
+	"net/http"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -14,8 +21,30 @@ import (
 )
 
 func main() {
+	// Dừng server và các job nền khi nhận SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Kết nối đến cơ sở dữ liệu
 	config.ConnectDB()
+	if err := migrations.Apply(config.DB); err != nil {
+		log.Fatalf("Failed to migrate the database: %v", err)
+	}
+
+	// Take card payments through the configured provider
+	switch config.PaymentProvider {
+	case "":
+		log.Println("No payment provider configured: card payments and membership charges are refused")
+	case "fake":
+		log.Println("Using the fake payment provider: payments are approved without taking money")
+		models.PaymentGateway = models.NewFakePaymentProvider()
+	default:
+		log.Fatalf("Unknown payment provider %q", config.PaymentProvider)
+	}
+
+	// Push seat status changes to clients watching a schedule
+	go sockets.HubInstance.Run()
+	models.SeatEventPublisher = sockets.PublishSeatChanges
 
 	// Khởi tạo router
 	r := gin.Default()
@@ -24,8 +53,8 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"}, // URL của frontend
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept"},
-		ExposeHeaders:    []string{"Content-Length", "Content-Type"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Type", "Idempotent-Replayed"},
 		AllowCredentials: true, // Cho phép gửi cookie hoặc thông tin xác thực
 		MaxAge:           12 * time.Hour,
 	}))
@@ -46,11 +75,31 @@ func main() {
 	} else {
 		defer config.RedisClient.Close()
 		log.Println("Kết nối thành công đến Redis")
+		go models.WatchHoldExpiry(ctx)
 	}
 
+	// Expire unpaid bookings and holds and renew memberships in the background
+	workers.SchedulerInstance.Register(workers.ExpiryJob())
+	workers.SchedulerInstance.Register(workers.MembershipRenewalJob())
+	workers.SchedulerInstance.Start(ctx)
+
 	// Khởi động server trên cổng 8080
-	log.Println("Khởi động server trên cổng :8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatalf("Không thể khởi động server: %v", err)
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		log.Println("Khởi động server trên cổng :8080")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Không thể khởi động server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Đang tắt server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Không thể tắt server gọn gàng: %v", err)
 	}
+	workers.SchedulerInstance.Wait()
+	log.Println("Server đã dừng")
 }
@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"my-app/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BookingAccessMiddleware authenticates a guest with the booking-access token issued at checkout.
+// The token is read from the Booking-Access-Token header or the "token" query parameter,
+// so it also works from a link in the confirmation email.
+func BookingAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenStr := c.GetHeader("Booking-Access-Token")
+		if tokenStr == "" {
+			tokenStr = c.Query("token")
+		}
+		if tokenStr == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Booking access token missing"})
+			c.Abort()
+			return
+		}
+
+		claims, err := utils.ValidateBookingAccessToken(tokenStr)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid booking access token"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("role", "guest")
+		c.Set("booking_id", claims.BookingID)
+		c.Next()
+	}
+}
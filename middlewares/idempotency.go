@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"my-app/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseRecorder keeps a copy of the response body while it is written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware replays the stored response when a request is retried with the same
+// Idempotency-Key header, and refuses a key reused with a different body. Keys are scoped to the
+// authenticated user and the endpoint, so it must run after JWTAuthMiddleware. Requests without
+// the header are not affected.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		requestHash := models.IdempotencyRequestHash(body)
+
+		userID, _ := c.Get("user_id")
+		scope := fmt.Sprintf("%v:%s:%s", userID, c.Request.Method, c.FullPath())
+
+		reserved, stored, err := models.ReserveIdempotencyKey(scope, key, requestHash)
+		if err != nil {
+			log.Printf("Error reserving idempotency key: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not process Idempotency-Key"})
+			c.Abort()
+			return
+		}
+		if !reserved {
+			if stored.RequestHash != requestHash {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+				c.Abort()
+				return
+			}
+			if stored.InProgress {
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+				c.Abort()
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.StatusCode, "application/json; charset=utf-8", stored.Body)
+			c.Abort()
+			return
+		}
+
+		// A panicking handler must not leave the key in progress until it expires
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				models.ReleaseIdempotencyKey(scope, key)
+				panic(recovered)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not stored so that the client can retry them
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			models.ReleaseIdempotencyKey(scope, key)
+			return
+		}
+		if err := models.SaveIdempotentResponse(scope, key, requestHash, status, recorder.body.Bytes()); err != nil {
+			log.Printf("Error saving idempotent response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"my-app/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitByIP refuses a client address more than limit requests per window on the routes it guards.
+// name separates the counts of different routes.
+func RateLimitByIP(name string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !models.AllowRequest(name+":ip:"+c.ClientIP(), limit, window) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
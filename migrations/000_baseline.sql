@@ -0,0 +1,98 @@
+-- Tables the application started with. IF NOT EXISTS leaves databases created before
+-- migrations were tracked untouched.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    password VARCHAR(255) NOT NULL,
+    phone VARCHAR(32) NOT NULL DEFAULT '',
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    gender VARCHAR(20) NOT NULL DEFAULT '',
+    UNIQUE KEY uq_users_email (email)
+);
+
+CREATE TABLE IF NOT EXISTS THEATER (
+    theaterID INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    location VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS ROOM (
+    roomID INT AUTO_INCREMENT PRIMARY KEY,
+    theaterID INT NOT NULL,
+    roomNumber INT NOT NULL,
+    KEY idx_room_theater (theaterID)
+);
+
+CREATE TABLE IF NOT EXISTS SCREEN (
+    screenID INT AUTO_INCREMENT PRIMARY KEY,
+    roomID INT NOT NULL,
+    screenNumber INT NOT NULL,
+    opacity DOUBLE NOT NULL DEFAULT 0,
+    KEY idx_screen_room (roomID)
+);
+
+CREATE TABLE IF NOT EXISTS SEAT (
+    seatID INT AUTO_INCREMENT PRIMARY KEY,
+    screenID INT NOT NULL,
+    seatNumber INT NOT NULL,
+    isBooked BOOLEAN NOT NULL DEFAULT FALSE,
+    KEY idx_seat_screen (screenID)
+);
+
+CREATE TABLE IF NOT EXISTS MOVIE (
+    movieID INT AUTO_INCREMENT PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    genre VARCHAR(100) NOT NULL DEFAULT '',
+    duration INT NOT NULL DEFAULT 0,
+    picture VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS SCHEDULE (
+    scheduleID INT AUTO_INCREMENT PRIMARY KEY,
+    movieID INT NOT NULL,
+    screenID INT NOT NULL,
+    showTime DATETIME NOT NULL,
+    availableSeats INT NOT NULL DEFAULT 0,
+    fare DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    KEY idx_schedule_screen (screenID),
+    KEY idx_schedule_movie (movieID)
+);
+
+CREATE TABLE IF NOT EXISTS BOOKING (
+    bookingID BIGINT AUTO_INCREMENT PRIMARY KEY,
+    userID INT NOT NULL,
+    movieID INT NOT NULL,
+    screenID INT NOT NULL,
+    bookingDate DATETIME NOT NULL,
+    seatsBooked INT NOT NULL DEFAULT 0,
+    paymentStatus VARCHAR(20) NULL,
+    KEY idx_booking_user (userID)
+);
+
+CREATE TABLE IF NOT EXISTS PAYMENT (
+    paymentID BIGINT AUTO_INCREMENT PRIMARY KEY,
+    bookingID BIGINT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    paymentStatus VARCHAR(20) NOT NULL,
+    KEY idx_payment_booking (bookingID)
+);
+
+CREATE TABLE IF NOT EXISTS TICKET (
+    ticketID INT AUTO_INCREMENT PRIMARY KEY,
+    bookingID BIGINT NOT NULL,
+    seatID INT NOT NULL,
+    fare DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    issuedAt DATETIME NULL,
+    qrCode VARCHAR(255) NOT NULL,
+    KEY idx_ticket_booking (bookingID),
+    KEY idx_ticket_qr (qrCode)
+);
+
+CREATE TABLE IF NOT EXISTS CHAT (
+    chatID INT AUTO_INCREMENT PRIMARY KEY,
+    userID INT NOT NULL,
+    messageText TEXT NOT NULL,
+    timestamp DATETIME NOT NULL
+);
@@ -0,0 +1,24 @@
+-- Seat availability per showtime, replacing the screen-wide SEAT.isBooked flag.
+-- The primary key lets syncScheduleSeats add missing rows with INSERT IGNORE and
+-- stops a seat being sold twice for one schedule.
+CREATE TABLE SCHEDULE_SEAT (
+    scheduleID INT NOT NULL,
+    seatID INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'AVAILABLE',
+    PRIMARY KEY (scheduleID, seatID),
+    KEY idx_schedule_seat_seat (seatID)
+);
+
+ALTER TABLE BOOKING
+    ADD COLUMN scheduleID INT NULL AFTER userID,
+    ADD KEY idx_booking_schedule (scheduleID);
+
+-- Seats sold under the old flag cannot be traced to a showtime, so they stay sold for the
+-- shows still to come; staff can release them seat by seat
+INSERT IGNORE INTO SCHEDULE_SEAT (scheduleID, seatID, status)
+SELECT s.scheduleID, st.seatID, IF(st.isBooked AND s.showTime > NOW(), 'BOOKED', 'AVAILABLE')
+FROM SCHEDULE s
+JOIN SEAT st ON st.screenID = s.screenID;
+
+UPDATE SCHEDULE s
+SET availableSeats = (SELECT COUNT(*) FROM SCHEDULE_SEAT ss WHERE ss.scheduleID = s.scheduleID AND ss.status = 'AVAILABLE');
@@ -0,0 +1,7 @@
+-- The seats each booking took. Bookings made before this table existed have no rows.
+CREATE TABLE BOOKING_SEAT (
+    bookingID BIGINT NOT NULL,
+    seatID INT NOT NULL,
+    PRIMARY KEY (bookingID, seatID),
+    KEY idx_booking_seat_seat (seatID)
+);
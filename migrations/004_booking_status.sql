@@ -0,0 +1,17 @@
+-- Booking lifecycle status and its transitions
+ALTER TABLE BOOKING
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'PENDING' AFTER seatsBooked,
+    ADD KEY idx_booking_status (status);
+
+UPDATE BOOKING SET status = 'CONFIRMED' WHERE paymentStatus = 'PAID';
+
+CREATE TABLE BOOKING_STATUS_HISTORY (
+    historyID BIGINT AUTO_INCREMENT PRIMARY KEY,
+    bookingID BIGINT NOT NULL,
+    fromStatus VARCHAR(20) NOT NULL,
+    toStatus VARCHAR(20) NOT NULL,
+    actorID INT NOT NULL,
+    actorRole VARCHAR(20) NOT NULL,
+    changedAt DATETIME NOT NULL,
+    KEY idx_booking_status_history_booking (bookingID)
+);
@@ -0,0 +1,9 @@
+-- Refunds given when a booking is cancelled
+CREATE TABLE REFUND (
+    refundID BIGINT AUTO_INCREMENT PRIMARY KEY,
+    bookingID BIGINT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    percent INT NOT NULL,
+    createdAt DATETIME NOT NULL,
+    KEY idx_refund_booking (bookingID)
+);
@@ -0,0 +1,11 @@
+-- Stored responses for Idempotency-Key requests when Redis is unavailable. The primary key on
+-- keyHash is what lets INSERT IGNORE reserve a key for exactly one request. requestHash is the hash
+-- of the body the key was first used with.
+CREATE TABLE IDEMPOTENCY_KEY (
+    keyHash CHAR(64) NOT NULL PRIMARY KEY,
+    requestHash CHAR(64) NOT NULL,
+    statusCode INT NOT NULL,
+    responseBody MEDIUMTEXT NOT NULL,
+    inProgress BOOLEAN NOT NULL,
+    expiresAt DATETIME NOT NULL
+);
@@ -0,0 +1,21 @@
+-- Seat positions and types, and each screen's grid with its aisles and blocked cells
+ALTER TABLE SEAT
+    ADD COLUMN rowLabel VARCHAR(8) NULL,
+    ADD COLUMN columnNumber INT NULL,
+    ADD COLUMN posX INT NULL,
+    ADD COLUMN posY INT NULL,
+    ADD COLUMN seatType VARCHAR(20) NOT NULL DEFAULT 'STANDARD';
+
+CREATE TABLE SCREEN_LAYOUT (
+    screenID INT NOT NULL PRIMARY KEY,
+    gridRows INT NOT NULL,
+    gridColumns INT NOT NULL
+);
+
+CREATE TABLE SCREEN_LAYOUT_CELL (
+    screenID INT NOT NULL,
+    posX INT NOT NULL,
+    posY INT NOT NULL,
+    cellType VARCHAR(20) NOT NULL,
+    PRIMARY KEY (screenID, posX, posY)
+);
@@ -0,0 +1,2 @@
+-- Per-theater single-seat gap policy: OFF, WARN or ENFORCE
+ALTER TABLE THEATER ADD COLUMN seatGapPolicy VARCHAR(10) NOT NULL DEFAULT 'OFF';
@@ -0,0 +1,4 @@
+-- Payment deadline of PENDING bookings, swept by the expiry worker
+ALTER TABLE BOOKING
+    ADD COLUMN expiresAt DATETIME NULL AFTER status,
+    ADD KEY idx_booking_expiry (status, expiresAt);
@@ -0,0 +1,23 @@
+-- Every guest checkout gets its own user row, so an email can appear on many guests. It stays
+-- unique among registered accounts: accountEmail is NULL for guests and the email for everyone else.
+-- Older databases may name the unique key on email differently, so it is looked up, and the whole
+-- change runs as one ALTER so that a failure leaves the table as it was.
+SET @email_key = (
+    SELECT s.INDEX_NAME FROM information_schema.STATISTICS s
+    WHERE s.TABLE_SCHEMA = DATABASE() AND s.TABLE_NAME = 'users' AND s.COLUMN_NAME = 'email' AND s.NON_UNIQUE = 0
+        AND NOT EXISTS (
+            SELECT 1 FROM information_schema.STATISTICS o
+            WHERE o.TABLE_SCHEMA = s.TABLE_SCHEMA AND o.TABLE_NAME = s.TABLE_NAME AND o.INDEX_NAME = s.INDEX_NAME AND o.COLUMN_NAME <> 'email'
+        )
+    LIMIT 1
+);
+SET @alter_users = CONCAT(
+    'ALTER TABLE users ',
+    IF(@email_key IS NULL, '', CONCAT('DROP INDEX `', @email_key, '`, ')),
+    'ADD COLUMN accountEmail VARCHAR(255) GENERATED ALWAYS AS (IF(role = ''guest'', NULL, email)) STORED, ',
+    'ADD UNIQUE KEY uq_users_account_email (accountEmail), ',
+    'ADD KEY idx_users_email (email)'
+);
+PREPARE alter_users FROM @alter_users;
+EXECUTE alter_users;
+DEALLOCATE PREPARE alter_users;
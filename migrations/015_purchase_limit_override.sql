@@ -0,0 +1,11 @@
+-- Per-user purchase limits replacing the defaults, e.g. for group sales. NULL keeps the default.
+CREATE TABLE PURCHASE_LIMIT_OVERRIDE (
+    userID INT NOT NULL PRIMARY KEY,
+    maxSeatsPerBooking INT NULL,
+    maxSeatsPerSchedule INT NULL,
+    maxActiveBookings INT NULL,
+    note VARCHAR(255) NULL
+);
+
+-- Guest checkouts are matched on their email and phone to count them as one customer
+CREATE INDEX idx_users_phone ON users (phone);
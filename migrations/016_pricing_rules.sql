@@ -0,0 +1,31 @@
+-- Pricing rule sets, holiday calendars, schedule formats and the price each booked seat sold at
+CREATE TABLE PRICE_RULE_SET (
+    ruleSetID INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    theaterID INT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    KEY idx_price_rule_set_theater (theaterID)
+);
+
+CREATE TABLE PRICE_RULE (
+    ruleID INT AUTO_INCREMENT PRIMARY KEY,
+    ruleSetID INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    ruleType VARCHAR(20) NOT NULL,
+    matchValue VARCHAR(255) NOT NULL DEFAULT '',
+    adjustmentType VARCHAR(10) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    KEY idx_price_rule_set (ruleSetID)
+);
+
+CREATE TABLE HOLIDAY (
+    holidayID INT AUTO_INCREMENT PRIMARY KEY,
+    calendar VARCHAR(50) NOT NULL,
+    holidayDate DATE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    KEY idx_holiday_date (holidayDate)
+);
+
+ALTER TABLE SCHEDULE ADD COLUMN format VARCHAR(20) NOT NULL DEFAULT '2D';
+
+ALTER TABLE BOOKING_SEAT ADD COLUMN price DECIMAL(10, 2) NULL;
@@ -0,0 +1,35 @@
+-- Promo codes, their redemptions and the discounted price stored on each booking
+CREATE TABLE PROMO_CODE (
+    promoID INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(50) NOT NULL,
+    discountType VARCHAR(10) NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    minSpend DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    validFrom DATETIME NULL,
+    validUntil DATETIME NULL,
+    maxUses INT NOT NULL DEFAULT 0,
+    maxUsesPerUser INT NOT NULL DEFAULT 0,
+    usedCount INT NOT NULL DEFAULT 0,
+    movieIDs VARCHAR(1000) NULL,
+    theaterIDs VARCHAR(1000) NULL,
+    weekdays VARCHAR(50) NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    UNIQUE KEY uq_promo_code (code)
+);
+
+CREATE TABLE PROMO_REDEMPTION (
+    redemptionID BIGINT AUTO_INCREMENT PRIMARY KEY,
+    promoID INT NOT NULL,
+    userID INT NOT NULL,
+    bookingID BIGINT NOT NULL,
+    discount DECIMAL(10, 2) NOT NULL,
+    redeemedAt DATETIME NOT NULL,
+    released BOOLEAN NOT NULL DEFAULT FALSE,
+    KEY idx_promo_redemption_user (promoID, userID),
+    KEY idx_promo_redemption_booking (bookingID)
+);
+
+ALTER TABLE BOOKING
+    ADD COLUMN subtotal DECIMAL(10, 2) NULL,
+    ADD COLUMN discount DECIMAL(10, 2) NULL,
+    ADD COLUMN total DECIMAL(10, 2) NULL;
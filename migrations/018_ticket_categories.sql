@@ -0,0 +1,18 @@
+-- Ticket category of each booked seat and ticket, and when each ticket was scanned at the door
+ALTER TABLE BOOKING_SEAT ADD COLUMN category VARCHAR(20) NULL;
+
+ALTER TABLE TICKET
+    ADD COLUMN category VARCHAR(20) NULL AFTER seatID,
+    ADD COLUMN scannedAt DATETIME NULL;
+
+-- Tickets could be issued more than once for the same seat; only the first one is kept
+DELETE extra FROM TICKET extra
+    JOIN TICKET kept ON kept.bookingID = extra.bookingID AND kept.seatID = extra.seatID AND kept.ticketID < extra.ticketID;
+
+-- QR codes were made from the booking and seat numbers, so anyone could work one out. They are
+-- replaced by random codes; customers download their tickets again to get them.
+UPDATE TICKET SET qrCode = HEX(RANDOM_BYTES(10)) WHERE qrCode LIKE 'QR\_%';
+
+ALTER TABLE TICKET
+    ADD UNIQUE KEY uq_ticket_qr (qrCode),
+    ADD UNIQUE KEY uq_ticket_booking_seat (bookingID, seatID);
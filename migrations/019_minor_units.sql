@@ -0,0 +1,46 @@
+-- Money moves from DECIMAL major units to BIGINT minor units (cents), and bookings keep their fee
+-- and VAT breakdown. The API changes with the columns: amounts such as a fare of 100.00 are now
+-- sent and received as the integer 10000.
+
+UPDATE SCHEDULE SET fare = fare * 100;
+ALTER TABLE SCHEDULE MODIFY fare BIGINT NOT NULL DEFAULT 0;
+
+UPDATE TICKET SET fare = fare * 100;
+ALTER TABLE TICKET MODIFY fare BIGINT NOT NULL DEFAULT 0;
+
+UPDATE PAYMENT SET amount = amount * 100;
+ALTER TABLE PAYMENT MODIFY amount BIGINT NOT NULL DEFAULT 0;
+
+UPDATE REFUND SET amount = amount * 100;
+ALTER TABLE REFUND MODIFY amount BIGINT NOT NULL;
+
+UPDATE BOOKING_SEAT SET price = price * 100;
+ALTER TABLE BOOKING_SEAT MODIFY price BIGINT NULL;
+
+UPDATE BOOKING SET subtotal = subtotal * 100, discount = discount * 100, total = total * 100;
+ALTER TABLE BOOKING
+    MODIFY subtotal BIGINT NULL,
+    MODIFY discount BIGINT NULL,
+    MODIFY total BIGINT NULL,
+    ADD COLUMN bookingFee BIGINT NULL,
+    ADD COLUMN vatRate INT NULL,
+    ADD COLUMN tax BIGINT NULL;
+
+UPDATE PROMO_REDEMPTION SET discount = discount * 100;
+ALTER TABLE PROMO_REDEMPTION MODIFY discount BIGINT NOT NULL;
+
+-- Percentages stay as they are; only fixed amounts change unit
+UPDATE PROMO_CODE SET minSpend = minSpend * 100, amount = IF(discountType = 'FIXED', amount * 100, amount);
+ALTER TABLE PROMO_CODE
+    MODIFY amount DECIMAL(12, 2) NOT NULL,
+    MODIFY minSpend BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE PRICE_RULE MODIFY amount DECIMAL(12, 2) NOT NULL;
+UPDATE PRICE_RULE SET amount = amount * 100 WHERE adjustmentType = 'FIXED';
+
+-- VAT in basis points and a booking fee per ticket, per theater
+CREATE TABLE THEATER_TAX_RATE (
+    theaterID INT NOT NULL PRIMARY KEY,
+    vatRate INT NOT NULL DEFAULT 0,
+    bookingFee BIGINT NOT NULL DEFAULT 0
+);
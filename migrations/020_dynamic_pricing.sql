@@ -0,0 +1,7 @@
+-- Dynamic fares between a floor and a ceiling, and the base fare each booked seat was quoted at
+ALTER TABLE SCHEDULE
+    ADD COLUMN pricingMode VARCHAR(10) NOT NULL DEFAULT 'FIXED',
+    ADD COLUMN fareFloor BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN fareCeiling BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE BOOKING_SEAT ADD COLUMN baseFare BIGINT NULL;
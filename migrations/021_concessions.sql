@@ -0,0 +1,42 @@
+-- Concession catalogues per theater, and the items ordered with each booking
+CREATE TABLE CONCESSION_ITEM (
+    itemID INT AUTO_INCREMENT PRIMARY KEY,
+    theaterID INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description VARCHAR(1000) NULL,
+    price BIGINT NOT NULL,
+    stock INT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    KEY idx_concession_item_theater (theaterID)
+);
+
+CREATE TABLE CONCESSION_COMBO_ITEM (
+    comboID INT NOT NULL,
+    itemID INT NOT NULL,
+    quantity INT NOT NULL,
+    PRIMARY KEY (comboID, itemID)
+);
+
+-- One order per booking, collected with its pickup code
+CREATE TABLE CONCESSION_ORDER (
+    bookingID BIGINT NOT NULL PRIMARY KEY,
+    pickupCode VARCHAR(16) NOT NULL,
+    createdAt DATETIME NOT NULL,
+    redeemedAt DATETIME NULL,
+    redeemedBy INT NULL,
+    released BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE KEY uq_concession_order_pickup (pickupCode)
+);
+
+CREATE TABLE BOOKING_CONCESSION (
+    lineID BIGINT AUTO_INCREMENT PRIMARY KEY,
+    bookingID BIGINT NOT NULL,
+    itemID INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL,
+    unitPrice BIGINT NOT NULL,
+    total BIGINT NOT NULL,
+    KEY idx_booking_concession_booking (bookingID)
+);
+
+ALTER TABLE BOOKING ADD COLUMN concessions BIGINT NULL;
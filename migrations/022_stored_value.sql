@@ -0,0 +1,42 @@
+-- Gift cards, wallets and the ledger every stored-value debit and credit is written to
+CREATE TABLE GIFT_CARD (
+    giftCardID INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(32) NOT NULL,
+    initialBalance BIGINT NOT NULL,
+    balance BIGINT NOT NULL,
+    expiresAt DATETIME NULL,
+    status VARCHAR(10) NOT NULL,
+    issuedBy INT NOT NULL,
+    createdAt DATETIME NOT NULL,
+    UNIQUE KEY uq_gift_card_code (code)
+);
+
+-- The primary key on userID lets lockWallet create a wallet with INSERT IGNORE
+CREATE TABLE WALLET (
+    userID INT NOT NULL PRIMARY KEY,
+    balance BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE STORED_VALUE_LEDGER (
+    entryID BIGINT AUTO_INCREMENT PRIMARY KEY,
+    account VARCHAR(10) NOT NULL,
+    giftCardID INT NULL,
+    userID INT NULL,
+    amount BIGINT NOT NULL,
+    balanceAfter BIGINT NOT NULL,
+    reason VARCHAR(10) NOT NULL,
+    bookingID BIGINT NULL,
+    actorID INT NOT NULL,
+    note VARCHAR(255) NULL,
+    createdAt DATETIME NOT NULL,
+    KEY idx_stored_value_ledger_gift_card (giftCardID),
+    KEY idx_stored_value_ledger_user (userID),
+    KEY idx_stored_value_ledger_booking (bookingID)
+);
+
+-- Earlier payments were all taken by card
+ALTER TABLE PAYMENT
+    ADD COLUMN method VARCHAR(10) NOT NULL DEFAULT 'CARD',
+    ADD COLUMN giftCardID INT NULL;
+
+ALTER TABLE REFUND ADD COLUMN storedValue BIGINT NOT NULL DEFAULT 0 AFTER amount;
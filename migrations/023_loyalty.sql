@@ -0,0 +1,29 @@
+-- Loyalty balances, their ledger and per-movie or per-theater earn rates
+-- The primary key on userID lets addPoints open an account with INSERT IGNORE
+CREATE TABLE LOYALTY_ACCOUNT (
+    userID INT NOT NULL PRIMARY KEY,
+    balance INT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE LOYALTY_LEDGER (
+    entryID BIGINT AUTO_INCREMENT PRIMARY KEY,
+    userID INT NOT NULL,
+    points INT NOT NULL,
+    balanceAfter INT NOT NULL,
+    reason VARCHAR(10) NOT NULL,
+    bookingID BIGINT NULL,
+    createdAt DATETIME NOT NULL,
+    KEY idx_loyalty_ledger_user (userID),
+    KEY idx_loyalty_ledger_booking (bookingID)
+);
+
+CREATE TABLE LOYALTY_EARN_RATE (
+    rateID INT AUTO_INCREMENT PRIMARY KEY,
+    movieID INT NULL,
+    theaterID INT NULL,
+    pointsPerUnit INT NOT NULL,
+    KEY idx_loyalty_earn_rate_movie (movieID),
+    KEY idx_loyalty_earn_rate_theater (theaterID)
+);
+
+ALTER TABLE BOOKING ADD COLUMN pointsDiscount BIGINT NULL;
@@ -0,0 +1,52 @@
+-- Membership plans, members' monthly periods, their charges and the bookings that used them
+CREATE TABLE MEMBERSHIP_PLAN (
+    planID INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description VARCHAR(1000) NULL,
+    price BIGINT NOT NULL,
+    filmsPerMonth INT NOT NULL DEFAULT 0,
+    discountPercent DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    freeBookingFee BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE MEMBERSHIP (
+    membershipID INT AUTO_INCREMENT PRIMARY KEY,
+    userID INT NOT NULL,
+    planID INT NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    autoRenew BOOLEAN NOT NULL DEFAULT TRUE,
+    periodStart DATETIME NOT NULL,
+    periodEnd DATETIME NOT NULL,
+    filmsUsed INT NOT NULL DEFAULT 0,
+    cancelledAt DATETIME NULL,
+    createdAt DATETIME NOT NULL,
+    KEY idx_membership_user (userID),
+    KEY idx_membership_renewal (status, periodEnd)
+);
+
+CREATE TABLE MEMBERSHIP_CHARGE (
+    chargeID BIGINT AUTO_INCREMENT PRIMARY KEY,
+    membershipID INT NOT NULL,
+    amount BIGINT NOT NULL,
+    periodStart DATETIME NOT NULL,
+    periodEnd DATETIME NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    reference VARCHAR(255) NULL,
+    failureReason VARCHAR(255) NULL,
+    createdAt DATETIME NOT NULL,
+    KEY idx_membership_charge_membership (membershipID)
+);
+
+-- A booking uses a membership at most once
+CREATE TABLE MEMBERSHIP_USAGE (
+    membershipID INT NOT NULL,
+    bookingID BIGINT NOT NULL PRIMARY KEY,
+    films INT NOT NULL,
+    discount BIGINT NOT NULL,
+    periodEnd DATETIME NOT NULL,
+    released BOOLEAN NOT NULL DEFAULT FALSE,
+    KEY idx_membership_usage_membership (membershipID)
+);
+
+ALTER TABLE BOOKING ADD COLUMN membershipDiscount BIGINT NULL;
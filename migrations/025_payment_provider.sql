@@ -0,0 +1,7 @@
+-- The provider's reference of card payments. It is unique, so a callback settles one payment only.
+-- startedAt marks card payments recorded before the provider is asked for them; the reference is
+-- filled in once the provider answers.
+ALTER TABLE PAYMENT
+    ADD COLUMN providerRef VARCHAR(255) NULL,
+    ADD COLUMN startedAt DATETIME NULL,
+    ADD UNIQUE KEY uq_payment_provider_ref (providerRef);
@@ -0,0 +1,98 @@
+// Package migrations holds the database schema as numbered SQL files, applied in order.
+// Each file runs once; the versions applied are recorded in SCHEMA_MIGRATION.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Apply runs every migration the database has not seen yet, oldest first
+func Apply(db *sql.DB) error {
+	_, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS SCHEMA_MIGRATION (
+            version VARCHAR(255) NOT NULL PRIMARY KEY,
+            appliedAt DATETIME NOT NULL
+        )
+    `)
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var applied bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM SCHEMA_MIGRATION WHERE version = ?)", name).Scan(&applied); err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		script, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := applyFile(db, name, string(script)); err != nil {
+			return err
+		}
+		log.Printf("Applied migration %s", name)
+	}
+	return nil
+}
+
+// applyFile runs one migration on a single connection, so that session variables set by one of
+// its statements are seen by the next, and records it as applied
+func applyFile(db *sql.DB, name, script string) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// MySQL commits DDL as it goes, so a failed file has to be fixed by hand before it is rerun
+	for _, statement := range statements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+	}
+	_, err = conn.ExecContext(ctx, "INSERT INTO SCHEMA_MIGRATION (version, appliedAt) VALUES (?, ?)", name, time.Now())
+	return err
+}
+
+// statements splits a script on the semicolons that end its lines, dropping comment lines
+func statements(script string) []string {
+	var result []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			result = append(result, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		result = append(result, rest)
+	}
+	return result
+}
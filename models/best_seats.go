@@ -0,0 +1,151 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"my-app/config"
+	"sort"
+)
+
+// ErrNoContiguousSeats is returned when no row has enough free seats side by side
+var ErrNoContiguousSeats = errors.New("no block of adjacent seats is available for the party size")
+
+// bestSeatAttempts bounds how often HoldBestSeats retries when another customer wins the picked seats
+const bestSeatAttempts = 3
+
+// FindBestSeats picks partySize adjacent free seats in one row of a schedule, as close as
+// possible to the ideal viewing row and to the centre of the screen
+func FindBestSeats(scheduleID, partySize int) ([]int, error) {
+	blocks, err := findSeatBlocks(scheduleID, partySize)
+	if err != nil {
+		return nil, err
+	}
+	return blocks[0], nil
+}
+
+// findSeatBlocks returns every block of partySize adjacent free seats of a schedule, best first
+func findSeatBlocks(scheduleID, partySize int) ([][]int, error) {
+	if partySize <= 0 {
+		return nil, errors.New("party size must be positive")
+	}
+
+	seats, err := GetSeatsByScheduleID(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if len(seats) == 0 {
+		return nil, errors.New("schedule not found or has no seats")
+	}
+
+	blocks := rankSeatBlocks(seats, partySize, config.IdealRowPercent)
+	if len(blocks) == 0 {
+		return nil, ErrNoContiguousSeats
+	}
+	return blocks, nil
+}
+
+// HoldBestSeats finds and holds the best block of seats for a party. A block that would strand a
+// single seat where the theater enforces the gap rule makes way for the next best one.
+func HoldBestSeats(userID, scheduleID, partySize int, options CheckoutOptions) (*SeatHold, error) {
+	var err error
+	for attempt := 0; attempt < bestSeatAttempts; attempt++ {
+		var blocks [][]int
+		blocks, err = findSeatBlocks(scheduleID, partySize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, seatIDs := range blocks {
+			var hold *SeatHold
+			hold, err = HoldSeats(userID, scheduleID, seatIDs, options)
+			if errors.Is(err, ErrSeatGap) {
+				continue
+			}
+			if !errors.Is(err, ErrSeatsUnavailable) {
+				return hold, err
+			}
+			break // another customer won some of the seats; look again
+		}
+		if errors.Is(err, ErrSeatGap) {
+			return nil, err // every block would strand a seat
+		}
+	}
+	return nil, err
+}
+
+// rankSeatBlocks scores every run of partySize free seats that sit next to each other on the grid
+// and returns them best first. Accessible seats are left for customers who ask for them explicitly.
+func rankSeatBlocks(seats []ScheduleSeat, partySize, idealRowPercent int) [][]int {
+	// Seats created before layouts existed have no position; treat them as one row by seat number
+	if !hasSeatPositions(seats) {
+		sort.Slice(seats, func(i, j int) bool { return seats[i].SeatNumber < seats[j].SeatNumber })
+		for i := range seats {
+			seats[i].PosX = i
+		}
+	}
+
+	rows := make(map[int][]ScheduleSeat)
+	maxX, maxY := 0, 0
+	for _, seat := range seats {
+		rows[seat.PosY] = append(rows[seat.PosY], seat)
+		maxX = max(maxX, seat.PosX)
+		maxY = max(maxY, seat.PosY)
+	}
+	centreX := float64(maxX) / 2
+	idealRow := math.Round(float64(maxY) * float64(idealRowPercent) / 100)
+
+	rowOrder := make([]int, 0, len(rows))
+	for posY := range rows {
+		rowOrder = append(rowOrder, posY)
+	}
+	sort.Ints(rowOrder)
+
+	type candidate struct {
+		ids   []int
+		score float64
+	}
+	var candidates []candidate
+	for _, posY := range rowOrder {
+		row := rows[posY]
+		sort.Slice(row, func(i, j int) bool { return row[i].PosX < row[j].PosX })
+
+		for start := 0; start+partySize <= len(row); start++ {
+			block := row[start : start+partySize]
+			if !freeAndAdjacent(block) {
+				continue
+			}
+
+			ids := make([]int, 0, partySize)
+			for _, seat := range block {
+				ids = append(ids, seat.SeatID)
+			}
+
+			blockCentre := float64(block[0].PosX+block[len(block)-1].PosX) / 2
+			// A row away from the ideal costs more than a seat away from the centre,
+			// and stranding a single empty seat costs more than either
+			score := 2*math.Abs(float64(posY)-idealRow) + math.Abs(blockCentre-centreX)
+			score += 10 * float64(len(findOrphanSeats(seats, ids)))
+			candidates = append(candidates, candidate{ids: ids, score: score})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score < candidates[j].score })
+	blocks := make([][]int, len(candidates))
+	for i, c := range candidates {
+		blocks[i] = c.ids
+	}
+	return blocks
+}
+
+// freeAndAdjacent reports whether a run of seats is sellable and has no gap or aisle inside it
+func freeAndAdjacent(block []ScheduleSeat) bool {
+	for i, seat := range block {
+		if seat.Status != SeatStatusAvailable || seat.SeatType == SeatTypeWheelchair || seat.SeatType == SeatTypeCompanion {
+			return false
+		}
+		if i > 0 && seat.PosX != block[i-1].PosX+1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"my-app/config"
+	"testing"
+	"time"
+)
+
+// The ideal row has three free seats, so a pair there strands the third one. Where the theater
+// enforces the gap rule the party gets the pair in the back row instead.
+func TestHoldBestSeatsSkipsBlockLeavingSingleSeat(t *testing.T) {
+	requireRedis(t)
+	previous := config.IdealRowPercent
+	config.IdealRowPercent = 100
+	t.Cleanup(func() { config.IdealRowPercent = previous })
+
+	theaterID := insertTestRow(t, "INSERT INTO THEATER (name, location, seatGapPolicy) VALUES ('Test theater', '', ?)", SeatGapPolicyEnforce)
+	roomID := insertTestRow(t, "INSERT INTO ROOM (theaterID, roomNumber) VALUES (?, 1)", theaterID)
+	screenID := insertTestRow(t, "INSERT INTO SCREEN (roomID, screenNumber) VALUES (?, 1)", roomID)
+	scheduleID := insertTestRow(t, "INSERT INTO SCHEDULE (movieID, screenID, showTime, availableSeats) VALUES (0, ?, ?, 5)",
+		screenID, time.Now().AddDate(0, 0, 1))
+
+	seat := func(row string, column, posX, posY int) int {
+		seatID := insertTestRow(t, "INSERT INTO SEAT (screenID, seatNumber, rowLabel, columnNumber, posX, posY) VALUES (?, ?, ?, ?, ?, ?)",
+			screenID, posY*10+column, row, column, posX, posY)
+		insertTestRow(t, "INSERT INTO SCHEDULE_SEAT (scheduleID, seatID, status) VALUES (?, ?, ?)", scheduleID, seatID, SeatStatusAvailable)
+		return seatID
+	}
+	backLeft, backRight := seat("A", 1, 0, 0), seat("A", 2, 1, 0)
+	for column := 1; column <= 3; column++ {
+		seat("G", column, column-1, 6)
+	}
+
+	best, err := FindBestSeats(scheduleID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckSeatGaps(scheduleID, best); !errors.Is(err, ErrSeatGap) {
+		t.Fatalf("the best block %v should strand a seat, got %v", best, err)
+	}
+
+	hold, err := HoldBestSeats(createTestUser(t), scheduleID, 2, CheckoutOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ReleaseHold(hold.UserID, hold.HoldID)
+	if len(hold.SeatIDs) != 2 || hold.SeatIDs[0] != backLeft || hold.SeatIDs[1] != backRight {
+		t.Errorf("held %v, want the back row pair %v", hold.SeatIDs, []int{backLeft, backRight})
+	}
+}
@@ -4,66 +4,243 @@ import (
 	"database/sql"
 	"errors"
 	"my-app/config"
+	"sort"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 // BookingDetails struct to represent booking data
 type BookingDetails struct {
 	BookingID   int64     `json:"booking_id"`
 	UserID      int       `json:"user_id"`
+	ScheduleID  int       `json:"schedule_id"`
 	MovieID     int       `json:"movie_id"`
 	ScreenID    int       `json:"screen_id"`
 	BookingDate time.Time `json:"booking_date"`
 	SeatsBooked int       `json:"seats_booked"`
+	Status      string    `json:"status"`
 	SeatIDs     []int     `json:"seat_ids"`
+	PriceBreakdown
+}
+
+// bookingAmountColumns selects a booking's PriceBreakdown in the order expected by amountFields
+const bookingAmountColumns = "COALESCE(subtotal, 0), COALESCE(membershipDiscount, 0), COALESCE(discount, 0), COALESCE(pointsDiscount, 0), COALESCE(concessions, 0), COALESCE(bookingFee, 0), COALESCE(vatRate, 0), COALESCE(tax, 0), COALESCE(total, 0)"
+
+// amountFields returns scan destinations for bookingAmountColumns
+func (b *PriceBreakdown) amountFields() []interface{} {
+	return []interface{}{&b.Subtotal, &b.MembershipDiscount, &b.Discount, &b.PointsDiscount, &b.Concessions, &b.BookingFee, &b.VATRate, &b.Tax, &b.Total}
+}
+
+// CheckoutOptions are the customer's choices carried from the seat hold to the booking
+type CheckoutOptions struct {
+	PromoCode    string           `json:"promo_code,omitempty"`
+	Categories   map[int]string   `json:"categories,omitempty"` // Ticket category by seat ID; ADULT when missing
+	Concessions  []ConcessionLine `json:"concessions,omitempty"`
+	RedeemPoints int              `json:"redeem_points,omitempty"` // Loyalty points to spend on the tickets
+	FreeTickets  int              `json:"free_tickets,omitempty"`  // Tickets to take for config.LoyaltyFreeTicketPoints each
 }
 
+func (options CheckoutOptions) redeemsPoints() bool {
+	return options.RedeemPoints != 0 || options.FreeTickets != 0
+}
+
+// BookingSeat is one seat of a booking with the category and price it was sold at
+type BookingSeat struct {
+	SeatID   int    `json:"seat_id"`
+	Category string `json:"category"`
+	BaseFare Money  `json:"base_fare"` // The schedule fare when sold, which moves under dynamic pricing
+	Price    Money  `json:"price"`
+}
+
+// ErrSeatsUnavailable is returned when a requested seat is held, booked or blocked.
+// Callers report it as a "seat taken" conflict.
+var ErrSeatsUnavailable = errors.New("seat taken: one or more seats are no longer available")
+
 // BookSeats books seats for a user and a specific movie schedule
 func BookSeats(userID, scheduleID int, seatIDs []int) (int64, error) {
+	if _, err := CheckSeatGaps(scheduleID, seatIDs); err != nil {
+		return 0, err
+	}
+
+	// Seats held by another customer are not for sale
+	held, err := heldSeatIDs(scheduleID, seatIDs)
+	if err != nil {
+		return 0, err
+	}
+	if len(held) > 0 {
+		return 0, ErrSeatsUnavailable
+	}
+
 	tx, err := config.DB.Begin()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
+
+	bookingID, err := bookSeats(tx, userID, scheduleID, seatIDs, CheckoutOptions{}, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isLockConflict(err) {
+			return 0, ErrSeatsUnavailable
+		}
+		return 0, err
+	}
+
+	publishSeatChanges(scheduleID, seatIDs, SeatStatusBooked)
+	return bookingID, nil
+}
+
+// bookSeats performs the booking inside the caller's transaction.
+// The seat rows are locked so that concurrent bookings of the same seat cannot both succeed.
+// A quote given to the customer with their seat hold is honoured; without one the seats are priced now.
+func bookSeats(tx *sql.Tx, userID, scheduleID int, seatIDs []int, options CheckoutOptions, quoted *PriceQuote) (int64, error) {
+	bookingID, err := lockAndBookSeats(tx, userID, scheduleID, seatIDs, options, quoted)
+	if isLockConflict(err) {
+		return 0, ErrSeatsUnavailable
+	}
+	return bookingID, err
+}
+
+func lockAndBookSeats(tx *sql.Tx, userID, scheduleID int, seatIDs []int, options CheckoutOptions, quoted *PriceQuote) (int64, error) {
+	if len(seatIDs) == 0 {
+		return 0, errors.New("no seats selected")
+	}
+
+	// Lock the user's row so that concurrent bookings by the same user are counted one after another
+	var lockedUserID int
+	if err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&lockedUserID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("user not found")
+		}
+		return 0, err
+	}
+	if err := checkPurchaseLimits(tx, userID, scheduleID, len(seatIDs), nil); err != nil {
+		return 0, err
+	}
 
-	// Step 1: Check seat availability
-	for _, seatID := range seatIDs {
-		var isBooked bool
-		err := tx.QueryRow("SELECT isBooked FROM SEAT WHERE seatID = ? AND screenID IN (SELECT screenID FROM SCHEDULE WHERE scheduleID = ?)", seatID, scheduleID).Scan(&isBooked)
+	// Schedules created before the seat inventory existed get their rows on first booking
+	var inventory int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM SCHEDULE_SEAT WHERE scheduleID = ?", scheduleID).Scan(&inventory); err != nil {
+		return 0, err
+	}
+	if inventory == 0 {
+		if err := syncScheduleSeats(tx, scheduleID); err != nil {
+			return 0, err
+		}
+	}
+
+	// Step 1: Lock the seats in a fixed order to avoid deadlocks, then check availability
+	locked := append([]int(nil), seatIDs...)
+	sort.Ints(locked)
+	for _, seatID := range locked {
+		var status string
+		err := tx.QueryRow("SELECT status FROM SCHEDULE_SEAT WHERE scheduleID = ? AND seatID = ? FOR UPDATE", scheduleID, seatID).Scan(&status)
+		if err == sql.ErrNoRows {
+			return 0, errors.New("one or more seats do not belong to this schedule")
+		}
+		if err != nil {
+			return 0, err
+		}
+		if status != SeatStatusAvailable {
+			return 0, ErrSeatsUnavailable
+		}
+	}
+
+	// Step 2: Price each seat and concession in force now, or as quoted for the hold, and lock in any membership, promo code and loyalty points
+	quote, pc, err := priceSeats(tx, scheduleID, seatIDs, options.Categories)
+	if err != nil {
+		return 0, err
+	}
+	if err := quote.addConcessions(tx, pc.theaterID, options.Concessions); err != nil {
+		return 0, err
+	}
+	quote.honour(quoted)
+	membership, err := currentMembership(tx, userID, true)
+	if err != nil {
+		return 0, err
+	}
+	quote.applyMembership(membership)
+	var promo *PromoCode
+	if options.PromoCode != "" {
+		promo, err = lockPromo(tx, options.PromoCode, userID)
+		if err != nil {
+			return 0, err
+		}
+		discount, err := promo.discountFor(pc, quote.ticketsDue(), time.Now())
+		if err != nil {
+			return 0, err
+		}
+		quote.applyDiscount(promo.Code, discount)
+	}
+	if options.redeemsPoints() {
+		balance, err := pointsBalance(tx, userID, true)
 		if err != nil {
-			tx.Rollback()
 			return 0, err
 		}
-		if isBooked {
-			tx.Rollback()
-			return 0, errors.New("one or more seats are already booked")
+		if err := quote.applyPoints(options, balance); err != nil {
+			return 0, err
 		}
 	}
 
-	// Step 2: Insert the booking record
-	result, err := tx.Exec("INSERT INTO BOOKING (userID, movieID, screenID, bookingDate, seatsBooked) SELECT ?, movieID, screenID, ?, ? FROM SCHEDULE WHERE scheduleID = ?", userID, time.Now(), len(seatIDs), scheduleID)
+	// Step 3: Insert the booking record
+	now := time.Now()
+	result, err := tx.Exec(
+		"INSERT INTO BOOKING (userID, scheduleID, movieID, screenID, bookingDate, seatsBooked, status, expiresAt, subtotal, membershipDiscount, discount, pointsDiscount, concessions, bookingFee, vatRate, tax, total) SELECT ?, scheduleID, movieID, screenID, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? FROM SCHEDULE WHERE scheduleID = ?",
+		userID, now, len(seatIDs), BookingStatusPending, now.Add(config.PaymentWindow),
+		quote.Subtotal, quote.MembershipDiscount, quote.Discount, quote.PointsDiscount, quote.Concessions, quote.BookingFee, quote.VATRate, quote.Tax, quote.Total, scheduleID,
+	)
 	if err != nil {
-		tx.Rollback()
 		return 0, err
 	}
 
 	bookingID, err := result.LastInsertId()
 	if err != nil {
-		tx.Rollback()
+		return 0, err
+	}
+	if err := recordBookingStatus(tx, bookingID, "", BookingStatusPending, Actor{UserID: userID, Role: "user"}); err != nil {
+		return 0, err
+	}
+	if promo != nil {
+		if err := redeemPromo(tx, promo, userID, bookingID, quote.Discount); err != nil {
+			return 0, err
+		}
+	}
+	if err := useMembership(tx, quote, bookingID); err != nil {
+		return 0, err
+	}
+	if err := addPoints(tx, userID, -quote.PointsRedeemed, PointsRedeem, bookingID); err != nil {
+		return 0, err
+	}
+	if err := reserveConcessions(tx, bookingID, quote.ConcessionItems); err != nil {
 		return 0, err
 	}
 
-	// Step 3: Mark the seats as booked
-	for _, seatID := range seatIDs {
-		_, err := tx.Exec("UPDATE SEAT SET isBooked = true WHERE seatID = ?", seatID)
+	// Step 4: Mark the seats as booked for this showtime only and record the fare and price each sold at
+	for i, seatID := range seatIDs {
+		result, err := tx.Exec("UPDATE SCHEDULE_SEAT SET status = ? WHERE scheduleID = ? AND seatID = ? AND status = ?", SeatStatusBooked, scheduleID, seatID, SeatStatusAvailable)
+		if err != nil {
+			return 0, err
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if updated != 1 {
+			return 0, ErrSeatsUnavailable
+		}
+		_, err = tx.Exec(
+			"INSERT INTO BOOKING_SEAT (bookingID, seatID, category, baseFare, price) VALUES (?, ?, ?, ?, ?)",
+			bookingID, seatID, quote.Seats[i].Category, quote.Seats[i].BaseFare, quote.Seats[i].Price,
+		)
 		if err != nil {
-			tx.Rollback()
 			return 0, err
 		}
 	}
-
-	// Step 4: Commit the transaction
-	err = tx.Commit()
-	if err != nil {
+	if err := refreshAvailableSeats(tx, scheduleID); err != nil {
 		return 0, err
 	}
 
@@ -75,7 +252,7 @@ func BookSeats(userID, scheduleID int, seatIDs []int) (int64, error) {
 func GetBookingDetailsByUserID(userID int) ([]BookingDetails, error) {
 	var bookings []BookingDetails
 
-	rows, err := config.DB.Query("SELECT bookingID, movieID, screenID, bookingDate, seatsBooked FROM BOOKING WHERE userID = ?", userID)
+	rows, err := config.DB.Query("SELECT bookingID, COALESCE(scheduleID, 0), movieID, screenID, bookingDate, seatsBooked, COALESCE(status, ?), "+bookingAmountColumns+" FROM BOOKING WHERE userID = ?", BookingStatusPending, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +263,8 @@ func GetBookingDetailsByUserID(userID int) ([]BookingDetails, error) {
 		var bookingDate sql.NullString // use NullString to handle NULLs gracefully
 
 		booking.UserID = userID
-		if err := rows.Scan(&booking.BookingID, &booking.MovieID, &booking.ScreenID, &bookingDate, &booking.SeatsBooked); err != nil {
+		dest := []interface{}{&booking.BookingID, &booking.ScheduleID, &booking.MovieID, &booking.ScreenID, &bookingDate, &booking.SeatsBooked, &booking.Status}
+		if err := rows.Scan(append(dest, booking.amountFields()...)...); err != nil {
 			return nil, err
 		}
 
@@ -98,20 +276,11 @@ func GetBookingDetailsByUserID(userID int) ([]BookingDetails, error) {
 			}
 		}
 
-		// Query booked seat IDs for each booking
-		seatRows, err := config.DB.Query("SELECT seatID FROM SEAT WHERE isBooked = true AND screenID = ?", booking.ScreenID)
+		// Query the seats taken by this booking
+		booking.SeatIDs, err = GetBookingSeatIDs(booking.BookingID)
 		if err != nil {
 			return nil, err
 		}
-		defer seatRows.Close()
-
-		for seatRows.Next() {
-			var seatID int
-			if err := seatRows.Scan(&seatID); err != nil {
-				return nil, err
-			}
-			booking.SeatIDs = append(booking.SeatIDs, seatID)
-		}
 
 		bookings = append(bookings, booking)
 	}
@@ -127,7 +296,7 @@ func GetBookingDetailsByUserID(userID int) ([]BookingDetails, error) {
 func GetAllBookings() ([]BookingDetails, error) {
 	var bookings []BookingDetails
 
-	rows, err := config.DB.Query("SELECT bookingID, userID, movieID, screenID, bookingDate, seatsBooked FROM BOOKING")
+	rows, err := config.DB.Query("SELECT bookingID, userID, COALESCE(scheduleID, 0), movieID, screenID, bookingDate, seatsBooked, COALESCE(status, ?), "+bookingAmountColumns+" FROM BOOKING", BookingStatusPending)
 	if err != nil {
 		return nil, err
 	}
@@ -137,7 +306,8 @@ func GetAllBookings() ([]BookingDetails, error) {
 		var booking BookingDetails
 		var bookingDate sql.NullString // handle NULLs gracefully
 
-		if err := rows.Scan(&booking.BookingID, &booking.UserID, &booking.MovieID, &booking.ScreenID, &bookingDate, &booking.SeatsBooked); err != nil {
+		dest := []interface{}{&booking.BookingID, &booking.UserID, &booking.ScheduleID, &booking.MovieID, &booking.ScreenID, &bookingDate, &booking.SeatsBooked, &booking.Status}
+		if err := rows.Scan(append(dest, booking.amountFields()...)...); err != nil {
 			return nil, err
 		}
 
@@ -149,20 +319,11 @@ func GetAllBookings() ([]BookingDetails, error) {
 			}
 		}
 
-		// Query booked seat IDs for each booking
-		seatRows, err := config.DB.Query("SELECT seatID FROM SEAT WHERE isBooked = true AND screenID = ?", booking.ScreenID)
+		// Query the seats taken by this booking
+		booking.SeatIDs, err = GetBookingSeatIDs(booking.BookingID)
 		if err != nil {
 			return nil, err
 		}
-		defer seatRows.Close()
-
-		for seatRows.Next() {
-			var seatID int
-			if err := seatRows.Scan(&seatID); err != nil {
-				return nil, err
-			}
-			booking.SeatIDs = append(booking.SeatIDs, seatID)
-		}
 
 		bookings = append(bookings, booking)
 	}
@@ -174,20 +335,148 @@ func GetAllBookings() ([]BookingDetails, error) {
 	return bookings, nil
 }
 
-// DeleteBooking deletes a booking by bookingID
-func DeleteBooking(bookingID int64) error {
-	result, err := config.DB.Exec("DELETE FROM BOOKING WHERE bookingID = ?", bookingID)
+// GetBookingDetails retrieves one booking with its seats
+func GetBookingDetails(bookingID int64) (*BookingDetails, error) {
+	var booking BookingDetails
+	var bookingDate sql.NullString
+	dest := []interface{}{&booking.BookingID, &booking.UserID, &booking.ScheduleID, &booking.MovieID, &booking.ScreenID, &bookingDate, &booking.SeatsBooked, &booking.Status}
+	err := config.DB.QueryRow("SELECT bookingID, userID, COALESCE(scheduleID, 0), movieID, screenID, bookingDate, seatsBooked, COALESCE(status, ?), "+bookingAmountColumns+" FROM BOOKING WHERE bookingID = ?", BookingStatusPending, bookingID).
+		Scan(append(dest, booking.amountFields()...)...)
+	if err == sql.ErrNoRows {
+		return nil, ErrBookingNotFound
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if bookingDate.Valid {
+		booking.BookingDate, err = time.Parse("2006-01-02 15:04:05", bookingDate.String)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	booking.SeatIDs, err = GetBookingSeatIDs(booking.BookingID)
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// GetBookingSeatIDs retrieves the seats taken by one booking
+func GetBookingSeatIDs(bookingID int64) ([]int, error) {
+	rows, err := config.DB.Query("SELECT seatID FROM BOOKING_SEAT WHERE bookingID = ? ORDER BY seatID", bookingID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seatIDs []int
+	for rows.Next() {
+		var seatID int
+		if err := rows.Scan(&seatID); err != nil {
+			return nil, err
+		}
+		seatIDs = append(seatIDs, seatID)
 	}
-	if rowsAffected == 0 {
-		return errors.New("no booking found with the given ID")
+
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return seatIDs, nil
+}
+
+// GetBookingSeats retrieves the seats of a booking with the category and price each was sold at.
+// Bookings made before seats were priced individually fall back to the schedule fare.
+func GetBookingSeats(bookingID int64) ([]BookingSeat, error) {
+	rows, err := config.DB.Query(`
+        SELECT bs.seatID, COALESCE(bs.category, ?), COALESCE(bs.baseFare, s.fare, 0), COALESCE(bs.price, s.fare, 0)
+        FROM BOOKING_SEAT bs
+        JOIN BOOKING b ON b.bookingID = bs.bookingID
+        LEFT JOIN SCHEDULE s ON s.scheduleID = b.scheduleID
+        WHERE bs.bookingID = ?
+        ORDER BY bs.seatID
+    `, TicketCategoryAdult, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seats []BookingSeat
+	for rows.Next() {
+		var seat BookingSeat
+		if err := rows.Scan(&seat.SeatID, &seat.Category, &seat.BaseFare, &seat.Price); err != nil {
+			return nil, err
+		}
+		seats = append(seats, seat)
+	}
+	return seats, rows.Err()
+}
+
+// seatRelease remembers seats freed inside a transaction so they can be announced after commit
+type seatRelease struct {
+	scheduleID int
+	seatIDs    []int
+}
+
+func (release *seatRelease) publish() {
+	if release != nil {
+		publishSeatChanges(release.scheduleID, release.seatIDs, SeatStatusAvailable)
+	}
+}
+
+// releaseBookingSeats puts a booking's seats back on sale for its schedule
+func releaseBookingSeats(tx *sql.Tx, bookingID int64) (*seatRelease, error) {
+	var scheduleID sql.NullInt64
+	if err := tx.QueryRow("SELECT scheduleID FROM BOOKING WHERE bookingID = ?", bookingID).Scan(&scheduleID); err != nil {
+		return nil, err
+	}
+	if !scheduleID.Valid {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`
+        SELECT ss.seatID
+        FROM SCHEDULE_SEAT ss
+        JOIN BOOKING_SEAT bs ON bs.seatID = ss.seatID
+        WHERE bs.bookingID = ? AND ss.scheduleID = ? AND ss.status = ?
+        FOR UPDATE
+    `, bookingID, scheduleID.Int64, SeatStatusBooked)
+	if err != nil {
+		return nil, err
+	}
+	release := &seatRelease{scheduleID: int(scheduleID.Int64)}
+	for rows.Next() {
+		var seatID int
+		if err := rows.Scan(&seatID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		release.seatIDs = append(release.seatIDs, seatID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, seatID := range release.seatIDs {
+		_, err := tx.Exec("UPDATE SCHEDULE_SEAT SET status = ? WHERE scheduleID = ? AND seatID = ?", SeatStatusAvailable, release.scheduleID, seatID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := refreshAvailableSeats(tx, release.scheduleID); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// isLockConflict reports whether MySQL aborted a statement because another transaction held the row
+func isLockConflict(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205 // deadlock, lock wait timeout
+	}
+	return false
 }
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"my-app/config"
+	"time"
+)
+
+// expiryBatchSize caps how many bookings one sweep expires, so a backlog is worked off gradually
+const expiryBatchSize = 200
+
+// ExpireOverdueBookings expires PENDING bookings whose payment deadline has passed and puts
+// their seats back on sale. It returns the number of bookings expired and seats released.
+func ExpireOverdueBookings(now time.Time) (int, int, error) {
+	rows, err := config.DB.Query(
+		"SELECT bookingID FROM BOOKING WHERE status = ? AND expiresAt IS NOT NULL AND expiresAt < ? ORDER BY expiresAt LIMIT ?",
+		BookingStatusPending, now, expiryBatchSize,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	var bookingIDs []int64
+	for rows.Next() {
+		var bookingID int64
+		if err := rows.Scan(&bookingID); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		bookingIDs = append(bookingIDs, bookingID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	bookings, seats := 0, 0
+	for _, bookingID := range bookingIDs {
+		released, err := expireBooking(bookingID)
+		if errors.Is(err, ErrInvalidTransition) {
+			continue // Paid or cancelled since it was listed
+		}
+		if err != nil {
+			return bookings, seats, err
+		}
+		bookings++
+		seats += released
+	}
+	return bookings, seats, nil
+}
+
+// expireBooking moves one booking to EXPIRED and returns how many seats it freed
+func expireBooking(bookingID int64) (int, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	release, err := transitionBooking(tx, bookingID, BookingStatusExpired, SystemActor)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	release.publish()
+	if release == nil {
+		return 0, nil
+	}
+	return len(release.seatIDs), nil
+}
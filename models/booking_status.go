@@ -0,0 +1,178 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+	"time"
+)
+
+// Booking lifecycle statuses
+const (
+	BookingStatusPending   = "PENDING"
+	BookingStatusConfirmed = "CONFIRMED"
+	BookingStatusCheckedIn = "CHECKED_IN"
+	BookingStatusCancelled = "CANCELLED"
+	BookingStatusExpired   = "EXPIRED"
+	BookingStatusRefunded  = "REFUNDED"
+)
+
+// bookingTransitions lists the statuses each status may move to
+var bookingTransitions = map[string][]string{
+	BookingStatusPending:   {BookingStatusConfirmed, BookingStatusCancelled, BookingStatusExpired},
+	BookingStatusConfirmed: {BookingStatusCheckedIn, BookingStatusCancelled, BookingStatusRefunded},
+	BookingStatusCancelled: {BookingStatusRefunded},
+}
+
+var (
+	ErrBookingNotFound   = errors.New("no booking found with the given ID")
+	ErrBookingNotOwned   = errors.New("booking does not belong to the user")
+	ErrInvalidTransition = errors.New("invalid booking status transition")
+)
+
+// Actor identifies who caused a booking status change
+type Actor struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"` // user, admin or system
+}
+
+// SystemActor is used for status changes made by the server itself
+var SystemActor = Actor{Role: "system"}
+
+// BookingStatusChange is one entry of a booking's status history
+type BookingStatusChange struct {
+	BookingID  int64     `json:"booking_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    int       `json:"actor_id"`
+	ActorRole  string    `json:"actor_role"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// CanTransition reports whether a booking may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, next := range bookingTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionBooking moves a booking to a new status. Users may only act on their own bookings.
+func TransitionBooking(bookingID int64, to string, actor Actor) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	release, err := transitionBooking(tx, bookingID, to, actor)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	release.publish()
+	return nil
+}
+
+// transitionBooking is the only place a booking's status is changed.
+// It records the change, earns or gives back loyalty points, and frees the seats of bookings that no longer hold them;
+// the freed seats are returned so they can be announced once the transaction commits.
+func transitionBooking(tx *sql.Tx, bookingID int64, to string, actor Actor) (*seatRelease, error) {
+	var from string
+	var ownerID int
+	err := tx.QueryRow("SELECT COALESCE(status, ?), userID FROM BOOKING WHERE bookingID = ? FOR UPDATE", BookingStatusPending, bookingID).Scan(&from, &ownerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrBookingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if (actor.Role == "user" || actor.Role == RoleGuest) && actor.UserID != ownerID {
+		return nil, ErrBookingNotOwned
+	}
+	if !CanTransition(from, to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	if _, err := tx.Exec("UPDATE BOOKING SET status = ? WHERE bookingID = ?", to, bookingID); err != nil {
+		return nil, err
+	}
+	if err := recordBookingStatus(tx, bookingID, from, to, actor); err != nil {
+		return nil, err
+	}
+
+	// An unpaid booking gives its promo code use back, and any part of its payment already made
+	if to == BookingStatusExpired || (to == BookingStatusCancelled && from == BookingStatusPending) {
+		if err := releasePromoRedemption(tx, bookingID); err != nil {
+			return nil, err
+		}
+		if err := reversePayments(tx, bookingID, ownerID, actor, "booking was not paid in full"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := settleBookingPoints(tx, bookingID, to); err != nil {
+		return nil, err
+	}
+
+	// Seats, uncollected concessions and included membership tickets go back unless the booking was already cancelled before the refund
+	releases := to == BookingStatusCancelled || to == BookingStatusExpired || (to == BookingStatusRefunded && from == BookingStatusConfirmed)
+	if releases {
+		if err := releaseMembershipUsage(tx, bookingID); err != nil {
+			return nil, err
+		}
+		if err := releaseConcessions(tx, bookingID); err != nil {
+			return nil, err
+		}
+		return releaseBookingSeats(tx, bookingID)
+	}
+	return nil, nil
+}
+
+func recordBookingStatus(tx *sql.Tx, bookingID int64, from, to string, actor Actor) error {
+	_, err := tx.Exec(
+		"INSERT INTO BOOKING_STATUS_HISTORY (bookingID, fromStatus, toStatus, actorID, actorRole, changedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		bookingID, from, to, actor.UserID, actor.Role, time.Now(),
+	)
+	return err
+}
+
+// GetBookingStatusHistory retrieves every status change of a booking, oldest first
+func GetBookingStatusHistory(bookingID int64) ([]BookingStatusChange, error) {
+	rows, err := config.DB.Query(
+		"SELECT bookingID, fromStatus, toStatus, actorID, actorRole, changedAt FROM BOOKING_STATUS_HISTORY WHERE bookingID = ? ORDER BY changedAt, historyID",
+		bookingID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []BookingStatusChange
+	for rows.Next() {
+		var change BookingStatusChange
+		var changedAt string
+		if err := rows.Scan(&change.BookingID, &change.FromStatus, &change.ToStatus, &change.ActorID, &change.ActorRole, &changedAt); err != nil {
+			return nil, err
+		}
+		change.ChangedAt, err = time.Parse("2006-01-02 15:04:05", changedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// codeAlphabet leaves out characters that are easily misread, for codes people type or read out
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newCode returns a random code of the given length
+func newCode(length int) (string, error) {
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+	"strings"
+)
+
+var (
+	ErrConcessionNotFound    = errors.New("concession item not found")
+	ErrConcessionUnavailable = errors.New("concession item is unavailable or out of stock")
+)
+
+// ConcessionComponent is one item inside a combo
+type ConcessionComponent struct {
+	ItemID   int `json:"item_id"`
+	Quantity int `json:"quantity"`
+}
+
+// ConcessionItem is food or drink sold at one theater. An item with components is a combo;
+// selling it also takes its components out of stock.
+type ConcessionItem struct {
+	ItemID      int                   `json:"item_id"`
+	TheaterID   int                   `json:"theater_id"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Price       Money                 `json:"price"`
+	Stock       *int                  `json:"stock"` // nil when stock is not tracked
+	Active      bool                  `json:"active"`
+	Components  []ConcessionComponent `json:"components,omitempty"`
+}
+
+const concessionColumns = "itemID, theaterID, name, COALESCE(description, ''), price, stock, active"
+
+func scanConcessionItem(row rowScanner) (*ConcessionItem, error) {
+	var item ConcessionItem
+	var stock sql.NullInt64
+	if err := row.Scan(&item.ItemID, &item.TheaterID, &item.Name, &item.Description, &item.Price, &stock, &item.Active); err != nil {
+		return nil, err
+	}
+	item.Stock = nullIntPtr(stock)
+	return &item, nil
+}
+
+// GetConcessionItems retrieves a theater's catalogue, optionally only what is on sale
+func GetConcessionItems(theaterID int, activeOnly bool) ([]ConcessionItem, error) {
+	query := "SELECT " + concessionColumns + " FROM CONCESSION_ITEM WHERE theaterID = ?"
+	if activeOnly {
+		query += " AND active = TRUE"
+	}
+	rows, err := config.DB.Query(query+" ORDER BY name", theaterID)
+	if err != nil {
+		return nil, err
+	}
+	items := []ConcessionItem{}
+	for rows.Next() {
+		item, err := scanConcessionItem(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		if items[i].Components, err = concessionComponents(config.DB, items[i].ItemID); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// GetConcessionItem retrieves one item with its combo components
+func GetConcessionItem(itemID int) (*ConcessionItem, error) {
+	item, err := scanConcessionItem(config.DB.QueryRow("SELECT "+concessionColumns+" FROM CONCESSION_ITEM WHERE itemID = ?", itemID))
+	if err == sql.ErrNoRows {
+		return nil, ErrConcessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if item.Components, err = concessionComponents(config.DB, itemID); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// CreateConcessionItem adds an item or combo to a theater's catalogue
+func CreateConcessionItem(item *ConcessionItem) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := item.validate(tx); err != nil {
+		return err
+	}
+	result, err := tx.Exec(
+		"INSERT INTO CONCESSION_ITEM (theaterID, name, description, price, stock, active) VALUES (?, ?, ?, ?, ?, ?)",
+		item.TheaterID, item.Name, item.Description, item.Price, item.Stock, item.Active,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	item.ItemID = int(id)
+
+	if err := insertConcessionComponents(tx, item); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateConcessionItem replaces an item's details, stock and combo components
+func UpdateConcessionItem(item *ConcessionItem) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM CONCESSION_ITEM WHERE itemID = ?)", item.ItemID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrConcessionNotFound
+	}
+	if err := item.validate(tx); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE CONCESSION_ITEM SET theaterID = ?, name = ?, description = ?, price = ?, stock = ?, active = ? WHERE itemID = ?",
+		item.TheaterID, item.Name, item.Description, item.Price, item.Stock, item.Active, item.ItemID,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM CONCESSION_COMBO_ITEM WHERE comboID = ?", item.ItemID); err != nil {
+		return err
+	}
+	if err := insertConcessionComponents(tx, item); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeactivateConcessionItem takes an item off sale. Orders already placed keep it.
+func DeactivateConcessionItem(itemID int) error {
+	result, err := config.DB.Exec("UPDATE CONCESSION_ITEM SET active = FALSE WHERE itemID = ?", itemID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return ErrConcessionNotFound
+	}
+	return nil
+}
+
+// validate normalizes the item and checks that its components are plain items of the same theater
+func (item *ConcessionItem) validate(q queryRower) error {
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		return errors.New("name is required")
+	}
+	if item.TheaterID <= 0 {
+		return errors.New("theater_id is required")
+	}
+	if item.Price < 0 {
+		return errors.New("price cannot be negative")
+	}
+	if item.Stock != nil && *item.Stock < 0 {
+		return errors.New("stock cannot be negative")
+	}
+
+	for _, component := range item.Components {
+		if component.Quantity <= 0 {
+			return errors.New("component quantities must be positive")
+		}
+		if component.ItemID == item.ItemID {
+			return errors.New("a combo cannot contain itself")
+		}
+		var theaterID, nested int
+		err := q.QueryRow(
+			"SELECT theaterID, (SELECT COUNT(*) FROM CONCESSION_COMBO_ITEM WHERE comboID = itemID) FROM CONCESSION_ITEM WHERE itemID = ?",
+			component.ItemID,
+		).Scan(&theaterID, &nested)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: component %d", ErrConcessionNotFound, component.ItemID)
+		}
+		if err != nil {
+			return err
+		}
+		if theaterID != item.TheaterID {
+			return fmt.Errorf("component %d is sold at another theater", component.ItemID)
+		}
+		if nested > 0 {
+			return fmt.Errorf("component %d is itself a combo", component.ItemID)
+		}
+	}
+	return nil
+}
+
+func insertConcessionComponents(tx *sql.Tx, item *ConcessionItem) error {
+	for _, component := range item.Components {
+		_, err := tx.Exec("INSERT INTO CONCESSION_COMBO_ITEM (comboID, itemID, quantity) VALUES (?, ?, ?)", item.ItemID, component.ItemID, component.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func concessionComponents(q querier, comboID int) ([]ConcessionComponent, error) {
+	rows, err := q.Query("SELECT itemID, quantity FROM CONCESSION_COMBO_ITEM WHERE comboID = ? ORDER BY itemID", comboID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var components []ConcessionComponent
+	for rows.Next() {
+		var component ConcessionComponent
+		if err := rows.Scan(&component.ItemID, &component.Quantity); err != nil {
+			return nil, err
+		}
+		components = append(components, component)
+	}
+	return components, rows.Err()
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPickupNotFound        = errors.New("pickup code not found")
+	ErrPickupAlreadyRedeemed = errors.New("concession order has already been collected")
+	ErrPickupNotValid        = errors.New("concession order is not paid or was cancelled")
+)
+
+// ConcessionLine is an item a customer adds to their booking at checkout
+type ConcessionLine struct {
+	ItemID   int `json:"item_id"`
+	Quantity int `json:"quantity"`
+}
+
+// ConcessionPrice is one priced line of a concession order
+type ConcessionPrice struct {
+	ItemID    int    `json:"item_id"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Money  `json:"unit_price"`
+	Total     Money  `json:"total"`
+}
+
+// ConcessionOrder is the food and drink of a booking, collected at the counter with its pickup code
+type ConcessionOrder struct {
+	BookingID  int64             `json:"booking_id"`
+	PickupCode string            `json:"pickup_code"`
+	Lines      []ConcessionPrice `json:"lines"`
+	RedeemedAt *time.Time        `json:"redeemed_at"`
+	Cancelled  bool              `json:"cancelled,omitempty"` // The booking was cancelled and the stock returned
+}
+
+// addConcessions prices the chosen items at the booking's theater and adds them to the quote.
+// Stock is only checked here; it is taken when the booking is made.
+func (quote *PriceQuote) addConcessions(q querier, theaterID int, lines []ConcessionLine) error {
+	quote.ConcessionItems = nil
+	quote.Concessions = 0
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return errors.New("concession quantities must be positive")
+		}
+		item, err := scanConcessionItem(q.QueryRow("SELECT "+concessionColumns+" FROM CONCESSION_ITEM WHERE itemID = ?", line.ItemID))
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: item %d", ErrConcessionNotFound, line.ItemID)
+		}
+		if err != nil {
+			return err
+		}
+		if item.TheaterID != theaterID || !item.Active {
+			return fmt.Errorf("%w: %s is not on sale at this theater", ErrConcessionUnavailable, item.Name)
+		}
+
+		price := ConcessionPrice{ItemID: item.ItemID, Name: item.Name, Quantity: line.Quantity, UnitPrice: item.Price, Total: item.Price * Money(line.Quantity)}
+		quote.ConcessionItems = append(quote.ConcessionItems, price)
+		quote.Concessions += price.Total
+	}
+
+	needs, err := concessionStockNeeds(q, quote.ConcessionItems)
+	if err != nil {
+		return err
+	}
+	for itemID, quantity := range needs {
+		var stock sql.NullInt64
+		if err := q.QueryRow("SELECT stock FROM CONCESSION_ITEM WHERE itemID = ?", itemID).Scan(&stock); err != nil {
+			return err
+		}
+		if stock.Valid && stock.Int64 < int64(quantity) {
+			return fmt.Errorf("%w: item %d", ErrConcessionUnavailable, itemID)
+		}
+	}
+	quote.settle()
+	return nil
+}
+
+// concessionStockNeeds counts how many of each item an order takes out of stock, combos included
+func concessionStockNeeds(q querier, lines []ConcessionPrice) (map[int]int, error) {
+	needs := make(map[int]int)
+	for _, line := range lines {
+		needs[line.ItemID] += line.Quantity
+		components, err := concessionComponents(q, line.ItemID)
+		if err != nil {
+			return nil, err
+		}
+		for _, component := range components {
+			needs[component.ItemID] += component.Quantity * line.Quantity
+		}
+	}
+	return needs, nil
+}
+
+// reserveConcessions takes a booking's items out of stock and opens its order under a new pickup code.
+// Items are locked in ID order, after the seats and promo code.
+func reserveConcessions(tx *sql.Tx, bookingID int64, lines []ConcessionPrice) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	needs, err := concessionStockNeeds(tx, lines)
+	if err != nil {
+		return err
+	}
+	itemIDs := make([]int, 0, len(needs))
+	for itemID := range needs {
+		itemIDs = append(itemIDs, itemID)
+	}
+	sort.Ints(itemIDs)
+
+	for _, itemID := range itemIDs {
+		var stock sql.NullInt64
+		if err := tx.QueryRow("SELECT stock FROM CONCESSION_ITEM WHERE itemID = ? FOR UPDATE", itemID).Scan(&stock); err != nil {
+			return err
+		}
+		if !stock.Valid {
+			continue
+		}
+		if stock.Int64 < int64(needs[itemID]) {
+			return fmt.Errorf("%w: item %d", ErrConcessionUnavailable, itemID)
+		}
+		if _, err := tx.Exec("UPDATE CONCESSION_ITEM SET stock = stock - ? WHERE itemID = ?", needs[itemID], itemID); err != nil {
+			return err
+		}
+	}
+
+	code, err := newCode(8)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO CONCESSION_ORDER (bookingID, pickupCode, createdAt, released) VALUES (?, ?, ?, FALSE)", bookingID, code, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		_, err := tx.Exec(
+			"INSERT INTO BOOKING_CONCESSION (bookingID, itemID, name, quantity, unitPrice, total) VALUES (?, ?, ?, ?, ?, ?)",
+			bookingID, line.ItemID, line.Name, line.Quantity, line.UnitPrice, line.Total,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseConcessions returns the stock of a booking's order that was never collected
+func releaseConcessions(tx *sql.Tx, bookingID int64) error {
+	var redeemed, released bool
+	err := tx.QueryRow("SELECT redeemedAt IS NOT NULL, released FROM CONCESSION_ORDER WHERE bookingID = ? FOR UPDATE", bookingID).Scan(&redeemed, &released)
+	if err == sql.ErrNoRows || (err == nil && (redeemed || released)) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lines, err := concessionOrderLines(tx, bookingID)
+	if err != nil {
+		return err
+	}
+	needs, err := concessionStockNeeds(tx, lines)
+	if err != nil {
+		return err
+	}
+	for itemID, quantity := range needs {
+		if _, err := tx.Exec("UPDATE CONCESSION_ITEM SET stock = stock + ? WHERE itemID = ? AND stock IS NOT NULL", quantity, itemID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("UPDATE CONCESSION_ORDER SET released = TRUE WHERE bookingID = ?", bookingID)
+	return err
+}
+
+// GetConcessionOrder retrieves a booking's concession order, or nil when it has none
+func GetConcessionOrder(bookingID int64) (*ConcessionOrder, error) {
+	return concessionOrder(config.DB, bookingID)
+}
+
+func concessionOrder(q querier, bookingID int64) (*ConcessionOrder, error) {
+	order := ConcessionOrder{BookingID: bookingID}
+	var redeemedAt sql.NullString
+	err := q.QueryRow("SELECT pickupCode, redeemedAt, released FROM CONCESSION_ORDER WHERE bookingID = ?", bookingID).Scan(&order.PickupCode, &redeemedAt, &order.Cancelled)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order.RedeemedAt, err = parseNullTime(redeemedAt); err != nil {
+		return nil, err
+	}
+	if order.Lines, err = concessionOrderLines(q, bookingID); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func concessionOrderLines(q querier, bookingID int64) ([]ConcessionPrice, error) {
+	rows, err := q.Query("SELECT itemID, name, quantity, unitPrice, total FROM BOOKING_CONCESSION WHERE bookingID = ? ORDER BY lineID", bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []ConcessionPrice{}
+	for rows.Next() {
+		var line ConcessionPrice
+		if err := rows.Scan(&line.ItemID, &line.Name, &line.Quantity, &line.UnitPrice, &line.Total); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// RedeemPickupCode hands over a paid concession order at the counter. Each order is collected once.
+func RedeemPickupCode(code string, actor Actor) (*ConcessionOrder, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var bookingID int64
+	var redeemed, released bool
+	var status string
+	err = tx.QueryRow(`
+        SELECT o.bookingID, o.redeemedAt IS NOT NULL, o.released, COALESCE(b.status, ?)
+        FROM CONCESSION_ORDER o
+        JOIN BOOKING b ON b.bookingID = o.bookingID
+        WHERE o.pickupCode = ?
+        FOR UPDATE
+    `, BookingStatusPending, strings.ToUpper(strings.TrimSpace(code))).Scan(&bookingID, &redeemed, &released, &status)
+	if err == sql.ErrNoRows {
+		return nil, ErrPickupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case redeemed:
+		order, _ := concessionOrder(tx, bookingID)
+		return order, ErrPickupAlreadyRedeemed
+	case released || (status != BookingStatusConfirmed && status != BookingStatusCheckedIn):
+		return nil, fmt.Errorf("%w: booking is %s", ErrPickupNotValid, status)
+	}
+
+	if _, err := tx.Exec("UPDATE CONCESSION_ORDER SET redeemedAt = ?, redeemedBy = ? WHERE bookingID = ?", time.Now(), actor.UserID, bookingID); err != nil {
+		return nil, err
+	}
+	order, err := concessionOrder(tx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"math"
+	"my-app/config"
+	"time"
+)
+
+// Schedule pricing modes
+const (
+	PricingModeFixed   = "FIXED"   // Every seat starts from the schedule fare
+	PricingModeDynamic = "DYNAMIC" // The starting fare moves between the floor and ceiling with demand
+)
+
+// dynamicFare places a fare between floor and ceiling. Demand is a weighted mix of sell-through and
+// how close the show is, so a filling house or an imminent showtime push the price up.
+func dynamicFare(floor, ceiling Money, sold, capacity int, untilShow time.Duration) Money {
+	occupancy := 0.0
+	if capacity > 0 {
+		occupancy = math.Min(float64(sold)/float64(capacity), 1)
+	}
+	urgency := 1.0
+	if window := config.DynamicPricingWindow; window > 0 {
+		urgency = 1 - math.Max(math.Min(float64(untilShow)/float64(window), 1), 0)
+	}
+
+	weight := math.Min(float64(config.DynamicOccupancyWeight), 100) / 100
+	demand := weight*occupancy + (1-weight)*urgency
+	return floor + Money(math.Round(float64(ceiling-floor)*demand))
+}
+
+// scheduleDemand counts the seats sold and on sale for a schedule; blocked seats count as neither
+func scheduleDemand(q queryRower, scheduleID int) (sold, capacity int, err error) {
+	err = q.QueryRow(
+		"SELECT COALESCE(SUM(status = ?), 0), COALESCE(SUM(status <> ?), 0) FROM SCHEDULE_SEAT WHERE scheduleID = ?",
+		SeatStatusBooked, SeatStatusBlocked, scheduleID,
+	).Scan(&sold, &capacity)
+	return sold, capacity, err
+}
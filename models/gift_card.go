@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+	"strings"
+	"time"
+)
+
+// Gift card statuses
+const (
+	GiftCardActive = "ACTIVE"
+	GiftCardVoided = "VOIDED"
+)
+
+var (
+	ErrGiftCardNotFound    = errors.New("gift card not found")
+	ErrGiftCardNotUsable   = errors.New("gift card is voided or expired")
+	ErrInsufficientBalance = errors.New("insufficient stored-value balance")
+)
+
+// GiftCard is a prepaid balance spent with its code
+type GiftCard struct {
+	GiftCardID     int           `json:"gift_card_id"`
+	Code           string        `json:"code"`
+	InitialBalance Money         `json:"initial_balance"`
+	Balance        Money         `json:"balance"`
+	ExpiresAt      *time.Time    `json:"expires_at"`
+	Status         string        `json:"status"`
+	CreatedAt      time.Time     `json:"created_at"`
+	Ledger         []LedgerEntry `json:"ledger,omitempty"`
+}
+
+const giftCardColumns = "giftCardID, code, initialBalance, balance, expiresAt, status, createdAt"
+
+func scanGiftCard(row rowScanner) (*GiftCard, error) {
+	var card GiftCard
+	var expiresAt sql.NullString
+	var createdAt string
+	if err := row.Scan(&card.GiftCardID, &card.Code, &card.InitialBalance, &card.Balance, &expiresAt, &card.Status, &createdAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if card.ExpiresAt, err = parseNullTime(expiresAt); err != nil {
+		return nil, err
+	}
+	if card.CreatedAt, err = time.ParseInLocation("2006-01-02 15:04:05", createdAt, time.Local); err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+// usable reports why a card cannot be spent, or nil when it can
+func (card *GiftCard) usable(now time.Time) error {
+	if card.Status != GiftCardActive {
+		return fmt.Errorf("%w: card is %s", ErrGiftCardNotUsable, strings.ToLower(card.Status))
+	}
+	if card.ExpiresAt != nil && now.After(*card.ExpiresAt) {
+		return fmt.Errorf("%w: card expired on %s", ErrGiftCardNotUsable, card.ExpiresAt.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// IssueGiftCard creates a card with its opening balance. A code is generated when none is given.
+func IssueGiftCard(card *GiftCard, actor Actor) error {
+	if card.InitialBalance <= 0 {
+		return errors.New("initial_balance must be positive")
+	}
+	if card.ExpiresAt != nil && card.ExpiresAt.Before(time.Now()) {
+		return errors.New("expires_at is in the past")
+	}
+	card.Code = strings.ToUpper(strings.TrimSpace(card.Code))
+	if card.Code == "" {
+		code, err := newCode(16)
+		if err != nil {
+			return err
+		}
+		card.Code = code
+	}
+	card.Balance = card.InitialBalance
+	card.Status = GiftCardActive
+	card.CreatedAt = time.Now()
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO GIFT_CARD (code, initialBalance, balance, expiresAt, status, issuedBy, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		card.Code, card.InitialBalance, card.Balance, card.ExpiresAt, card.Status, actor.UserID, card.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	card.GiftCardID = int(id)
+
+	entry := LedgerEntry{Account: AccountGiftCard, GiftCardID: &card.GiftCardID, Amount: card.Balance, BalanceAfter: card.Balance, Reason: LedgerIssue, ActorID: actor.UserID}
+	if err := recordLedgerEntry(tx, &entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetGiftCards retrieves every gift card, newest first
+func GetGiftCards() ([]GiftCard, error) {
+	rows, err := config.DB.Query("SELECT " + giftCardColumns + " FROM GIFT_CARD ORDER BY giftCardID DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := []GiftCard{}
+	for rows.Next() {
+		card, err := scanGiftCard(rows)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, *card)
+	}
+	return cards, rows.Err()
+}
+
+// GetGiftCard retrieves one gift card with its ledger
+func GetGiftCard(giftCardID int) (*GiftCard, error) {
+	card, err := scanGiftCard(config.DB.QueryRow("SELECT "+giftCardColumns+" FROM GIFT_CARD WHERE giftCardID = ?", giftCardID))
+	if err == sql.ErrNoRows {
+		return nil, ErrGiftCardNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if card.Ledger, err = getLedger("giftCardID", giftCardID); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// VoidGiftCard stops a card from being used and writes off its remaining balance
+func VoidGiftCard(giftCardID int, actor Actor, note string) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	card, err := scanGiftCard(tx.QueryRow("SELECT "+giftCardColumns+" FROM GIFT_CARD WHERE giftCardID = ? FOR UPDATE", giftCardID))
+	if err == sql.ErrNoRows {
+		return ErrGiftCardNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if card.Status == GiftCardVoided {
+		return fmt.Errorf("%w: card is already voided", ErrGiftCardNotUsable)
+	}
+
+	if _, err := tx.Exec("UPDATE GIFT_CARD SET status = ?, balance = 0 WHERE giftCardID = ?", GiftCardVoided, giftCardID); err != nil {
+		return err
+	}
+	entry := LedgerEntry{Account: AccountGiftCard, GiftCardID: &giftCardID, Amount: -card.Balance, BalanceAfter: 0, Reason: LedgerVoid, ActorID: actor.UserID, Note: note}
+	if err := recordLedgerEntry(tx, &entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// debitGiftCard spends part of a card's balance on a booking inside the payment's transaction
+func debitGiftCard(tx *sql.Tx, code string, amount Money, bookingID int64, actor Actor) (int, error) {
+	card, err := scanGiftCard(tx.QueryRow("SELECT "+giftCardColumns+" FROM GIFT_CARD WHERE code = ? FOR UPDATE", strings.ToUpper(strings.TrimSpace(code))))
+	if err == sql.ErrNoRows {
+		return 0, ErrGiftCardNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := card.usable(time.Now()); err != nil {
+		return 0, err
+	}
+	if card.Balance < amount {
+		return 0, fmt.Errorf("%w: gift card balance is %s", ErrInsufficientBalance, card.Balance)
+	}
+
+	balance := card.Balance - amount
+	if _, err := tx.Exec("UPDATE GIFT_CARD SET balance = ? WHERE giftCardID = ?", balance, card.GiftCardID); err != nil {
+		return 0, err
+	}
+	entry := LedgerEntry{Account: AccountGiftCard, GiftCardID: &card.GiftCardID, Amount: -amount, BalanceAfter: balance, Reason: LedgerPayment, BookingID: &bookingID, ActorID: actor.UserID}
+	if err := recordLedgerEntry(tx, &entry); err != nil {
+		return 0, err
+	}
+	return card.GiftCardID, nil
+}
+
+// refundGiftCard gives a booking's payment back to the card it came from. It reports false, leaving
+// the card alone, when the card has since been voided or has expired.
+func refundGiftCard(tx *sql.Tx, giftCardID int, amount Money, bookingID int64, actor Actor, note string) (bool, error) {
+	card, err := scanGiftCard(tx.QueryRow("SELECT "+giftCardColumns+" FROM GIFT_CARD WHERE giftCardID = ? FOR UPDATE", giftCardID))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if card.usable(time.Now()) != nil {
+		return false, nil
+	}
+
+	balance := card.Balance + amount
+	if _, err := tx.Exec("UPDATE GIFT_CARD SET balance = ? WHERE giftCardID = ?", balance, giftCardID); err != nil {
+		return false, err
+	}
+	entry := LedgerEntry{Account: AccountGiftCard, GiftCardID: &giftCardID, Amount: amount, BalanceAfter: balance, Reason: LedgerRefund, BookingID: &bookingID, ActorID: actor.UserID, Note: note}
+	if err := recordLedgerEntry(tx, &entry); err != nil {
+		return false, err
+	}
+	return true, nil
+}
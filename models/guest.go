@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"my-app/config"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RoleGuest is given to customers who checked out without registering
+const RoleGuest = "guest"
+
+var (
+	ErrAccountExists   = errors.New("an account already exists for this email, please log in")
+	ErrGuestNotClaimed = errors.New("the booking access token is not for a guest checkout with this email")
+	ErrInvalidPhone    = errors.New("invalid phone number")
+)
+
+// NormalizeEmail puts an email in the form guests are stored and matched under
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone keeps the digits of a phone number and a leading +, dropping spaces and punctuation
+func NormalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	var normalized strings.Builder
+	if strings.HasPrefix(phone, "+") {
+		normalized.WriteByte('+')
+	}
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
+
+// customerUserIDs returns the user IDs counted as one customer for limits: the user itself and, for a
+// guest, every guest checkout made with the same email or phone
+func customerUserIDs(q querier, userID int) ([]int, error) {
+	var role, email, phone string
+	err := q.QueryRow("SELECT role, email, phone FROM users WHERE id = ?", userID).Scan(&role, &email, &phone)
+	if err == sql.ErrNoRows || (err == nil && role != RoleGuest) {
+		return []int{userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query("SELECT id FROM users WHERE role = ? AND (email = ? OR (phone = ? AND phone <> ''))", RoleGuest, email, phone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	userIDs := []int{userID}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if id != userID {
+			userIDs = append(userIDs, id)
+		}
+	}
+	return userIDs, rows.Err()
+}
+
+// inList returns the placeholders of an SQL IN list for ids and the matching arguments
+func inList(ids []int) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// AllowGuestCheckout counts a guest checkout against its email and phone, and reports whether
+// either has been used for more than config.GuestBookingsPerContact checkouts in the last hour
+func AllowGuestCheckout(email, phone string) bool {
+	allowEmail := AllowRequest("guest-book:email:"+NormalizeEmail(email), config.GuestBookingsPerContact, time.Hour)
+	allowPhone := AllowRequest("guest-book:phone:"+NormalizePhone(phone), config.GuestBookingsPerContact, time.Hour)
+	return allowEmail && allowPhone
+}
+
+// CreateGuest returns the guest a customer checking out without an account books as. A checkout
+// with the same email and phone as an earlier one reuses its guest, so repeated checkouts do not
+// pile up users and count towards the same purchase limits; the stored name is kept.
+// Emails that belong to a registered account must log in instead.
+func CreateGuest(email, phone, name string) (User, error) {
+	email, phone = NormalizeEmail(email), NormalizePhone(phone)
+	if phone == "" {
+		return User{}, ErrInvalidPhone
+	}
+	user := User{Email: email, Phone: phone, Name: name, Role: RoleGuest}
+
+	var registered bool
+	if err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND role <> ?)", email, RoleGuest).Scan(&registered); err != nil {
+		return User{}, err
+	}
+	if registered {
+		return User{}, ErrAccountExists
+	}
+
+	err := config.DB.QueryRow("SELECT id, name FROM users WHERE email = ? AND phone = ? AND role = ? ORDER BY id LIMIT 1", email, phone, RoleGuest).
+		Scan(&user.ID, &user.Name)
+	if err == nil {
+		return user, nil
+	}
+	if err != sql.ErrNoRows {
+		return User{}, err
+	}
+
+	// Guests never log in; the random password only fills the column
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(uuid.NewString()), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, err
+	}
+	result, err := config.DB.Exec("INSERT INTO users (email, name, password, phone, role, gender) VALUES (?, ?, ?, ?, ?, ?)",
+		email, name, hashedPassword, phone, RoleGuest, "")
+	if err != nil {
+		return User{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return User{}, err
+	}
+	user.ID = int(id)
+	return user, nil
+}
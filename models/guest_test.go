@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"my-app/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testGuestEmail returns an email no earlier test run has checked out with
+func testGuestEmail() string {
+	return fmt.Sprintf("guest-%d@example.com", time.Now().UnixNano())
+}
+
+func TestCreateGuestReusesGuestWithSameContact(t *testing.T) {
+	requireDB(t)
+	email := testGuestEmail()
+
+	first, err := CreateGuest(email, "+44 20 7946 0000", "First")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := CreateGuest(" "+strings.ToUpper(email)+" ", "+44 (20) 7946-0000", "Second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != first.ID {
+		t.Fatalf("second checkout got guest %d, want the first checkout's guest %d", second.ID, first.ID)
+	}
+	if second.Name != "First" {
+		t.Errorf("reused guest is named %q, want the stored name %q", second.Name, "First")
+	}
+
+	other, err := CreateGuest(email, "+44 20 7946 0001", "Other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == first.ID {
+		t.Errorf("a checkout with another phone reused guest %d", first.ID)
+	}
+}
+
+func TestGuestCheckoutsWithSameEmailShareSeatLimit(t *testing.T) {
+	requireDB(t)
+	previous := config.MaxSeatsPerSchedule
+	config.MaxSeatsPerSchedule = 2
+	t.Cleanup(func() { config.MaxSeatsPerSchedule = previous })
+
+	scheduleID, _ := createTestSchedule(t)
+	email := testGuestEmail()
+	first, err := CreateGuest(email, "+1 555 0100", "Guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := CreateGuest(email, "+1 555 0199", "Guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == second.ID {
+		t.Fatal("checkouts with different phones got the same guest")
+	}
+
+	insertTestRow(t, "INSERT INTO BOOKING (userID, movieID, screenID, scheduleID, bookingDate, seatsBooked, status) VALUES (?, 0, 0, ?, ?, 2, ?)",
+		first.ID, scheduleID, time.Now(), BookingStatusConfirmed)
+
+	err = checkPurchaseLimits(config.DB, second.ID, scheduleID, 1, nil)
+	if !errors.Is(err, ErrPurchaseLimit) {
+		t.Fatalf("second guest checkout with the same email: got %v, want %v", err, ErrPurchaseLimit)
+	}
+	if err := checkPurchaseLimits(config.DB, createTestUser(t), scheduleID, 1, nil); err != nil {
+		t.Errorf("another customer was limited: %v", err)
+	}
+}
+
+func TestGuestCheckoutsWithSameEmailSharePromoUses(t *testing.T) {
+	requireDB(t)
+	promo := &PromoCode{Code: fmt.Sprintf("ONCE%d", time.Now().UnixNano()), DiscountType: AdjustmentFixed, Amount: 100, MaxUsesPerUser: 1}
+	if err := CreatePromoCode(promo); err != nil {
+		t.Fatal(err)
+	}
+	email := testGuestEmail()
+	first, err := CreateGuest(email, "+1 555 0100", "Guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := CreateGuest(email, "+1 555 0199", "Guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insertTestRow(t, "INSERT INTO PROMO_REDEMPTION (promoID, userID, bookingID, discount, redeemedAt, released) VALUES (?, ?, 0, 100, ?, FALSE)",
+		promo.PromoID, first.ID, time.Now())
+
+	err = checkPromoUsage(config.DB, promo, second.ID)
+	if !errors.Is(err, ErrPromoExhausted) {
+		t.Fatalf("second guest checkout with the same email: got %v, want %v", err, ErrPromoExhausted)
+	}
+	if err := checkPromoUsage(config.DB, promo, createTestUser(t)); err != nil {
+		t.Errorf("another customer could not use the code: %v", err)
+	}
+}
@@ -0,0 +1,361 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"my-app/config"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// SeatStatusHeld marks a seat temporarily locked by a customer's hold. Holds live in Redis.
+const SeatStatusHeld = "HELD"
+
+var (
+	ErrHoldNotFound = errors.New("hold not found or expired")
+	ErrHoldNotOwned = errors.New("hold does not belong to the user")
+)
+
+// SeatHold is a temporary lock on seats of one schedule, waiting for payment
+type SeatHold struct {
+	HoldID     string      `json:"hold_id"`
+	UserID     int         `json:"user_id"`
+	ScheduleID int         `json:"schedule_id"`
+	SeatIDs    []int       `json:"seat_ids"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+	Warnings   []string    `json:"warnings,omitempty"` // Seat gap warnings under the theater's WARN policy
+	Quote      *PriceQuote `json:"quote,omitempty"`    // Price of the held seats, including any promo code, honoured at payment
+	CheckoutOptions
+}
+
+// releaseSeatScript deletes a seat lock only if it still belongs to the given hold
+var releaseSeatScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// holdIndexKey is a sorted set of hold IDs scored by expiry, swept by SweepExpiredHolds
+const holdIndexKey = "hold_index"
+
+// holdRecordGrace keeps a hold's record around after its seat locks expire so the sweeper can
+// still tell which seats it covered
+const holdRecordGrace = time.Hour
+
+func seatHoldKey(scheduleID, seatID int) string {
+	return fmt.Sprintf("seat_hold:%d:%d", scheduleID, seatID)
+}
+
+func holdKey(holdID string) string {
+	return fmt.Sprintf("hold:%s", holdID)
+}
+
+// userHoldsKey is the set of a user's hold IDs, used to enforce purchase limits
+func userHoldsKey(userID int) string {
+	return fmt.Sprintf("user_holds:%d", userID)
+}
+
+// HoldSeats locks seats of a schedule for a user for config.SeatHoldDuration.
+// Checkout options such as a promo code are validated now and applied when the hold is paid.
+func HoldSeats(userID, scheduleID int, seatIDs []int, options CheckoutOptions) (*SeatHold, error) {
+	if len(seatIDs) == 0 {
+		return nil, errors.New("no seats selected")
+	}
+	seen := make(map[int]bool)
+	for _, seatID := range seatIDs {
+		if seen[seatID] {
+			return nil, fmt.Errorf("seat %d selected more than once", seatID)
+		}
+		seen[seatID] = true
+	}
+
+	if config.RedisClient == nil {
+		return nil, errors.New("seat holds are unavailable")
+	}
+	if err := checkSeatsAvailable(scheduleID, seatIDs); err != nil {
+		return nil, err
+	}
+	warnings, err := CheckSeatGaps(scheduleID, seatIDs)
+	if err != nil {
+		return nil, err
+	}
+	holds, err := customerActiveHolds(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPurchaseLimits(config.DB, userID, scheduleID, len(seatIDs), holds); err != nil {
+		return nil, err
+	}
+	quote, err := QuoteSeats(userID, scheduleID, seatIDs, options)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	hold := &SeatHold{
+		HoldID:     uuid.NewString(),
+		UserID:     userID,
+		ScheduleID: scheduleID,
+		SeatIDs:    seatIDs,
+		ExpiresAt:  time.Now().Add(config.SeatHoldDuration),
+		Warnings:   warnings,
+		Quote:      quote,
+
+		CheckoutOptions: options,
+	}
+
+	// Lock each seat; the first seat already locked by someone else aborts the whole hold
+	var locked []int
+	for _, seatID := range seatIDs {
+		ok, err := config.RedisClient.SetNX(ctx, seatHoldKey(scheduleID, seatID), hold.HoldID, config.SeatHoldDuration).Result()
+		if err != nil || !ok {
+			releaseSeatLocks(ctx, hold.HoldID, scheduleID, locked)
+			if err != nil {
+				return nil, fmt.Errorf("error holding seats: %v", err)
+			}
+			return nil, ErrSeatsUnavailable
+		}
+		locked = append(locked, seatID)
+	}
+
+	data, err := json.Marshal(hold)
+	if err != nil {
+		releaseSeatLocks(ctx, hold.HoldID, scheduleID, locked)
+		return nil, err
+	}
+	if err := config.RedisClient.Set(ctx, holdKey(hold.HoldID), data, config.SeatHoldDuration+holdRecordGrace).Err(); err != nil {
+		releaseSeatLocks(ctx, hold.HoldID, scheduleID, locked)
+		return nil, fmt.Errorf("error saving hold: %v", err)
+	}
+	config.RedisClient.ZAdd(ctx, holdIndexKey, &redis.Z{Score: float64(hold.ExpiresAt.Unix()), Member: hold.HoldID})
+	config.RedisClient.SAdd(ctx, userHoldsKey(userID), hold.HoldID)
+	config.RedisClient.Expire(ctx, userHoldsKey(userID), config.SeatHoldDuration+holdRecordGrace)
+
+	publishSeatChanges(scheduleID, seatIDs, SeatStatusHeld)
+	return hold, nil
+}
+
+// GetHold retrieves an active hold by its ID
+func GetHold(holdID string) (*SeatHold, error) {
+	data, err := config.RedisClient.Get(context.Background(), holdKey(holdID)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving hold: %v", err)
+	}
+
+	var hold SeatHold
+	if err := json.Unmarshal(data, &hold); err != nil {
+		return nil, err
+	}
+	if time.Now().After(hold.ExpiresAt) {
+		return nil, ErrHoldNotFound
+	}
+	return &hold, nil
+}
+
+// GetUserHold retrieves an active hold and checks that it belongs to the user
+func GetUserHold(userID int, holdID string) (*SeatHold, error) {
+	hold, err := GetHold(holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.UserID != userID {
+		return nil, ErrHoldNotOwned
+	}
+	return hold, nil
+}
+
+// ReleaseHold gives the held seats back to the pool before the hold expires
+func ReleaseHold(userID int, holdID string) error {
+	hold, err := GetUserHold(userID, holdID)
+	if err != nil {
+		return err
+	}
+	if err := dropHold(hold); err != nil {
+		return err
+	}
+
+	publishSeatChanges(hold.ScheduleID, hold.SeatIDs, SeatStatusAvailable)
+	return nil
+}
+
+// confirmHold turns a hold into a booking inside the caller's transaction.
+// The hold itself must be dropped with dropHold once the transaction commits.
+func confirmHold(tx *sql.Tx, userID int, holdID string) (int64, *SeatHold, error) {
+	hold, err := GetUserHold(userID, holdID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Every seat lock must still belong to this hold
+	ctx := context.Background()
+	for _, seatID := range hold.SeatIDs {
+		owner, err := config.RedisClient.Get(ctx, seatHoldKey(hold.ScheduleID, seatID)).Result()
+		if err == redis.Nil || (err == nil && owner != hold.HoldID) {
+			return 0, nil, ErrHoldNotFound
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("error checking hold: %v", err)
+		}
+	}
+
+	bookingID, err := bookSeats(tx, userID, hold.ScheduleID, hold.SeatIDs, hold.CheckoutOptions, hold.Quote)
+	if err != nil {
+		return 0, nil, err
+	}
+	return bookingID, hold, nil
+}
+
+// dropHold removes a hold and the seat locks it still owns
+func dropHold(hold *SeatHold) error {
+	ctx := context.Background()
+	releaseSeatLocks(ctx, hold.HoldID, hold.ScheduleID, hold.SeatIDs)
+	config.RedisClient.ZRem(ctx, holdIndexKey, hold.HoldID)
+	config.RedisClient.SRem(ctx, userHoldsKey(hold.UserID), hold.HoldID)
+	return config.RedisClient.Del(ctx, holdKey(hold.HoldID)).Err()
+}
+
+// SweepExpiredHolds removes holds whose deadline has passed and announces their seats as
+// available again. It returns the number of holds and seats released.
+// Seat locks normally expire on their own; this catches anything left behind, for example
+// when Redis expiry notifications are disabled.
+func SweepExpiredHolds(now time.Time) (int, int, error) {
+	if config.RedisClient == nil {
+		return 0, 0, nil
+	}
+	ctx := context.Background()
+	holdIDs, err := config.RedisClient.ZRangeByScore(ctx, holdIndexKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error listing expired holds: %v", err)
+	}
+
+	holds, seats := 0, 0
+	for _, holdID := range holdIDs {
+		// Only the process that removes the index entry releases the hold
+		removed, err := config.RedisClient.ZRem(ctx, holdIndexKey, holdID).Result()
+		if err != nil {
+			return holds, seats, err
+		}
+		if removed == 0 {
+			continue
+		}
+
+		data, err := config.RedisClient.Get(ctx, holdKey(holdID)).Bytes()
+		if err == redis.Nil {
+			continue // Confirmed or released already
+		}
+		if err != nil {
+			return holds, seats, fmt.Errorf("error retrieving hold: %v", err)
+		}
+		var hold SeatHold
+		if err := json.Unmarshal(data, &hold); err != nil {
+			return holds, seats, err
+		}
+
+		releaseSeatLocks(ctx, hold.HoldID, hold.ScheduleID, hold.SeatIDs)
+		config.RedisClient.SRem(ctx, userHoldsKey(hold.UserID), hold.HoldID)
+		config.RedisClient.Del(ctx, holdKey(hold.HoldID))
+
+		freed, err := freeSeatIDs(hold.ScheduleID, hold.SeatIDs)
+		if err != nil {
+			return holds, seats, err
+		}
+		publishSeatChanges(hold.ScheduleID, freed, SeatStatusAvailable)
+		holds++
+		seats += len(freed)
+	}
+	return holds, seats, nil
+}
+
+// freeSeatIDs filters seats down to those that are on sale and not held by anyone
+func freeSeatIDs(scheduleID int, seatIDs []int) ([]int, error) {
+	held, err := heldSeatIDs(scheduleID, seatIDs)
+	if err != nil {
+		return nil, err
+	}
+	var free []int
+	for _, seatID := range seatIDs {
+		if held[seatID] {
+			continue
+		}
+		var status string
+		err := config.DB.QueryRow("SELECT status FROM SCHEDULE_SEAT WHERE scheduleID = ? AND seatID = ?", scheduleID, seatID).Scan(&status)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if status == SeatStatusAvailable {
+			free = append(free, seatID)
+		}
+	}
+	return free, nil
+}
+
+func releaseSeatLocks(ctx context.Context, holdID string, scheduleID int, seatIDs []int) {
+	for _, seatID := range seatIDs {
+		releaseSeatScript.Run(ctx, config.RedisClient, []string{seatHoldKey(scheduleID, seatID)}, holdID)
+	}
+}
+
+// heldSeatIDs reports which of the given seats are currently locked by a hold
+func heldSeatIDs(scheduleID int, seatIDs []int) (map[int]bool, error) {
+	held := make(map[int]bool)
+	if len(seatIDs) == 0 || config.RedisClient == nil {
+		return held, nil
+	}
+
+	keys := make([]string, len(seatIDs))
+	for i, seatID := range seatIDs {
+		keys[i] = seatHoldKey(scheduleID, seatID)
+	}
+	values, err := config.RedisClient.MGet(context.Background(), keys...).Result()
+	if err != nil {
+		return held, err
+	}
+	for i, value := range values {
+		if value != nil {
+			held[seatIDs[i]] = true
+		}
+	}
+	return held, nil
+}
+
+// checkSeatsAvailable verifies that the seats belong to the schedule and are not booked or blocked
+func checkSeatsAvailable(scheduleID int, seatIDs []int) error {
+	seats, err := GetSeatsByScheduleID(scheduleID)
+	if err != nil {
+		return err
+	}
+	if len(seats) == 0 {
+		return errors.New("schedule not found or has no seats")
+	}
+
+	status := make(map[int]string, len(seats))
+	for _, seat := range seats {
+		status[seat.SeatID] = seat.Status
+	}
+	for _, seatID := range seatIDs {
+		s, ok := status[seatID]
+		if !ok {
+			return errors.New("one or more seats do not belong to this schedule")
+		}
+		if s != SeatStatusAvailable {
+			return ErrSeatsUnavailable
+		}
+	}
+	return nil
+}
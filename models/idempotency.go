@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"my-app/config"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// IdempotentResponse is the stored outcome of a request made with an Idempotency-Key
+type IdempotentResponse struct {
+	RequestHash string `json:"request_hash"` // Hash of the request body the key was first used with
+	StatusCode  int    `json:"status_code"`
+	Body        []byte `json:"body"`
+	InProgress  bool   `json:"in_progress"`
+}
+
+// IdempotencyRequestHash fingerprints a request body, so that a key reused for a different request is caught
+func IdempotencyRequestHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotencyKeyHash scopes a client key to the caller and endpoint
+func idempotencyKeyHash(scope, key string) string {
+	sum := sha256.Sum256([]byte(scope + "|" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+func idempotencyRedisKey(hash string) string {
+	return "idempotency:" + hash
+}
+
+// ReserveIdempotencyKey claims a key for a request with the given body hash before it runs. It returns
+// false when the key was already used, together with the stored response (which may still be in progress).
+// Redis is used when reachable, MySQL otherwise.
+func ReserveIdempotencyKey(scope, key, requestHash string) (bool, *IdempotentResponse, error) {
+	hash := idempotencyKeyHash(scope, key)
+	placeholder, _ := json.Marshal(IdempotentResponse{RequestHash: requestHash, InProgress: true})
+
+	if config.RedisClient != nil {
+		ctx := context.Background()
+		ok, err := config.RedisClient.SetNX(ctx, idempotencyRedisKey(hash), placeholder, config.IdempotencyKeyTTL).Result()
+		if err == nil {
+			if ok {
+				return true, nil, nil
+			}
+			data, err := config.RedisClient.Get(ctx, idempotencyRedisKey(hash)).Bytes()
+			if err == redis.Nil {
+				// Expired between the two calls; the caller may simply retry
+				return false, &IdempotentResponse{RequestHash: requestHash, InProgress: true}, nil
+			}
+			if err == nil {
+				var stored IdempotentResponse
+				if err := json.Unmarshal(data, &stored); err != nil {
+					return false, nil, err
+				}
+				return false, &stored, nil
+			}
+		}
+		log.Printf("Redis unavailable for idempotency keys, falling back to MySQL: %v", err)
+	}
+
+	return reserveIdempotencyKeyDB(hash, requestHash)
+}
+
+func reserveIdempotencyKeyDB(hash, requestHash string) (bool, *IdempotentResponse, error) {
+	if _, err := config.DB.Exec("DELETE FROM IDEMPOTENCY_KEY WHERE keyHash = ? AND expiresAt < ?", hash, time.Now()); err != nil {
+		return false, nil, err
+	}
+
+	result, err := config.DB.Exec(
+		"INSERT IGNORE INTO IDEMPOTENCY_KEY (keyHash, requestHash, statusCode, responseBody, inProgress, expiresAt) VALUES (?, ?, 0, '', true, ?)",
+		hash, requestHash, time.Now().Add(config.IdempotencyKeyTTL),
+	)
+	if err != nil {
+		return false, nil, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, nil, err
+	}
+	if inserted == 1 {
+		return true, nil, nil
+	}
+
+	var stored IdempotentResponse
+	err = config.DB.QueryRow("SELECT requestHash, statusCode, responseBody, inProgress FROM IDEMPOTENCY_KEY WHERE keyHash = ?", hash).
+		Scan(&stored.RequestHash, &stored.StatusCode, &stored.Body, &stored.InProgress)
+	if err == sql.ErrNoRows {
+		return false, &IdempotentResponse{RequestHash: requestHash, InProgress: true}, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	return false, &stored, nil
+}
+
+// SaveIdempotentResponse stores the final response of a reserved key
+func SaveIdempotentResponse(scope, key, requestHash string, statusCode int, body []byte) error {
+	hash := idempotencyKeyHash(scope, key)
+	data, err := json.Marshal(IdempotentResponse{RequestHash: requestHash, StatusCode: statusCode, Body: body})
+	if err != nil {
+		return err
+	}
+
+	if config.RedisClient != nil {
+		err := config.RedisClient.Set(context.Background(), idempotencyRedisKey(hash), data, config.IdempotencyKeyTTL).Err()
+		if err == nil {
+			return nil
+		}
+		log.Printf("Redis unavailable for idempotency keys, falling back to MySQL: %v", err)
+	}
+
+	_, err = config.DB.Exec(`
+        INSERT INTO IDEMPOTENCY_KEY (keyHash, requestHash, statusCode, responseBody, inProgress, expiresAt)
+        VALUES (?, ?, ?, ?, false, ?)
+        ON DUPLICATE KEY UPDATE requestHash = VALUES(requestHash), statusCode = VALUES(statusCode), responseBody = VALUES(responseBody),
+            inProgress = false, expiresAt = VALUES(expiresAt)
+    `, hash, requestHash, statusCode, body, time.Now().Add(config.IdempotencyKeyTTL))
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a reserved key so that the request can be retried,
+// used when the original attempt failed on the server side
+func ReleaseIdempotencyKey(scope, key string) {
+	hash := idempotencyKeyHash(scope, key)
+	if config.RedisClient != nil {
+		if err := config.RedisClient.Del(context.Background(), idempotencyRedisKey(hash)).Err(); err != nil {
+			log.Printf("Error releasing idempotency key in Redis: %v", err)
+		}
+	}
+	if _, err := config.DB.Exec("DELETE FROM IDEMPOTENCY_KEY WHERE keyHash = ?", hash); err != nil {
+		log.Printf("Error releasing idempotency key in MySQL: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"my-app/config"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyKeepsTheFirstRequestHash(t *testing.T) {
+	requireDB(t)
+	// Exercise the MySQL store, which is the fallback when Redis is down
+	previous := config.RedisClient
+	config.RedisClient = nil
+	t.Cleanup(func() { config.RedisClient = previous })
+
+	scope, key := "1:POST:/user/book", fmt.Sprintf("key-%d", time.Now().UnixNano())
+	first, second := IdempotencyRequestHash([]byte(`{"seats":[1]}`)), IdempotencyRequestHash([]byte(`{"seats":[2]}`))
+
+	reserved, _, err := ReserveIdempotencyKey(scope, key, first)
+	if err != nil || !reserved {
+		t.Fatalf("first use: reserved %v, err %v", reserved, err)
+	}
+	reserved, stored, err := ReserveIdempotencyKey(scope, key, second)
+	if err != nil || reserved {
+		t.Fatalf("second use: reserved %v, err %v", reserved, err)
+	}
+	if !stored.InProgress || stored.RequestHash != first {
+		t.Errorf("in progress: got %+v, want the first request's hash", stored)
+	}
+
+	if err := SaveIdempotentResponse(scope, key, first, 201, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	_, stored, err = ReserveIdempotencyKey(scope, key, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.InProgress || stored.StatusCode != 201 || stored.RequestHash != first {
+		t.Errorf("completed: got %+v, want status 201 with the first request's hash", stored)
+	}
+
+	ReleaseIdempotencyKey(scope, key)
+	if reserved, _, err := ReserveIdempotencyKey(scope, key, second); err != nil || !reserved {
+		t.Errorf("after release: reserved %v, err %v", reserved, err)
+	}
+}
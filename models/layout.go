@@ -0,0 +1,250 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+)
+
+// Non-seat cells of a seat map
+const (
+	CellTypeAisle   = "AISLE"
+	CellTypeBlocked = "BLOCKED"
+)
+
+// ErrLayoutHasBookings is returned when a layout change would remove seats sold for an upcoming show
+var ErrLayoutHasBookings = errors.New("seats with bookings for upcoming schedules cannot be removed")
+
+// LayoutCell is an aisle or blocked position on the seat grid
+type LayoutCell struct {
+	PosX     int    `json:"pos_x"`
+	PosY     int    `json:"pos_y"`
+	CellType string `json:"cell_type"`
+}
+
+// ScreenLayout is the full seat map of a screen
+type ScreenLayout struct {
+	ScreenID int          `json:"screen_id"`
+	Rows     int          `json:"rows"`
+	Columns  int          `json:"columns"`
+	Seats    []Seat       `json:"seats"`
+	Cells    []LayoutCell `json:"cells"`
+}
+
+// GetScreenLayout retrieves the seat grid of a screen including aisles and blocked cells
+func GetScreenLayout(screenID int) (*ScreenLayout, error) {
+	layout := &ScreenLayout{ScreenID: screenID}
+
+	err := config.DB.QueryRow("SELECT gridRows, gridColumns FROM SCREEN_LAYOUT WHERE screenID = ?", screenID).Scan(&layout.Rows, &layout.Columns)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	layout.Seats, err = GetSeatsByScreenID(screenID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := config.DB.Query("SELECT posX, posY, cellType FROM SCREEN_LAYOUT_CELL WHERE screenID = ? ORDER BY posY, posX", screenID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cell LayoutCell
+		if err := rows.Scan(&cell.PosX, &cell.PosY, &cell.CellType); err != nil {
+			return nil, err
+		}
+		layout.Cells = append(layout.Cells, cell)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Screens without a saved layout get a grid just large enough for their seats
+	for _, seat := range layout.Seats {
+		layout.Rows = max(layout.Rows, seat.PosY+1)
+		layout.Columns = max(layout.Columns, seat.PosX+1)
+	}
+
+	return layout, nil
+}
+
+// validate checks that every seat and cell fits the grid without overlapping
+func (layout *ScreenLayout) validate() error {
+	if layout.Rows <= 0 || layout.Columns <= 0 {
+		return errors.New("layout must have at least one row and one column")
+	}
+
+	type position struct{ x, y int }
+	used := make(map[position]bool)
+	place := func(x, y int) error {
+		if x < 0 || y < 0 || x >= layout.Columns || y >= layout.Rows {
+			return fmt.Errorf("position (%d, %d) is outside the %dx%d grid", x, y, layout.Columns, layout.Rows)
+		}
+		if used[position{x, y}] {
+			return fmt.Errorf("position (%d, %d) is used more than once", x, y)
+		}
+		used[position{x, y}] = true
+		return nil
+	}
+
+	for _, seat := range layout.Seats {
+		if seat.SeatType != "" && !ValidSeatType(seat.SeatType) {
+			return fmt.Errorf("invalid seat type %q", seat.SeatType)
+		}
+		if err := place(seat.PosX, seat.PosY); err != nil {
+			return err
+		}
+	}
+	for _, cell := range layout.Cells {
+		if cell.CellType != CellTypeAisle && cell.CellType != CellTypeBlocked {
+			return fmt.Errorf("invalid cell type %q", cell.CellType)
+		}
+		if err := place(cell.PosX, cell.PosY); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveScreenLayout replaces a screen's seat map in one transaction.
+// Incoming seats update the existing seat with the same seat_id, or else the one at the same
+// row label and column; other seats are created. Existing seats missing from the layout are
+// removed, which fails if they are booked for an upcoming schedule.
+func SaveScreenLayout(layout *ScreenLayout) error {
+	if err := layout.validate(); err != nil {
+		return err
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveScreenLayout(tx, layout); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func saveScreenLayout(tx *sql.Tx, layout *ScreenLayout) error {
+	_, err := tx.Exec(`
+        INSERT INTO SCREEN_LAYOUT (screenID, gridRows, gridColumns) VALUES (?, ?, ?)
+        ON DUPLICATE KEY UPDATE gridRows = VALUES(gridRows), gridColumns = VALUES(gridColumns)
+    `, layout.ScreenID, layout.Rows, layout.Columns)
+	if err != nil {
+		return err
+	}
+
+	// Index the seats currently on the screen
+	rows, err := tx.Query("SELECT "+seatColumns+" FROM SEAT st WHERE st.screenID = ? FOR UPDATE", layout.ScreenID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int]Seat)
+	byPlace := make(map[string]int)
+	for rows.Next() {
+		var seat Seat
+		if err := scanSeat(rows, &seat); err != nil {
+			rows.Close()
+			return err
+		}
+		byID[seat.SeatID] = seat
+		if seat.RowLabel != "" {
+			byPlace[fmt.Sprintf("%s-%d", seat.RowLabel, seat.ColumnNumber)] = seat.SeatID
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	kept := make(map[int]bool)
+	for i := range layout.Seats {
+		seat := &layout.Seats[i]
+		seat.ScreenID = layout.ScreenID
+		if seat.SeatType == "" {
+			seat.SeatType = SeatTypeStandard
+		}
+		if seat.SeatNumber == 0 {
+			seat.SeatNumber = i + 1
+		}
+
+		if seat.SeatID != 0 {
+			if _, ok := byID[seat.SeatID]; !ok {
+				return fmt.Errorf("seat %d does not belong to screen %d", seat.SeatID, layout.ScreenID)
+			}
+		} else if existingID, ok := byPlace[fmt.Sprintf("%s-%d", seat.RowLabel, seat.ColumnNumber)]; ok && seat.RowLabel != "" && !kept[existingID] {
+			seat.SeatID = existingID
+		}
+
+		if seat.SeatID != 0 {
+			_, err := tx.Exec(
+				"UPDATE SEAT SET seatNumber = ?, rowLabel = ?, columnNumber = ?, posX = ?, posY = ?, seatType = ? WHERE seatID = ?",
+				seat.SeatNumber, seat.RowLabel, seat.ColumnNumber, seat.PosX, seat.PosY, seat.SeatType, seat.SeatID,
+			)
+			if err != nil {
+				return err
+			}
+			kept[seat.SeatID] = true
+			continue
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO SEAT (screenID, seatNumber, rowLabel, columnNumber, posX, posY, seatType) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			seat.ScreenID, seat.SeatNumber, seat.RowLabel, seat.ColumnNumber, seat.PosX, seat.PosY, seat.SeatType,
+		)
+		if err != nil {
+			return err
+		}
+		seatID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		seat.SeatID = int(seatID)
+		kept[seat.SeatID] = true
+	}
+
+	// Remove seats that are no longer part of the layout
+	for seatID := range byID {
+		if kept[seatID] {
+			continue
+		}
+		var upcoming int
+		err := tx.QueryRow(`
+            SELECT COUNT(*)
+            FROM SCHEDULE_SEAT ss
+            JOIN SCHEDULE s ON s.scheduleID = ss.scheduleID
+            WHERE ss.seatID = ? AND ss.status = ? AND s.showTime > NOW()
+        `, seatID, SeatStatusBooked).Scan(&upcoming)
+		if err != nil {
+			return err
+		}
+		if upcoming > 0 {
+			return fmt.Errorf("%w (seat %d)", ErrLayoutHasBookings, seatID)
+		}
+		if _, err := tx.Exec("DELETE FROM SCHEDULE_SEAT WHERE seatID = ?", seatID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM SEAT WHERE seatID = ?", seatID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM SCREEN_LAYOUT_CELL WHERE screenID = ?", layout.ScreenID); err != nil {
+		return err
+	}
+	for _, cell := range layout.Cells {
+		_, err := tx.Exec("INSERT INTO SCREEN_LAYOUT_CELL (screenID, posX, posY, cellType) VALUES (?, ?, ?, ?)", layout.ScreenID, cell.PosX, cell.PosY, cell.CellType)
+		if err != nil {
+			return err
+		}
+	}
+
+	return syncScreenSchedules(tx, layout.ScreenID)
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"database/sql"
+	"my-app/config"
+	"time"
+)
+
+// Stored-value accounts: a gift card, or a user's wallet
+const (
+	AccountGiftCard = "GIFT_CARD"
+	AccountWallet   = "WALLET"
+)
+
+// Reasons for ledger entries
+const (
+	LedgerIssue   = "ISSUE"   // A gift card was issued with its opening balance
+	LedgerPayment = "PAYMENT" // Spent on a booking
+	LedgerRefund  = "REFUND"  // Given back for a cancelled booking
+	LedgerVoid    = "VOID"    // A voided gift card's remaining balance was written off
+	LedgerCredit  = "CREDIT"  // Added by an admin
+)
+
+// LedgerEntry is one debit (negative) or credit (positive) of a stored-value account.
+// Replaying an account's entries gives its balance.
+type LedgerEntry struct {
+	EntryID      int64     `json:"entry_id"`
+	Account      string    `json:"account"` // GIFT_CARD or WALLET
+	GiftCardID   *int      `json:"gift_card_id,omitempty"`
+	UserID       *int      `json:"user_id,omitempty"`
+	Amount       Money     `json:"amount"`
+	BalanceAfter Money     `json:"balance_after"`
+	Reason       string    `json:"reason"`
+	BookingID    *int64    `json:"booking_id,omitempty"`
+	ActorID      int       `json:"actor_id"`
+	Note         string    `json:"note,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func recordLedgerEntry(tx *sql.Tx, entry *LedgerEntry) error {
+	entry.CreatedAt = time.Now()
+	result, err := tx.Exec(`
+        INSERT INTO STORED_VALUE_LEDGER (account, giftCardID, userID, amount, balanceAfter, reason, bookingID, actorID, note, createdAt)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, entry.Account, entry.GiftCardID, entry.UserID, entry.Amount, entry.BalanceAfter, entry.Reason, entry.BookingID, entry.ActorID, entry.Note, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	entry.EntryID, err = result.LastInsertId()
+	return err
+}
+
+// getLedger retrieves the entries of one account, oldest first
+func getLedger(column string, id int) ([]LedgerEntry, error) {
+	rows, err := config.DB.Query(`
+        SELECT entryID, account, giftCardID, userID, amount, balanceAfter, reason, bookingID, actorID, COALESCE(note, ''), createdAt
+        FROM STORED_VALUE_LEDGER
+        WHERE `+column+` = ?
+        ORDER BY entryID
+    `, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LedgerEntry{}
+	for rows.Next() {
+		var entry LedgerEntry
+		var giftCardID, userID, bookingID sql.NullInt64
+		var createdAt string
+		err := rows.Scan(&entry.EntryID, &entry.Account, &giftCardID, &userID, &entry.Amount, &entry.BalanceAfter, &entry.Reason,
+			&bookingID, &entry.ActorID, &entry.Note, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.GiftCardID = nullIntPtr(giftCardID)
+		entry.UserID = nullIntPtr(userID)
+		if bookingID.Valid {
+			entry.BookingID = &bookingID.Int64
+		}
+		if entry.CreatedAt, err = time.ParseInLocation("2006-01-02 15:04:05", createdAt, time.Local); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,312 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+	"sort"
+	"time"
+)
+
+// Reasons for loyalty ledger entries
+const (
+	PointsEarn    = "EARN"    // Awarded for a paid booking
+	PointsReverse = "REVERSE" // Earned points taken back when the booking is cancelled or refunded
+	PointsRedeem  = "REDEEM"  // Spent at checkout
+	PointsReturn  = "RETURN"  // Spent points given back when the booking falls through
+)
+
+var (
+	ErrInsufficientPoints = errors.New("not enough loyalty points")
+	ErrEarnRateNotFound   = errors.New("earn rate not found")
+)
+
+// EarnRate sets the points earned per currency unit spent on a movie or at a theater.
+// A movie rate wins over a theater rate, which wins over config.LoyaltyPointsPerUnit.
+type EarnRate struct {
+	RateID        int  `json:"rate_id"`
+	MovieID       *int `json:"movie_id"`
+	TheaterID     *int `json:"theater_id"`
+	PointsPerUnit int  `json:"points_per_unit"`
+}
+
+// PointsEntry is one change to a user's points balance
+type PointsEntry struct {
+	EntryID      int64     `json:"entry_id"`
+	Points       int       `json:"points"`
+	BalanceAfter int       `json:"balance_after"` // May be negative when points already spent are reversed
+	Reason       string    `json:"reason"`
+	BookingID    *int64    `json:"booking_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// LoyaltyAccount is a user's points balance and history, newest first
+type LoyaltyAccount struct {
+	UserID  int           `json:"user_id"`
+	Balance int           `json:"balance"`
+	History []PointsEntry `json:"history"`
+}
+
+// GetLoyaltyAccount retrieves a user's points balance and history
+func GetLoyaltyAccount(userID int) (*LoyaltyAccount, error) {
+	account := LoyaltyAccount{UserID: userID, History: []PointsEntry{}}
+	err := config.DB.QueryRow("SELECT balance FROM LOYALTY_ACCOUNT WHERE userID = ?", userID).Scan(&account.Balance)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	rows, err := config.DB.Query(
+		"SELECT entryID, points, balanceAfter, reason, bookingID, createdAt FROM LOYALTY_LEDGER WHERE userID = ? ORDER BY entryID DESC", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry PointsEntry
+		var bookingID sql.NullInt64
+		var createdAt string
+		if err := rows.Scan(&entry.EntryID, &entry.Points, &entry.BalanceAfter, &entry.Reason, &bookingID, &createdAt); err != nil {
+			return nil, err
+		}
+		if bookingID.Valid {
+			entry.BookingID = &bookingID.Int64
+		}
+		if entry.CreatedAt, err = time.ParseInLocation("2006-01-02 15:04:05", createdAt, time.Local); err != nil {
+			return nil, err
+		}
+		account.History = append(account.History, entry)
+	}
+	return &account, rows.Err()
+}
+
+// pointsBalance reads a user's balance, locking it when inside a transaction
+func pointsBalance(q queryRower, userID int, lock bool) (int, error) {
+	query := "SELECT balance FROM LOYALTY_ACCOUNT WHERE userID = ?"
+	if lock {
+		query += " FOR UPDATE"
+	}
+	var balance int
+	err := q.QueryRow(query, userID).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return balance, err
+}
+
+// addPoints changes a user's balance by points and records why
+func addPoints(tx *sql.Tx, userID, points int, reason string, bookingID int64) error {
+	if points == 0 {
+		return nil
+	}
+	if _, err := tx.Exec("INSERT IGNORE INTO LOYALTY_ACCOUNT (userID, balance) VALUES (?, 0)", userID); err != nil {
+		return err
+	}
+	balance, err := pointsBalance(tx, userID, true)
+	if err != nil {
+		return err
+	}
+	balance += points
+	if _, err := tx.Exec("UPDATE LOYALTY_ACCOUNT SET balance = ? WHERE userID = ?", balance, userID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO LOYALTY_LEDGER (userID, points, balanceAfter, reason, bookingID, createdAt) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, points, balance, reason, bookingID, time.Now(),
+	)
+	return err
+}
+
+// applyPoints turns the customer's points into a discount on the tickets left to pay after any
+// membership and promo code. Each free ticket covers one of the cheapest seats, and is charged only
+// for the part of it still to pay; loose points are worth config.LoyaltyPointValue each. Only as
+// many points are spent as the tickets need.
+func (quote *PriceQuote) applyPoints(options CheckoutOptions, balance int) error {
+	if options.RedeemPoints < 0 || options.FreeTickets < 0 {
+		return errors.New("points to redeem cannot be negative")
+	}
+	if options.FreeTickets > len(quote.Seats) {
+		return errors.New("more free tickets than seats")
+	}
+	if options.RedeemPoints == 0 && options.FreeTickets == 0 {
+		return nil
+	}
+
+	remaining := quote.ticketsDue()
+	prices := make([]Money, len(quote.Seats))
+	for i, seat := range quote.Seats {
+		prices[i] = seat.Price
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	var discount Money
+	points := 0
+	for _, price := range prices[:options.FreeTickets] {
+		covered := min(price, remaining)
+		if covered <= 0 {
+			continue
+		}
+		discount += covered
+		remaining -= covered
+		// A ticket already partly paid by a membership or promo code costs only its share of the points
+		points += int((Money(config.LoyaltyFreeTicketPoints)*covered + price - 1) / price)
+	}
+
+	loose := options.RedeemPoints
+	if value := Money(config.LoyaltyPointValue); value > 0 {
+		if needed := int((remaining + value - 1) / value); loose > needed {
+			loose = needed
+		}
+		discount += min(Money(loose)*value, remaining)
+	}
+
+	points += loose
+	if points > balance {
+		return fmt.Errorf("%w: %d needed, %d available", ErrInsufficientPoints, points, balance)
+	}
+	quote.PointsRedeemed = points
+	quote.PointsDiscount = discount
+	quote.settle()
+	return nil
+}
+
+// settleBookingPoints keeps a booking's points in step with its status: paying earns points,
+// and a booking that is cancelled, refunded or expires gives back spent points and loses earned ones.
+func settleBookingPoints(tx *sql.Tx, bookingID int64, to string) error {
+	switch to {
+	case BookingStatusConfirmed:
+		return awardPoints(tx, bookingID)
+	case BookingStatusCancelled, BookingStatusRefunded, BookingStatusExpired:
+		var userID, earned, spent int
+		err := tx.QueryRow(`
+            SELECT userID,
+                COALESCE(SUM(CASE WHEN reason IN (?, ?) THEN points ELSE 0 END), 0),
+                COALESCE(SUM(CASE WHEN reason IN (?, ?) THEN -points ELSE 0 END), 0)
+            FROM LOYALTY_LEDGER WHERE bookingID = ? GROUP BY userID
+        `, PointsEarn, PointsReverse, PointsRedeem, PointsReturn, bookingID).Scan(&userID, &earned, &spent)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := addPoints(tx, userID, -earned, PointsReverse, bookingID); err != nil {
+			return err
+		}
+		return addPoints(tx, userID, spent, PointsReturn, bookingID)
+	}
+	return nil
+}
+
+// awardPoints credits the points earned by a paid booking: what the customer spent on tickets
+// and concessions, before fees and tax, at the movie's or theater's earn rate
+func awardPoints(tx *sql.Tx, bookingID int64) error {
+	var userID, movieID, theaterID, earned int
+	var spend Money
+	err := tx.QueryRow(`
+        SELECT b.userID, b.movieID, COALESCE(r.theaterID, 0),
+            COALESCE(b.subtotal, 0) - COALESCE(b.membershipDiscount, 0) - COALESCE(b.discount, 0) - COALESCE(b.pointsDiscount, 0) + COALESCE(b.concessions, 0),
+            (SELECT COUNT(*) FROM LOYALTY_LEDGER WHERE bookingID = b.bookingID AND reason = ?)
+        FROM BOOKING b
+        LEFT JOIN SCREEN sc ON sc.screenID = b.screenID
+        LEFT JOIN ROOM r ON r.roomID = sc.roomID
+        WHERE b.bookingID = ?
+    `, PointsEarn, bookingID).Scan(&userID, &movieID, &theaterID, &spend, &earned)
+	if err != nil || earned > 0 {
+		return err
+	}
+
+	rate, err := earnRate(tx, movieID, theaterID)
+	if err != nil {
+		return err
+	}
+	return addPoints(tx, userID, int(spend)*rate/100, PointsEarn, bookingID)
+}
+
+// earnRate returns the points per currency unit for a movie shown at a theater
+func earnRate(q queryRower, movieID, theaterID int) (int, error) {
+	var rate int
+	err := q.QueryRow(`
+        SELECT pointsPerUnit FROM LOYALTY_EARN_RATE
+        WHERE movieID = ? OR (movieID IS NULL AND theaterID = ?)
+        ORDER BY movieID IS NULL, rateID DESC
+        LIMIT 1
+    `, movieID, theaterID).Scan(&rate)
+	if err == sql.ErrNoRows {
+		return config.LoyaltyPointsPerUnit, nil
+	}
+	return rate, err
+}
+
+// GetEarnRates retrieves every movie and theater earn rate
+func GetEarnRates() ([]EarnRate, error) {
+	rows, err := config.DB.Query("SELECT rateID, movieID, theaterID, pointsPerUnit FROM LOYALTY_EARN_RATE ORDER BY rateID")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []EarnRate{}
+	for rows.Next() {
+		var rate EarnRate
+		var movieID, theaterID sql.NullInt64
+		if err := rows.Scan(&rate.RateID, &movieID, &theaterID, &rate.PointsPerUnit); err != nil {
+			return nil, err
+		}
+		rate.MovieID = nullIntPtr(movieID)
+		rate.TheaterID = nullIntPtr(theaterID)
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// SaveEarnRate creates an earn rate, or replaces it when RateID is set
+func SaveEarnRate(rate *EarnRate) error {
+	if (rate.MovieID == nil) == (rate.TheaterID == nil) {
+		return errors.New("an earn rate applies to either a movie_id or a theater_id")
+	}
+	if rate.PointsPerUnit < 0 {
+		return errors.New("points_per_unit cannot be negative")
+	}
+
+	if rate.RateID == 0 {
+		result, err := config.DB.Exec("INSERT INTO LOYALTY_EARN_RATE (movieID, theaterID, pointsPerUnit) VALUES (?, ?, ?)", rate.MovieID, rate.TheaterID, rate.PointsPerUnit)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		rate.RateID = int(id)
+		return err
+	}
+
+	result, err := config.DB.Exec("UPDATE LOYALTY_EARN_RATE SET movieID = ?, theaterID = ?, pointsPerUnit = ? WHERE rateID = ?", rate.MovieID, rate.TheaterID, rate.PointsPerUnit, rate.RateID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		var exists bool
+		if err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM LOYALTY_EARN_RATE WHERE rateID = ?)", rate.RateID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrEarnRateNotFound
+		}
+	}
+	return nil
+}
+
+// DeleteEarnRate removes an earn rate, falling back to the theater or default rate
+func DeleteEarnRate(rateID int) error {
+	result, err := config.DB.Exec("DELETE FROM LOYALTY_EARN_RATE WHERE rateID = ?", rateID)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return ErrEarnRateNotFound
+	}
+	return nil
+}
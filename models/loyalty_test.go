@@ -0,0 +1,32 @@
+package models
+
+import (
+	"my-app/config"
+	"testing"
+)
+
+func TestApplyPointsChargesOnlyForTheDiscountApplied(t *testing.T) {
+	points := config.LoyaltyFreeTicketPoints
+
+	// The membership already made the dearer seat free, so the second free ticket covers nothing
+	quote := &PriceQuote{Seats: []SeatPrice{{Price: 1000}, {Price: 1400}}}
+	quote.Subtotal = 2400
+	quote.MembershipDiscount = 1400
+	if err := quote.applyPoints(CheckoutOptions{FreeTickets: 2}, 10*points); err != nil {
+		t.Fatal(err)
+	}
+	if quote.PointsDiscount != 1000 || quote.PointsRedeemed != points {
+		t.Fatalf("got a discount of %s for %d points, want %s for %d", quote.PointsDiscount, quote.PointsRedeemed, Money(1000), points)
+	}
+
+	// A promo code paid for most of the only seat, so the free ticket costs a share of its points
+	quote = &PriceQuote{Seats: []SeatPrice{{Price: 1000}}}
+	quote.Subtotal = 1000
+	quote.Discount = 750
+	if err := quote.applyPoints(CheckoutOptions{FreeTickets: 1}, points/4); err != nil {
+		t.Fatal(err)
+	}
+	if quote.PointsDiscount != 250 || quote.PointsRedeemed != points/4 {
+		t.Fatalf("got a discount of %s for %d points, want %s for %d", quote.PointsDiscount, quote.PointsRedeemed, Money(250), points/4)
+	}
+}
@@ -0,0 +1,485 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+	"sort"
+	"time"
+)
+
+// Membership statuses
+const (
+	MembershipActive = "ACTIVE"
+	MembershipLapsed = "LAPSED" // A renewal payment was declined
+	MembershipEnded  = "ENDED"  // Cancelled, or its plan withdrawn, and run to the end of its month
+)
+
+// Membership charge statuses
+const (
+	ChargePaid     = "PAID"
+	ChargeDeclined = "DECLINED"
+)
+
+// renewalBatchSize caps how many memberships one sweep renews
+const renewalBatchSize = 200
+
+// filmsPerBooking caps the included tickets one booking can take from the monthly allowance
+const filmsPerBooking = 1
+
+var (
+	ErrMembershipNotFound = errors.New("no membership found")
+	ErrAlreadyMember      = errors.New("user already has an active membership")
+)
+
+// Membership is a user's subscription to a plan. Each row is renewed month after month until it is
+// cancelled or a payment is declined; subscribing again after that starts a new one.
+type Membership struct {
+	MembershipID int                `json:"membership_id"`
+	UserID       int                `json:"user_id"`
+	Plan         MembershipPlan     `json:"plan"`
+	Status       string             `json:"status"`
+	AutoRenew    bool               `json:"auto_renew"` // Cleared by cancelling; the benefits last until PeriodEnd
+	PeriodStart  time.Time          `json:"period_start"`
+	PeriodEnd    time.Time          `json:"period_end"`
+	FilmsUsed    int                `json:"films_used"` // Included tickets taken this month
+	CancelledAt  *time.Time         `json:"cancelled_at"`
+	Charges      []MembershipCharge `json:"charges,omitempty"`
+}
+
+// MembershipCharge is one monthly payment for a membership
+type MembershipCharge struct {
+	ChargeID      int64     `json:"charge_id"`
+	Amount        Money     `json:"amount"`
+	PeriodStart   time.Time `json:"period_start"`
+	PeriodEnd     time.Time `json:"period_end"`
+	Status        string    `json:"status"` // PAID or DECLINED
+	Reference     string    `json:"reference,omitempty"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+const membershipColumns = `m.membershipID, m.userID, m.status, m.autoRenew, m.periodStart, m.periodEnd, m.filmsUsed, m.cancelledAt,
+    p.planID, p.name, COALESCE(p.description, ''), p.price, p.filmsPerMonth, p.discountPercent, p.freeBookingFee, p.active
+    FROM MEMBERSHIP m JOIN MEMBERSHIP_PLAN p ON p.planID = m.planID`
+
+func scanMembership(row rowScanner) (*Membership, error) {
+	var m Membership
+	var periodStart, periodEnd string
+	var cancelledAt sql.NullString
+	err := row.Scan(&m.MembershipID, &m.UserID, &m.Status, &m.AutoRenew, &periodStart, &periodEnd, &m.FilmsUsed, &cancelledAt,
+		&m.Plan.PlanID, &m.Plan.Name, &m.Plan.Description, &m.Plan.Price, &m.Plan.FilmsPerMonth, &m.Plan.DiscountPercent, &m.Plan.FreeBookingFee, &m.Plan.Active)
+	if err != nil {
+		return nil, err
+	}
+	if m.PeriodStart, err = time.ParseInLocation("2006-01-02 15:04:05", periodStart, time.Local); err != nil {
+		return nil, err
+	}
+	if m.PeriodEnd, err = time.ParseInLocation("2006-01-02 15:04:05", periodEnd, time.Local); err != nil {
+		return nil, err
+	}
+	if m.CancelledAt, err = parseNullTime(cancelledAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// current reports whether the membership's benefits apply at the given time
+func (m *Membership) current(now time.Time) bool {
+	return m.Status == MembershipActive && !now.Before(m.PeriodStart) && now.Before(m.PeriodEnd)
+}
+
+// latestMembership returns the user's most recent membership, locking it when asked
+func latestMembership(q queryRower, userID int, lock bool) (*Membership, error) {
+	query := "SELECT membershipID FROM MEMBERSHIP WHERE userID = ? ORDER BY membershipID DESC LIMIT 1"
+	if lock {
+		query += " FOR UPDATE"
+	}
+	var membershipID int
+	err := q.QueryRow(query, userID).Scan(&membershipID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMembershipNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scanMembership(q.QueryRow("SELECT "+membershipColumns+" WHERE m.membershipID = ?", membershipID))
+}
+
+// GetMembership retrieves the user's most recent membership with its charges, newest first
+func GetMembership(userID int) (*Membership, error) {
+	m, err := latestMembership(config.DB, userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := config.DB.Query(`
+        SELECT chargeID, amount, periodStart, periodEnd, status, COALESCE(reference, ''), COALESCE(failureReason, ''), createdAt
+        FROM MEMBERSHIP_CHARGE WHERE membershipID = ? ORDER BY chargeID DESC
+    `, m.MembershipID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m.Charges = []MembershipCharge{}
+	for rows.Next() {
+		var charge MembershipCharge
+		var periodStart, periodEnd, createdAt string
+		err := rows.Scan(&charge.ChargeID, &charge.Amount, &periodStart, &periodEnd, &charge.Status, &charge.Reference, &charge.FailureReason, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		if charge.PeriodStart, err = time.ParseInLocation("2006-01-02 15:04:05", periodStart, time.Local); err != nil {
+			return nil, err
+		}
+		if charge.PeriodEnd, err = time.ParseInLocation("2006-01-02 15:04:05", periodEnd, time.Local); err != nil {
+			return nil, err
+		}
+		if charge.CreatedAt, err = time.ParseInLocation("2006-01-02 15:04:05", createdAt, time.Local); err != nil {
+			return nil, err
+		}
+		m.Charges = append(m.Charges, charge)
+	}
+	return m, rows.Err()
+}
+
+// SubscribeMembership charges the first month of a plan and starts the membership at once
+func SubscribeMembership(userID, planID int) (*Membership, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the user's row so that two subscriptions cannot both start
+	var lockedUserID int
+	if err := tx.QueryRow("SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&lockedUserID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	now := time.Now().Truncate(time.Second)
+	existing, err := latestMembership(tx, userID, true)
+	if err != nil && err != ErrMembershipNotFound {
+		return nil, err
+	}
+	if existing != nil && existing.current(now) {
+		return nil, fmt.Errorf("%w: %s until %s", ErrAlreadyMember, existing.Plan.Name, existing.PeriodEnd.Format("2006-01-02"))
+	}
+
+	plan, err := membershipPlan(tx, planID)
+	if err != nil {
+		return nil, err
+	}
+	if !plan.Active {
+		return nil, errors.New("this plan is no longer offered")
+	}
+
+	m := &Membership{UserID: userID, Plan: *plan, Status: MembershipActive, AutoRenew: true, PeriodStart: now, PeriodEnd: now.AddDate(0, 1, 0)}
+	result, err := tx.Exec(
+		"INSERT INTO MEMBERSHIP (userID, planID, status, autoRenew, periodStart, periodEnd, filmsUsed, createdAt) VALUES (?, ?, ?, TRUE, ?, ?, 0, ?)",
+		userID, planID, m.Status, m.PeriodStart, m.PeriodEnd, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	m.MembershipID = int(id)
+
+	if err := chargeMembership(tx, m, m.PeriodStart, m.PeriodEnd); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetMembership(userID)
+}
+
+// RenewMembership undoes a cancellation while the month still runs, or restarts a lapsed or
+// ended membership straight away with a new payment
+func RenewMembership(userID int) (*Membership, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	m, err := latestMembership(tx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Truncate(time.Second)
+
+	if m.current(now) {
+		if m.AutoRenew {
+			return nil, fmt.Errorf("%w: it renews on %s", ErrAlreadyMember, m.PeriodEnd.Format("2006-01-02"))
+		}
+		if _, err := tx.Exec("UPDATE MEMBERSHIP SET autoRenew = TRUE, cancelledAt = NULL WHERE membershipID = ?", m.MembershipID); err != nil {
+			return nil, err
+		}
+	} else {
+		if !m.Plan.Active {
+			return nil, errors.New("this plan is no longer offered")
+		}
+		start, end := now, now.AddDate(0, 1, 0)
+		if err := chargeMembership(tx, m, start, end); err != nil {
+			return nil, err
+		}
+		_, err := tx.Exec(
+			"UPDATE MEMBERSHIP SET status = ?, autoRenew = TRUE, periodStart = ?, periodEnd = ?, filmsUsed = 0, cancelledAt = NULL WHERE membershipID = ?",
+			MembershipActive, start, end, m.MembershipID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetMembership(userID)
+}
+
+// CancelMembership stops a membership renewing. Its benefits last until the month ends.
+func CancelMembership(userID int) (*Membership, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	m, err := latestMembership(tx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if !m.current(time.Now()) {
+		return nil, fmt.Errorf("%w: membership is %s", ErrMembershipNotFound, m.Status)
+	}
+	if !m.AutoRenew {
+		return nil, errors.New("membership is already cancelled")
+	}
+	if _, err := tx.Exec("UPDATE MEMBERSHIP SET autoRenew = FALSE, cancelledAt = ? WHERE membershipID = ?", time.Now(), m.MembershipID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetMembership(userID)
+}
+
+// chargeMembership takes the plan's price for a month through the payment provider and records the
+// attempt. Any provider failure is returned as ErrPaymentDeclined; without a provider nothing is
+// charged or recorded and ErrNoPaymentProvider is returned.
+func chargeMembership(tx *sql.Tx, m *Membership, start, end time.Time) error {
+	gateway, err := paymentGateway()
+	if err != nil {
+		return err
+	}
+	description := fmt.Sprintf("%s membership %s to %s", m.Plan.Name, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	reference, chargeErr := gateway.Charge(m.UserID, m.Plan.Price, description)
+	if chargeErr != nil && !errors.Is(chargeErr, ErrPaymentDeclined) {
+		chargeErr = fmt.Errorf("%w: %v", ErrPaymentDeclined, chargeErr)
+	}
+
+	status, failure := ChargePaid, ""
+	if chargeErr != nil {
+		status, failure = ChargeDeclined, chargeErr.Error()
+	}
+	_, err = tx.Exec(
+		"INSERT INTO MEMBERSHIP_CHARGE (membershipID, amount, periodStart, periodEnd, status, reference, failureReason, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		m.MembershipID, m.Plan.Price, start, end, status, reference, failure, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	return chargeErr
+}
+
+// RenewDueMemberships renews the memberships whose month has run out: those still renewing are
+// charged for the next month, and the rest end. It returns how many were renewed, lapsed and ended.
+func RenewDueMemberships(now time.Time) (renewed, lapsed, ended int, err error) {
+	rows, err := config.DB.Query(
+		"SELECT membershipID FROM MEMBERSHIP WHERE status = ? AND periodEnd <= ? ORDER BY periodEnd LIMIT ?",
+		MembershipActive, now, renewalBatchSize,
+	)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var membershipIDs []int
+	for rows.Next() {
+		var membershipID int
+		if err := rows.Scan(&membershipID); err != nil {
+			rows.Close()
+			return 0, 0, 0, err
+		}
+		membershipIDs = append(membershipIDs, membershipID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, 0, err
+	}
+
+	for _, membershipID := range membershipIDs {
+		status, err := renewMembership(membershipID, now)
+		if err != nil {
+			return renewed, lapsed, ended, err
+		}
+		switch status {
+		case MembershipActive:
+			renewed++
+		case MembershipLapsed:
+			lapsed++
+		case MembershipEnded:
+			ended++
+		}
+	}
+	return renewed, lapsed, ended, nil
+}
+
+// renewMembership moves one due membership into its next month, or ends it, and returns its new status.
+// Nothing is returned when it was renewed or cancelled since it was listed.
+func renewMembership(membershipID int, now time.Time) (string, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow("SELECT membershipID FROM MEMBERSHIP WHERE membershipID = ? FOR UPDATE", membershipID).Scan(&membershipID); err != nil {
+		return "", err
+	}
+	m, err := scanMembership(tx.QueryRow("SELECT "+membershipColumns+" WHERE m.membershipID = ?", membershipID))
+	if err != nil {
+		return "", err
+	}
+	if m.Status != MembershipActive || m.PeriodEnd.After(now) {
+		return "", nil
+	}
+
+	status := MembershipActive
+	if !m.AutoRenew || !m.Plan.Active {
+		status = MembershipEnded
+		if _, err := tx.Exec("UPDATE MEMBERSHIP SET status = ? WHERE membershipID = ?", status, membershipID); err != nil {
+			return "", err
+		}
+	} else {
+		// The new month follows on from the last, unless the server was down for longer than a month
+		start := m.PeriodEnd
+		if !start.AddDate(0, 1, 0).After(now) {
+			start = now.Truncate(time.Second)
+		}
+		end := start.AddDate(0, 1, 0)
+
+		err := chargeMembership(tx, m, start, end)
+		switch {
+		case errors.Is(err, ErrPaymentDeclined):
+			status = MembershipLapsed
+			_, err = tx.Exec("UPDATE MEMBERSHIP SET status = ? WHERE membershipID = ?", status, membershipID)
+		case err == nil:
+			_, err = tx.Exec("UPDATE MEMBERSHIP SET periodStart = ?, periodEnd = ?, filmsUsed = 0 WHERE membershipID = ?", start, end, membershipID)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// currentMembership returns the membership whose benefits apply to the user's bookings now, or nil
+func currentMembership(q queryRower, userID int, lock bool) (*Membership, error) {
+	m, err := latestMembership(q, userID, lock)
+	if err == ErrMembershipNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !m.current(time.Now()) {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// applyMembership gives the member their plan's benefits: an included ticket left this month covers
+// the dearest seat, the other seats get the plan's discount, and the booking fee may go.
+func (quote *PriceQuote) applyMembership(m *Membership) {
+	if m == nil {
+		return
+	}
+	prices := make([]Money, len(quote.Seats))
+	for i, seat := range quote.Seats {
+		prices[i] = seat.Price
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] > prices[j] })
+
+	films := min(max(m.Plan.FilmsPerMonth-m.FilmsUsed, 0), filmsPerBooking, len(prices))
+	var discount Money
+	for i, price := range prices {
+		if i < films {
+			discount += price
+		} else {
+			discount += percentOf(price, m.Plan.DiscountPercent)
+		}
+	}
+	if discount == 0 && !m.Plan.FreeBookingFee {
+		return
+	}
+
+	quote.MembershipID = m.MembershipID
+	quote.MembershipFilms = films
+	quote.MembershipDiscount = discount
+	if m.Plan.FreeBookingFee {
+		quote.BookingFee = 0
+	}
+	quote.settle()
+}
+
+// useMembership takes the included tickets of a booking out of the member's monthly allowance
+func useMembership(tx *sql.Tx, quote *PriceQuote, bookingID int64) error {
+	if quote.MembershipID == 0 {
+		return nil
+	}
+	if _, err := tx.Exec("UPDATE MEMBERSHIP SET filmsUsed = filmsUsed + ? WHERE membershipID = ?", quote.MembershipFilms, quote.MembershipID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(
+		"INSERT INTO MEMBERSHIP_USAGE (membershipID, bookingID, films, discount, periodEnd, released) SELECT membershipID, ?, ?, ?, periodEnd, FALSE FROM MEMBERSHIP WHERE membershipID = ?",
+		bookingID, quote.MembershipFilms, quote.MembershipDiscount, quote.MembershipID,
+	)
+	return err
+}
+
+// releaseMembershipUsage gives a booking's included tickets back to the member's allowance,
+// as long as the month they were taken from is still running
+func releaseMembershipUsage(tx *sql.Tx, bookingID int64) error {
+	var released bool
+	err := tx.QueryRow("SELECT released FROM MEMBERSHIP_USAGE WHERE bookingID = ? FOR UPDATE", bookingID).Scan(&released)
+	if err == sql.ErrNoRows || (err == nil && released) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE MEMBERSHIP m
+        JOIN MEMBERSHIP_USAGE u ON u.membershipID = m.membershipID
+        SET m.filmsUsed = GREATEST(m.filmsUsed - u.films, 0)
+        WHERE u.bookingID = ? AND m.periodEnd = u.periodEnd
+    `, bookingID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE MEMBERSHIP_USAGE SET released = TRUE WHERE bookingID = ?", bookingID)
+	return err
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"my-app/config"
+	"strings"
+)
+
+var ErrPlanNotFound = errors.New("membership plan not found")
+
+// MembershipPlan is a monthly subscription. Its benefits apply to the member's own bookings:
+// a number of included tickets each month, a discount on the other tickets, and no booking fee.
+type MembershipPlan struct {
+	PlanID          int     `json:"plan_id"`
+	Name            string  `json:"name"`
+	Description     string  `json:"description"`
+	Price           Money   `json:"price"`            // Charged every month
+	FilmsPerMonth   int     `json:"films_per_month"`  // Tickets included each month; 0 for none
+	DiscountPercent float64 `json:"discount_percent"` // Off the tickets not included
+	FreeBookingFee  bool    `json:"free_booking_fee"`
+	Active          bool    `json:"active"` // Inactive plans take no new members and are not renewed
+}
+
+const membershipPlanColumns = "planID, name, COALESCE(description, ''), price, filmsPerMonth, discountPercent, freeBookingFee, active"
+
+func scanMembershipPlan(row rowScanner) (*MembershipPlan, error) {
+	var plan MembershipPlan
+	err := row.Scan(&plan.PlanID, &plan.Name, &plan.Description, &plan.Price, &plan.FilmsPerMonth, &plan.DiscountPercent, &plan.FreeBookingFee, &plan.Active)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// GetMembershipPlans retrieves every plan, optionally only those open to new members
+func GetMembershipPlans(activeOnly bool) ([]MembershipPlan, error) {
+	query := "SELECT " + membershipPlanColumns + " FROM MEMBERSHIP_PLAN"
+	if activeOnly {
+		query += " WHERE active = TRUE"
+	}
+	rows, err := config.DB.Query(query + " ORDER BY price, planID")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []MembershipPlan{}
+	for rows.Next() {
+		plan, err := scanMembershipPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, *plan)
+	}
+	return plans, rows.Err()
+}
+
+// GetMembershipPlan retrieves one plan
+func GetMembershipPlan(planID int) (*MembershipPlan, error) {
+	return membershipPlan(config.DB, planID)
+}
+
+func membershipPlan(q queryRower, planID int) (*MembershipPlan, error) {
+	plan, err := scanMembershipPlan(q.QueryRow("SELECT "+membershipPlanColumns+" FROM MEMBERSHIP_PLAN WHERE planID = ?", planID))
+	if err == sql.ErrNoRows {
+		return nil, ErrPlanNotFound
+	}
+	return plan, err
+}
+
+// CreateMembershipPlan adds a plan
+func CreateMembershipPlan(plan *MembershipPlan) error {
+	if err := plan.validate(); err != nil {
+		return err
+	}
+	result, err := config.DB.Exec(
+		"INSERT INTO MEMBERSHIP_PLAN (name, description, price, filmsPerMonth, discountPercent, freeBookingFee, active) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		plan.Name, plan.Description, plan.Price, plan.FilmsPerMonth, plan.DiscountPercent, plan.FreeBookingFee, plan.Active,
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	plan.PlanID = int(id)
+	return err
+}
+
+// UpdateMembershipPlan replaces a plan. Members get the new benefits at once and the new price from their next renewal.
+func UpdateMembershipPlan(plan *MembershipPlan) error {
+	if err := plan.validate(); err != nil {
+		return err
+	}
+	if _, err := GetMembershipPlan(plan.PlanID); err != nil {
+		return err
+	}
+	_, err := config.DB.Exec(
+		"UPDATE MEMBERSHIP_PLAN SET name = ?, description = ?, price = ?, filmsPerMonth = ?, discountPercent = ?, freeBookingFee = ?, active = ? WHERE planID = ?",
+		plan.Name, plan.Description, plan.Price, plan.FilmsPerMonth, plan.DiscountPercent, plan.FreeBookingFee, plan.Active, plan.PlanID,
+	)
+	return err
+}
+
+// DeactivateMembershipPlan closes a plan to new members. Current members keep it until their month ends.
+func DeactivateMembershipPlan(planID int) error {
+	result, err := config.DB.Exec("UPDATE MEMBERSHIP_PLAN SET active = FALSE WHERE planID = ?", planID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		if _, err := GetMembershipPlan(planID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (plan *MembershipPlan) validate() error {
+	plan.Name = strings.TrimSpace(plan.Name)
+	if plan.Name == "" {
+		return errors.New("name is required")
+	}
+	if plan.Price <= 0 {
+		return errors.New("price must be positive")
+	}
+	if plan.FilmsPerMonth < 0 {
+		return errors.New("films_per_month cannot be negative")
+	}
+	if plan.DiscountPercent < 0 || plan.DiscountPercent > 100 {
+		return errors.New("discount_percent must be between 0 and 100")
+	}
+	if plan.FilmsPerMonth == 0 && plan.DiscountPercent == 0 && !plan.FreeBookingFee {
+		return errors.New("a plan needs at least one benefit")
+	}
+	return nil
+}
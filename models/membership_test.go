@@ -0,0 +1,160 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func createTestPlan(t *testing.T) *MembershipPlan {
+	t.Helper()
+	plan := &MembershipPlan{Name: fmt.Sprintf("Test plan %d", time.Now().UnixNano()), Price: 1500, FilmsPerMonth: 4, Active: true}
+	if err := CreateMembershipPlan(plan); err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+func TestRenewDueMembershipsChargesTheNextMonth(t *testing.T) {
+	requireDB(t)
+	fake := useFakePaymentProvider(t)
+	userID := createTestUser(t)
+	plan := createTestPlan(t)
+
+	first, err := SubscribeMembership(userID, plan.PlanID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := RenewDueMemberships(first.PeriodEnd.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := GetMembership(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.Status != MembershipActive {
+		t.Fatalf("status = %s, want %s", renewed.Status, MembershipActive)
+	}
+	if !renewed.PeriodStart.Equal(first.PeriodEnd) || !renewed.PeriodEnd.Equal(first.PeriodEnd.AddDate(0, 1, 0)) {
+		t.Fatalf("period = %s to %s, want the month after %s", renewed.PeriodStart, renewed.PeriodEnd, first.PeriodEnd)
+	}
+	if len(renewed.Charges) != 2 || renewed.Charges[0].Status != ChargePaid {
+		t.Fatalf("charges = %+v, want the sign-up and a paid renewal", renewed.Charges)
+	}
+
+	charges := customerCharges(fake, userID)
+	if len(charges) != 2 {
+		t.Fatalf("provider charged %d times, want 2", len(charges))
+	}
+	for _, charge := range charges {
+		if charge.Amount != plan.Price || charge.Started {
+			t.Fatalf("charge = %+v, want %s taken by the server", charge, plan.Price)
+		}
+	}
+}
+
+func TestRenewDueMembershipsLapsesDeclinedPayments(t *testing.T) {
+	requireDB(t)
+	fake := useFakePaymentProvider(t)
+	userID := createTestUser(t)
+	plan := createTestPlan(t)
+
+	first, err := SubscribeMembership(userID, plan.PlanID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Decline(userID, true)
+	if _, _, _, err := RenewDueMemberships(first.PeriodEnd.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	lapsed, err := GetMembership(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lapsed.Status != MembershipLapsed {
+		t.Fatalf("status = %s, want %s", lapsed.Status, MembershipLapsed)
+	}
+	if !lapsed.PeriodEnd.Equal(first.PeriodEnd) {
+		t.Fatalf("period end moved to %s on a declined renewal", lapsed.PeriodEnd)
+	}
+	if len(lapsed.Charges) != 2 || lapsed.Charges[0].Status != ChargeDeclined {
+		t.Fatalf("charges = %+v, want a declined renewal recorded", lapsed.Charges)
+	}
+	if charges := customerCharges(fake, userID); len(charges) != 1 {
+		t.Fatalf("provider charged %d times, want only the sign-up", len(charges))
+	}
+
+	// Paying again restarts the membership straight away
+	fake.Decline(userID, false)
+	restarted, err := RenewMembership(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Status != MembershipActive {
+		t.Fatalf("status after renewing = %s, want %s", restarted.Status, MembershipActive)
+	}
+}
+
+func TestSubscribeMembershipDeclined(t *testing.T) {
+	requireDB(t)
+	fake := useFakePaymentProvider(t)
+	userID := createTestUser(t)
+	plan := createTestPlan(t)
+
+	fake.Decline(userID, true)
+	if _, err := SubscribeMembership(userID, plan.PlanID); !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("err = %v, want %v", err, ErrPaymentDeclined)
+	}
+	if _, err := GetMembership(userID); !errors.Is(err, ErrMembershipNotFound) {
+		t.Fatalf("membership started on a declined payment: %v", err)
+	}
+}
+
+func TestSubscribeMembershipWithoutProvider(t *testing.T) {
+	requireDB(t)
+	previous := PaymentGateway
+	PaymentGateway = nil
+	t.Cleanup(func() { PaymentGateway = previous })
+	userID := createTestUser(t)
+	plan := createTestPlan(t)
+
+	if _, err := SubscribeMembership(userID, plan.PlanID); !errors.Is(err, ErrNoPaymentProvider) {
+		t.Fatalf("err = %v, want %v", err, ErrNoPaymentProvider)
+	}
+	if _, err := GetMembership(userID); !errors.Is(err, ErrMembershipNotFound) {
+		t.Fatalf("membership started without a payment provider: %v", err)
+	}
+}
+
+func TestApplyMembershipIncludesOneFilmPerBooking(t *testing.T) {
+	quote := &PriceQuote{Seats: []SeatPrice{{Price: 1000}, {Price: 1400}, {Price: 1000}}}
+	quote.Subtotal = 3400
+	member := &Membership{MembershipID: 7, Plan: MembershipPlan{FilmsPerMonth: 4, DiscountPercent: 10}}
+
+	quote.applyMembership(member)
+
+	if quote.MembershipFilms != 1 {
+		t.Fatalf("films = %d, want 1", quote.MembershipFilms)
+	}
+	// The dearest seat is included and the other two get 10% off
+	if quote.MembershipDiscount != 1400+100+100 {
+		t.Fatalf("discount = %s, want %s", quote.MembershipDiscount, Money(1600))
+	}
+	if quote.Total != 1800 {
+		t.Fatalf("total = %s, want %s", quote.Total, Money(1800))
+	}
+}
+
+// customerCharges returns the fake provider's charges of one customer
+func customerCharges(fake *FakePaymentProvider, customerID int) []FakeCharge {
+	var charges []FakeCharge
+	for _, charge := range fake.Charges() {
+		if charge.CustomerID == customerID {
+			charges = append(charges, charge)
+		}
+	}
+	return charges
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// Money is an amount in minor currency units (cents), so sums and splits never drift.
+// JSON carries the same integer: 12.50 is sent and received as 1250.
+type Money int64
+
+// String formats the amount in major units, e.g. 1250 as "12.50"
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign, m = "-", -m
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, m/100, m%100)
+}
+
+// percentOf returns percent of the amount, rounded to the nearest minor unit
+func percentOf(amount Money, percent float64) Money {
+	return Money(math.Round(float64(amount) * percent / 100))
+}
+
+// basisPointsOf returns rate/10000 of the amount, rounding halves away from zero
+func basisPointsOf(amount Money, rate int) Money {
+	product := int64(amount) * int64(rate)
+	if product < 0 {
+		return Money((product - 5000) / 10000)
+	}
+	return Money((product + 5000) / 10000)
+}
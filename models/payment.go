@@ -1,47 +1,476 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
+	"log"
 	"my-app/config"
+	"strings"
+	"time"
 )
 
+// Payment methods
+const (
+	PaymentMethodCard     = "CARD" // Taken by the external payment provider
+	PaymentMethodGiftCard = "GIFT_CARD"
+	PaymentMethodWallet   = "WALLET"
+)
+
+// Payment statuses. Card payments start PENDING and are settled by the provider's callback or an admin;
+// gift cards and wallets are debited at once. Paid amounts of a booking that is never confirmed are
+// REVERSED: gift card payments back onto their card and the rest into the customer's wallet.
+const (
+	PaymentPending  = "PENDING"
+	PaymentPaid     = "PAID"
+	PaymentFailed   = "FAILED"
+	PaymentReversed = "REVERSED"
+)
+
+var (
+	ErrPaymentMismatch      = errors.New("payment does not match the amount due")
+	ErrPaymentNotFound      = errors.New("payment not found")
+	ErrPaymentInProgress    = errors.New("a card payment for this booking is already awaiting the provider")
+	ErrPaymentSettled       = errors.New("payment has already been settled")
+	ErrBookingNotPayable    = errors.New("booking is not awaiting payment")
+	ErrInvalidPaymentStatus = errors.New("payments can only be settled as PAID or FAILED")
+)
+
+// ProviderActor is recorded for changes made by the payment provider's callbacks
+var ProviderActor = Actor{Role: "provider"}
+
+// Payment is a customer's request to pay for a booking, or for a seat hold which then becomes a booking.
+// The amount due is worked out by the server; the customer only chooses how to pay it.
 type Payment struct {
-	BookingID     int     `json:"booking_id"`
-	Amount        float64 `json:"amount"`
-	PaymentStatus string  `json:"payment_status"` // PAID or PENDING
+	BookingID int            `json:"booking_id"`
+	HoldID    string         `json:"hold_id,omitempty"` // Pay for a seat hold, turning it into a booking
+	Amount    Money          `json:"amount"`            // Optional; rejected unless it is the amount due
+	Splits    []PaymentSplit `json:"splits,omitempty"`  // Pay with several methods; without splits the amount due is one CARD payment
+}
+
+// PaymentSplit is the part of a payment made with one method
+type PaymentSplit struct {
+	Method       string `json:"method"` // CARD, GIFT_CARD or WALLET
+	Amount       Money  `json:"amount"`
+	GiftCardCode string `json:"gift_card_code,omitempty"`
+}
+
+// PaymentRecord is one stored part of a booking's payment
+type PaymentRecord struct {
+	PaymentID         int64  `json:"payment_id"`
+	BookingID         int64  `json:"booking_id"`
+	Method            string `json:"method"`
+	Amount            Money  `json:"amount"`
+	Status            string `json:"status"`
+	GiftCardID        *int   `json:"gift_card_id,omitempty"`
+	ProviderReference string `json:"provider_reference,omitempty"` // Completes the card payment with the provider
+}
+
+// PaymentResult is the outcome of a payment request
+type PaymentResult struct {
+	BookingID     int64           `json:"booking_id"`
+	Status        string          `json:"status"` // PAID, PENDING while a card payment awaits the provider, or FAILED when the provider refused it
+	AmountDue     Money           `json:"amount_due"`
+	Payments      []PaymentRecord `json:"payments"`
+	FailureReason string          `json:"failure_reason,omitempty"`
+}
+
+// tenders checks the payment against the amount due and returns its splits. Without splits
+// the whole amount is paid by card.
+func (payment *Payment) tenders(due Money) ([]PaymentSplit, error) {
+	if payment.Amount != 0 && payment.Amount != due {
+		return nil, fmt.Errorf("%w: paying %s, the amount due is %s", ErrPaymentMismatch, payment.Amount, due)
+	}
+	if len(payment.Splits) == 0 {
+		if due == 0 {
+			return nil, nil
+		}
+		return []PaymentSplit{{Method: PaymentMethodCard, Amount: due}}, nil
+	}
+
+	var sum Money
+	cards := 0
+	for i := range payment.Splits {
+		split := &payment.Splits[i]
+		split.Method = strings.ToUpper(strings.TrimSpace(split.Method))
+		switch split.Method {
+		case PaymentMethodCard:
+			cards++
+		case PaymentMethodGiftCard:
+			if split.GiftCardCode == "" {
+				return nil, errors.New("gift card payments need a gift_card_code")
+			}
+		case PaymentMethodWallet:
+		default:
+			return nil, fmt.Errorf("invalid payment method %q", split.Method)
+		}
+		if split.Amount <= 0 {
+			return nil, errors.New("split amounts must be positive")
+		}
+		sum += split.Amount
+	}
+	if cards > 1 {
+		return nil, errors.New("a payment can have only one card split")
+	}
+	if sum != due {
+		return nil, fmt.Errorf("%w: splits add up to %s, the amount due is %s", ErrPaymentMismatch, sum, due)
+	}
+	return payment.Splits, nil
 }
 
-// ProcessPayment processes the payment for a booking
-func ProcessPayment(userID int, payment Payment) error {
-	// Check if the booking exists and belongs to the user
-	var bookingExists bool
-	err := config.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM BOOKING WHERE bookingID = ? AND userID = ?)", payment.BookingID, userID,
-	).Scan(&bookingExists)
+// ProcessPayment pays for a booking, or for a seat hold which then becomes a PENDING booking.
+// Gift cards and wallets are debited at once; a card payment is recorded as started, then opened
+// with the provider once the booking's rows are unlocked, and confirms the booking when the
+// provider calls back. A payment a provider never answers fails when its booking expires.
+// Without a card split the booking is confirmed straight away.
+func ProcessPayment(userID int, payment Payment) (*PaymentResult, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
+	var hold *SeatHold
+	if payment.HoldID != "" {
+		var bookingID int64
+		bookingID, hold, err = confirmHold(tx, userID, payment.HoldID)
+		if err != nil {
+			return nil, err
+		}
+		payment.BookingID = int(bookingID)
+	}
+	bookingID := int64(payment.BookingID)
+
+	// Only the owner's unpaid bookings can be paid, one attempt at a time
+	var status string
+	err = tx.QueryRow(
+		"SELECT COALESCE(status, ?) FROM BOOKING WHERE bookingID = ? AND userID = ? FOR UPDATE", BookingStatusPending, bookingID, userID,
+	).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("invalid booking ID or booking does not belong to the user")
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if status != BookingStatusPending {
+		return nil, fmt.Errorf("%w: booking is %s", ErrBookingNotPayable, status)
+	}
+	var inProgress bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM PAYMENT WHERE bookingID = ? AND paymentStatus = ? AND startedAt IS NOT NULL)", bookingID, PaymentPending).Scan(&inProgress); err != nil {
+		return nil, err
 	}
-	if !bookingExists {
-		return errors.New("invalid booking ID or booking does not belong to the user")
+	if inProgress {
+		return nil, ErrPaymentInProgress
 	}
 
-	// Update the payment status in the database
-	_, err = config.DB.Exec(
-		"INSERT INTO PAYMENT (bookingID, amount, paymentStatus) VALUES (?, ?, ?)",
-		payment.BookingID, payment.Amount, payment.PaymentStatus,
+	due, err := amountDue(tx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	splits, err := payment.tenders(due)
+	if err != nil {
+		return nil, err
+	}
+
+	var gateway PaymentProvider
+	for _, split := range splits {
+		if split.Method == PaymentMethodCard {
+			if gateway, err = paymentGateway(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Debit any stored value and record each part of the payment; a card payment is only marked
+	// as started, as the provider is not called while the booking is locked
+	actor := Actor{UserID: userID, Role: "user"}
+	result := &PaymentResult{BookingID: bookingID, Status: PaymentPaid, AmountDue: due, Payments: []PaymentRecord{}}
+	card := -1
+	for _, split := range splits {
+		record := PaymentRecord{BookingID: bookingID, Method: split.Method, Amount: split.Amount, Status: PaymentPaid}
+		switch split.Method {
+		case PaymentMethodGiftCard:
+			id, err := debitGiftCard(tx, split.GiftCardCode, split.Amount, bookingID, actor)
+			if err != nil {
+				return nil, err
+			}
+			record.GiftCardID = &id
+		case PaymentMethodWallet:
+			if err := debitWallet(tx, userID, split.Amount, bookingID, actor); err != nil {
+				return nil, err
+			}
+		case PaymentMethodCard:
+			record.Status = PaymentPending
+			result.Status = PaymentPending
+			card = len(result.Payments)
+		}
+
+		var startedAt *time.Time
+		if record.Method == PaymentMethodCard {
+			now := time.Now()
+			startedAt = &now
+		}
+		inserted, err := tx.Exec(
+			"INSERT INTO PAYMENT (bookingID, amount, paymentStatus, method, giftCardID, startedAt) VALUES (?, ?, ?, ?, ?, ?)",
+			bookingID, record.Amount, record.Status, record.Method, record.GiftCardID, startedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if record.PaymentID, err = inserted.LastInsertId(); err != nil {
+			return nil, err
+		}
+		result.Payments = append(result.Payments, record)
+	}
+
+	// A payment settled in full confirms the booking
+	if result.Status == PaymentPaid {
+		if _, err := transitionBooking(tx, bookingID, BookingStatusConfirmed, actor); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if hold != nil {
+		if err := dropHold(hold); err != nil {
+			log.Printf("Error dropping hold %s after payment: %v", hold.HoldID, err)
+		}
+		publishSeatChanges(hold.ScheduleID, hold.SeatIDs, SeatStatusBooked)
+	}
+
+	if card >= 0 {
+		record := &result.Payments[card]
+		reference, err := gateway.CreatePayment(userID, record.Amount, fmt.Sprintf("Booking #%d", bookingID))
+		if err != nil {
+			return refusedCardPayment(result, record.PaymentID, actor, err)
+		}
+		// Stored even if the payment has failed meanwhile, so a late callback still finds it
+		if _, err := config.DB.Exec("UPDATE PAYMENT SET providerRef = ? WHERE paymentID = ?", reference, record.PaymentID); err != nil {
+			return nil, err
+		}
+		record.ProviderReference = reference
+	}
+
+	return result, nil
+}
+
+// refusedCardPayment fails a card payment the provider would not start, giving back what else was
+// paid. The booking stays PENDING, so the customer can pay for it again before it expires.
+func refusedCardPayment(result *PaymentResult, paymentID int64, actor Actor, refusal error) (*PaymentResult, error) {
+	if err := SettlePayment(paymentID, PaymentFailed, actor); err != nil {
+		return nil, err
+	}
+	payments, err := GetBookingPayments(result.BookingID)
+	if err != nil {
+		return nil, err
+	}
+	result.Status = PaymentFailed
+	result.FailureReason = refusal.Error()
+	result.Payments = payments
+	return result, nil
+}
+
+// amountDue is what a booking costs, as priced by the server when it was made. Bookings made
+// before totals were stored are charged their seats' prices less any promo discount.
+func amountDue(q queryRower, bookingID int64) (Money, error) {
+	var total sql.NullInt64
+	var discount Money
+	if err := q.QueryRow("SELECT total, COALESCE(discount, 0) FROM BOOKING WHERE bookingID = ?", bookingID).Scan(&total, &discount); err != nil {
+		return 0, err
+	}
+	if total.Valid {
+		return Money(total.Int64), nil
+	}
+
+	var fares Money
+	err := q.QueryRow(`
+        SELECT COALESCE(SUM(COALESCE(bs.price, s.fare, 0)), 0)
+        FROM BOOKING_SEAT bs
+        JOIN BOOKING b ON b.bookingID = bs.bookingID
+        LEFT JOIN SCHEDULE s ON s.scheduleID = b.scheduleID
+        WHERE bs.bookingID = ?
+    `, bookingID).Scan(&fares)
+	return max(fares-discount, 0), err
+}
+
+// GetBookingPayments retrieves every recorded part of a booking's payments, oldest first
+func GetBookingPayments(bookingID int64) ([]PaymentRecord, error) {
+	rows, err := config.DB.Query(
+		"SELECT paymentID, bookingID, COALESCE(method, ?), amount, paymentStatus, giftCardID, COALESCE(providerRef, '') FROM PAYMENT WHERE bookingID = ? ORDER BY paymentID",
+		PaymentMethodCard, bookingID,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []PaymentRecord{}
+	for rows.Next() {
+		var record PaymentRecord
+		var giftCardID sql.NullInt64
+		if err := rows.Scan(&record.PaymentID, &record.BookingID, &record.Method, &record.Amount, &record.Status, &giftCardID, &record.ProviderReference); err != nil {
+			return nil, err
+		}
+		record.GiftCardID = nullIntPtr(giftCardID)
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// SettleProviderPayment applies the provider's callback for the card payment with the given reference
+func SettleProviderPayment(reference, status string) error {
+	var paymentID int64
+	err := config.DB.QueryRow("SELECT paymentID FROM PAYMENT WHERE providerRef = ?", reference).Scan(&paymentID)
+	if err == sql.ErrNoRows {
+		return ErrPaymentNotFound
+	}
 	if err != nil {
 		return err
 	}
+	return SettlePayment(paymentID, status, ProviderActor)
+}
 
-	// Optionally, update the booking status to reflect that payment has been made
-	if payment.PaymentStatus == "PAID" {
-		_, err = config.DB.Exec("UPDATE BOOKING SET paymentStatus = ? WHERE bookingID = ?", "PAID", payment.BookingID)
-		if err != nil {
+// SettlePayment marks a pending card payment PAID or FAILED. A booking is confirmed once all of
+// its payment is PAID; a failed payment gives back what else was paid, so the customer can try
+// again while the booking waits. Repeating a settlement is harmless, as providers retry callbacks.
+func SettlePayment(paymentID int64, status string, actor Actor) error {
+	status = strings.ToUpper(strings.TrimSpace(status))
+	if status != PaymentPaid && status != PaymentFailed {
+		return ErrInvalidPaymentStatus
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the booking before its payments, as transitionBooking does
+	var bookingID int64
+	if err := tx.QueryRow("SELECT bookingID FROM PAYMENT WHERE paymentID = ?", paymentID).Scan(&bookingID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPaymentNotFound
+		}
+		return err
+	}
+	var bookingStatus string
+	var ownerID int
+	err = tx.QueryRow("SELECT COALESCE(status, ?), userID FROM BOOKING WHERE bookingID = ? FOR UPDATE", BookingStatusPending, bookingID).Scan(&bookingStatus, &ownerID)
+	if err != nil {
+		return err
+	}
+	var current string
+	var amount Money
+	if err := tx.QueryRow("SELECT paymentStatus, amount FROM PAYMENT WHERE paymentID = ? FOR UPDATE", paymentID).Scan(&current, &amount); err != nil {
+		return err
+	}
+
+	switch {
+	case current == status || (current == PaymentReversed && status == PaymentPaid):
+		return nil
+	case current == PaymentFailed && status == PaymentPaid:
+		// The card was charged after the payment was given up, so the money goes to the wallet
+		if _, err := creditWallet(tx, ownerID, amount, LedgerRefund, &bookingID, actor, "card payment completed after it was abandoned"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE PAYMENT SET paymentStatus = ? WHERE paymentID = ?", PaymentReversed, paymentID); err != nil {
+			return err
+		}
+	case current != PaymentPending:
+		return fmt.Errorf("%w: payment is %s", ErrPaymentSettled, current)
+	default:
+		if _, err := tx.Exec("UPDATE PAYMENT SET paymentStatus = ? WHERE paymentID = ?", status, paymentID); err != nil {
+			return err
+		}
+		if status == PaymentFailed {
+			if err := reversePayments(tx, bookingID, ownerID, actor, "card payment failed"); err != nil {
+				return err
+			}
+		} else if err := confirmIfPaid(tx, bookingID, bookingStatus, actor); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// confirmIfPaid confirms a pending booking once none of its card payments await the provider
+// and what was paid covers the amount due
+func confirmIfPaid(tx *sql.Tx, bookingID int64, bookingStatus string, actor Actor) error {
+	if bookingStatus != BookingStatusPending {
+		return nil
+	}
+	var pending bool
+	var paid Money
+	err := tx.QueryRow(
+		"SELECT COALESCE(SUM(paymentStatus = ? AND startedAt IS NOT NULL), 0) > 0, COALESCE(SUM(CASE WHEN paymentStatus = ? THEN amount ELSE 0 END), 0) FROM PAYMENT WHERE bookingID = ?",
+		PaymentPending, PaymentPaid, bookingID,
+	).Scan(&pending, &paid)
+	if err != nil {
+		return err
+	}
+	due, err := amountDue(tx, bookingID)
+	if err != nil {
+		return err
+	}
+	if pending || paid < due {
+		return nil
+	}
+	_, err = transitionBooking(tx, bookingID, BookingStatusConfirmed, actor)
+	return err
+}
+
+// reversePayments gives up on a booking's payment: pending card payments fail, and whatever was
+// already paid is given back. Gift card payments go back to their card; the rest, and anything on
+// a card that can no longer be used, is credited to the customer's wallet.
+func reversePayments(tx *sql.Tx, bookingID int64, ownerID int, actor Actor, note string) error {
+	if _, err := tx.Exec("UPDATE PAYMENT SET paymentStatus = ? WHERE bookingID = ? AND paymentStatus = ?", PaymentFailed, bookingID, PaymentPending); err != nil {
+		return err
+	}
+	rows, err := tx.Query("SELECT COALESCE(method, ?), amount, giftCardID FROM PAYMENT WHERE bookingID = ? AND paymentStatus = ? FOR UPDATE", PaymentMethodCard, bookingID, PaymentPaid)
+	if err != nil {
+		return err
+	}
+	var paid []PaymentRecord
+	for rows.Next() {
+		var record PaymentRecord
+		var giftCardID sql.NullInt64
+		if err := rows.Scan(&record.Method, &record.Amount, &giftCardID); err != nil {
+			rows.Close()
+			return err
+		}
+		record.GiftCardID = nullIntPtr(giftCardID)
+		paid = append(paid, record)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(paid) == 0 {
+		return nil
+	}
+
+	var toWallet Money
+	for _, record := range paid {
+		if record.Method == PaymentMethodGiftCard && record.GiftCardID != nil {
+			refunded, err := refundGiftCard(tx, *record.GiftCardID, record.Amount, bookingID, actor, note)
+			if err != nil {
+				return err
+			}
+			if refunded {
+				continue
+			}
+		}
+		toWallet += record.Amount
+	}
+	if toWallet > 0 {
+		if _, err := creditWallet(tx, ownerID, toWallet, LedgerRefund, &bookingID, actor, note); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("UPDATE PAYMENT SET paymentStatus = ? WHERE bookingID = ? AND paymentStatus = ?", PaymentReversed, bookingID, PaymentPaid)
+	return err
 }
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"my-app/config"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPaymentDeclined   = errors.New("payment declined by the provider")
+	ErrNoPaymentProvider = errors.New("no payment provider is configured")
+)
+
+// PaymentProvider takes card payments.
+// CreatePayment starts a payment the customer completes with the provider; its outcome arrives later
+// at the payment callback under the returned reference. Charge takes a payment the server starts
+// itself, such as a membership renewal, and returns its reference or ErrPaymentDeclined.
+type PaymentProvider interface {
+	CreatePayment(customerID int, amount Money, description string) (string, error)
+	Charge(customerID int, amount Money, description string) (string, error)
+}
+
+// PaymentGateway is the provider in use, set at startup from config.PaymentProvider.
+// While it is nil nothing is charged: card payments and membership charges are refused.
+var PaymentGateway PaymentProvider
+
+// paymentGateway returns the provider in use, or ErrNoPaymentProvider
+func paymentGateway() (PaymentProvider, error) {
+	if PaymentGateway == nil {
+		return nil, ErrNoPaymentProvider
+	}
+	return PaymentGateway, nil
+}
+
+// VerifyPaymentCallback checks that a callback body was signed by the provider with
+// config.PaymentWebhookSecret: the signature is the hex HMAC-SHA256 of the raw body.
+func VerifyPaymentCallback(body []byte, signature string) bool {
+	if config.PaymentWebhookSecret == "" {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(config.PaymentWebhookSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// FakeCharge is a payment taken or started by the fake provider
+type FakeCharge struct {
+	Reference   string    `json:"reference"`
+	CustomerID  int       `json:"customer_id"`
+	Amount      Money     `json:"amount"`
+	Description string    `json:"description"`
+	Started     bool      `json:"started"` // Created for the customer to complete, rather than charged
+	ChargedAt   time.Time `json:"charged_at"`
+}
+
+// FakePaymentProvider approves every payment in memory, except for customers told to decline.
+// Its references are random, like a real provider's, so they stay unique across restarts.
+// Started payments are completed by posting a signed callback, as the real provider would.
+// It takes no money, so it is only for tests and local development.
+type FakePaymentProvider struct {
+	mu       sync.Mutex
+	declined map[int]bool
+	charges  []FakeCharge
+}
+
+func NewFakePaymentProvider() *FakePaymentProvider {
+	return &FakePaymentProvider{declined: make(map[int]bool)}
+}
+
+func (p *FakePaymentProvider) CreatePayment(customerID int, amount Money, description string) (string, error) {
+	return p.record(customerID, amount, description, true)
+}
+
+func (p *FakePaymentProvider) Charge(customerID int, amount Money, description string) (string, error) {
+	return p.record(customerID, amount, description, false)
+}
+
+func (p *FakePaymentProvider) record(customerID int, amount Money, description string, started bool) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if amount <= 0 {
+		return "", errors.New("charge amount must be positive")
+	}
+	if p.declined[customerID] {
+		return "", fmt.Errorf("%w: card of customer %d was declined", ErrPaymentDeclined, customerID)
+	}
+	charge := FakeCharge{
+		Reference:   "fake_ch_" + uuid.NewString(),
+		CustomerID:  customerID,
+		Amount:      amount,
+		Description: description,
+		Started:     started,
+		ChargedAt:   time.Now(),
+	}
+	p.charges = append(p.charges, charge)
+	return charge.Reference, nil
+}
+
+// Decline makes every later payment of the customer fail, or succeed again
+func (p *FakePaymentProvider) Decline(customerID int, declined bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.declined[customerID] = declined
+}
+
+// Charges returns every payment taken or started so far, oldest first
+func (p *FakePaymentProvider) Charges() []FakeCharge {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]FakeCharge(nil), p.charges...)
+}
@@ -0,0 +1,18 @@
+package models
+
+import "testing"
+
+func TestFakePaymentProviderReferencesSurviveRestarts(t *testing.T) {
+	// Each provider stands for one run of the server
+	first, err := NewFakePaymentProvider().CreatePayment(1, 1000, "Booking #1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewFakePaymentProvider().CreatePayment(1, 1000, "Booking #2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("two runs both issued reference %s", first)
+	}
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"my-app/config"
+	"testing"
+	"time"
+)
+
+// createTestBooking adds a PENDING booking of the user with the given total and returns its ID
+func createTestBooking(t *testing.T, userID int, total Money) int64 {
+	t.Helper()
+	result, err := config.DB.Exec(
+		"INSERT INTO BOOKING (userID, movieID, screenID, bookingDate, seatsBooked, status, expiresAt, subtotal, total) VALUES (?, 0, 0, ?, 0, ?, ?, ?, ?)",
+		userID, time.Now(), BookingStatusPending, time.Now().Add(time.Hour), total, total,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestProcessPaymentStartsCardPaymentAfterRecordingIt(t *testing.T) {
+	requireDB(t)
+	fake := useFakePaymentProvider(t)
+	userID := createTestUser(t)
+	bookingID := createTestBooking(t, userID, 2500)
+	if _, err := CreditWallet(userID, 1000, Actor{Role: "admin"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ProcessPayment(userID, Payment{BookingID: int(bookingID), Splits: []PaymentSplit{
+		{Method: PaymentMethodWallet, Amount: 1000},
+		{Method: PaymentMethodCard, Amount: 1500},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != PaymentPending {
+		t.Fatalf("status = %s, want %s", result.Status, PaymentPending)
+	}
+	charges := customerCharges(fake, userID)
+	if len(charges) != 1 || charges[0].Amount != 1500 {
+		t.Fatalf("charges = %+v, want one of 1500", charges)
+	}
+	payments, err := GetBookingPayments(bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payments[1].ProviderReference != charges[0].Reference {
+		t.Fatalf("stored reference = %q, want %q", payments[1].ProviderReference, charges[0].Reference)
+	}
+
+	if _, err := ProcessPayment(userID, Payment{BookingID: int(bookingID)}); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("paying again: err = %v, want %v", err, ErrPaymentInProgress)
+	}
+	if err := SettleProviderPayment(charges[0].Reference, PaymentPaid); err != nil {
+		t.Fatal(err)
+	}
+	if status := bookingStatus(t, bookingID); status != BookingStatusConfirmed {
+		t.Fatalf("booking status = %s, want %s", status, BookingStatusConfirmed)
+	}
+}
+
+func TestProcessPaymentRefusedByProviderGivesBackStoredValue(t *testing.T) {
+	requireDB(t)
+	fake := useFakePaymentProvider(t)
+	userID := createTestUser(t)
+	bookingID := createTestBooking(t, userID, 2500)
+	if _, err := CreditWallet(userID, 1000, Actor{Role: "admin"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	fake.Decline(userID, true)
+
+	result, err := ProcessPayment(userID, Payment{BookingID: int(bookingID), Splits: []PaymentSplit{
+		{Method: PaymentMethodWallet, Amount: 1000},
+		{Method: PaymentMethodCard, Amount: 1500},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != PaymentFailed || result.FailureReason == "" {
+		t.Fatalf("result = %+v, want a FAILED payment with its reason", result)
+	}
+	if balance := walletBalance(t, userID); balance != 1000 {
+		t.Fatalf("wallet balance = %s, want %s", balance, Money(1000))
+	}
+	if status := bookingStatus(t, bookingID); status != BookingStatusPending {
+		t.Fatalf("booking status = %s, want %s", status, BookingStatusPending)
+	}
+
+	// The booking can be paid again once the card goes through
+	fake.Decline(userID, false)
+	result, err = ProcessPayment(userID, Payment{BookingID: int(bookingID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != PaymentPending {
+		t.Fatalf("status = %s, want %s", result.Status, PaymentPending)
+	}
+}
+
+func TestRefusedCardPaymentGivesGiftCardPaymentBackToTheCard(t *testing.T) {
+	requireDB(t)
+	fake := useFakePaymentProvider(t)
+	userID := createTestUser(t)
+	bookingID := createTestBooking(t, userID, 2500)
+	card := &GiftCard{InitialBalance: 1000}
+	if err := IssueGiftCard(card, Actor{Role: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	fake.Decline(userID, true)
+
+	result, err := ProcessPayment(userID, Payment{BookingID: int(bookingID), Splits: []PaymentSplit{
+		{Method: PaymentMethodGiftCard, Amount: 1000, GiftCardCode: card.Code},
+		{Method: PaymentMethodCard, Amount: 1500},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != PaymentFailed {
+		t.Fatalf("status = %s, want %s", result.Status, PaymentFailed)
+	}
+
+	refunded, err := GetGiftCard(card.GiftCardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunded.Balance != 1000 {
+		t.Fatalf("gift card balance = %s, want %s", refunded.Balance, Money(1000))
+	}
+	if last := refunded.Ledger[len(refunded.Ledger)-1]; last.Reason != LedgerRefund || last.Amount != 1000 {
+		t.Fatalf("last ledger entry = %+v, want a refund of 1000", last)
+	}
+	if balance := walletBalance(t, userID); balance != 0 {
+		t.Fatalf("wallet balance = %s, want nothing", balance)
+	}
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+	"strings"
+	"time"
+)
+
+var ErrRuleSetNotFound = errors.New("price rule set not found")
+
+// GetPriceRuleSets retrieves every rule set with its rules
+func GetPriceRuleSets() ([]PriceRuleSet, error) {
+	rows, err := config.DB.Query("SELECT ruleSetID, name, theaterID, active FROM PRICE_RULE_SET ORDER BY ruleSetID")
+	if err != nil {
+		return nil, err
+	}
+	var sets []PriceRuleSet
+	for rows.Next() {
+		set, err := scanPriceRuleSet(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range sets {
+		if sets[i].Rules, err = getPriceRules(sets[i].RuleSetID); err != nil {
+			return nil, err
+		}
+	}
+	return sets, nil
+}
+
+// GetPriceRuleSet retrieves one rule set with its rules
+func GetPriceRuleSet(ruleSetID int) (*PriceRuleSet, error) {
+	row := config.DB.QueryRow("SELECT ruleSetID, name, theaterID, active FROM PRICE_RULE_SET WHERE ruleSetID = ?", ruleSetID)
+	set, err := scanPriceRuleSet(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrRuleSetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if set.Rules, err = getPriceRules(ruleSetID); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// CreatePriceRuleSet saves a new rule set together with its rules
+func CreatePriceRuleSet(set *PriceRuleSet) error {
+	if err := set.validate(); err != nil {
+		return err
+	}
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO PRICE_RULE_SET (name, theaterID, active) VALUES (?, ?, ?)", set.Name, set.TheaterID, set.Active)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	set.RuleSetID = int(id)
+
+	if err := insertPriceRules(tx, set); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdatePriceRuleSet replaces a rule set's settings and rules
+func UpdatePriceRuleSet(set *PriceRuleSet) error {
+	if err := set.validate(); err != nil {
+		return err
+	}
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE PRICE_RULE_SET SET name = ?, theaterID = ?, active = ? WHERE ruleSetID = ?", set.Name, set.TheaterID, set.Active, set.RuleSetID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM PRICE_RULE_SET WHERE ruleSetID = ?)", set.RuleSetID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRuleSetNotFound
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM PRICE_RULE WHERE ruleSetID = ?", set.RuleSetID); err != nil {
+		return err
+	}
+	if err := insertPriceRules(tx, set); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeletePriceRuleSet removes a rule set and its rules
+func DeletePriceRuleSet(ruleSetID int) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM PRICE_RULE WHERE ruleSetID = ?", ruleSetID); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM PRICE_RULE_SET WHERE ruleSetID = ?", ruleSetID)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return ErrRuleSetNotFound
+	}
+	return tx.Commit()
+}
+
+func (set *PriceRuleSet) validate() error {
+	set.Name = strings.TrimSpace(set.Name)
+	if set.Name == "" {
+		return errors.New("rule set name is required")
+	}
+	for i := range set.Rules {
+		if err := set.Rules[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertPriceRules(tx *sql.Tx, set *PriceRuleSet) error {
+	for i := range set.Rules {
+		rule := &set.Rules[i]
+		rule.RuleSetID = set.RuleSetID
+		result, err := tx.Exec(
+			"INSERT INTO PRICE_RULE (ruleSetID, name, ruleType, matchValue, adjustmentType, amount) VALUES (?, ?, ?, ?, ?, ?)",
+			rule.RuleSetID, rule.Name, rule.RuleType, rule.MatchValue, rule.AdjustmentType, rule.Amount,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		rule.RuleID = int(id)
+	}
+	return nil
+}
+
+func getPriceRules(ruleSetID int) ([]PriceRule, error) {
+	rows, err := config.DB.Query(
+		"SELECT ruleID, ruleSetID, name, ruleType, matchValue, adjustmentType, amount FROM PRICE_RULE WHERE ruleSetID = ? ORDER BY ruleID", ruleSetID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := scanPriceRules(rows)
+	if rules == nil && err == nil {
+		rules = []PriceRule{}
+	}
+	return rules, err
+}
+
+func scanPriceRuleSet(row rowScanner) (PriceRuleSet, error) {
+	var set PriceRuleSet
+	var theaterID sql.NullInt64
+	if err := row.Scan(&set.RuleSetID, &set.Name, &theaterID, &set.Active); err != nil {
+		return set, err
+	}
+	set.TheaterID = nullIntPtr(theaterID)
+	return set, nil
+}
+
+// GetHolidays retrieves every holiday, optionally limited to one calendar
+func GetHolidays(calendar string) ([]Holiday, error) {
+	query := "SELECT holidayID, calendar, holidayDate, name FROM HOLIDAY"
+	var args []interface{}
+	if calendar != "" {
+		query += " WHERE calendar = ?"
+		args = append(args, strings.ToUpper(calendar))
+	}
+	rows, err := config.DB.Query(query+" ORDER BY holidayDate", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holidays []Holiday
+	for rows.Next() {
+		var holiday Holiday
+		if err := rows.Scan(&holiday.HolidayID, &holiday.Calendar, &holiday.Date, &holiday.Name); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, holiday)
+	}
+	return holidays, rows.Err()
+}
+
+// CreateHoliday adds a date to a holiday calendar
+func CreateHoliday(holiday *Holiday) error {
+	holiday.Calendar = strings.ToUpper(strings.TrimSpace(holiday.Calendar))
+	if holiday.Calendar == "" {
+		return errors.New("calendar is required")
+	}
+	if _, err := time.Parse("2006-01-02", holiday.Date); err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", holiday.Date)
+	}
+
+	result, err := config.DB.Exec("INSERT INTO HOLIDAY (calendar, holidayDate, name) VALUES (?, ?, ?)", holiday.Calendar, holiday.Date, holiday.Name)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	holiday.HolidayID = int(id)
+	return nil
+}
+
+// DeleteHoliday removes a date from its holiday calendar
+func DeleteHoliday(holidayID int) error {
+	result, err := config.DB.Exec("DELETE FROM HOLIDAY WHERE holidayID = ?", holidayID)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return errors.New("holiday not found")
+	}
+	return nil
+}
@@ -0,0 +1,432 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"my-app/config"
+	"strings"
+	"time"
+)
+
+// Price rule types, each matching one aspect of a seat or showtime
+const (
+	PriceRuleSeatType  = "SEAT_TYPE"   // MatchValue is a seat type, e.g. VIP
+	PriceRuleTimeOfDay = "TIME_OF_DAY" // MatchValue is a range such as 18:00-23:00
+	PriceRuleWeekday   = "WEEKDAY"     // MatchValue is a list such as SAT,SUN
+	PriceRuleHoliday   = "HOLIDAY"     // MatchValue is a holiday calendar name
+	PriceRuleFormat    = "FORMAT"      // MatchValue is a schedule format, e.g. IMAX
+	PriceRuleCategory  = "CATEGORY"    // MatchValue is a ticket category, e.g. CHILD
+)
+
+// Adjustment types: a percentage of the base fare or a fixed amount in minor units, either may be negative
+const (
+	AdjustmentPercent = "PERCENT"
+	AdjustmentFixed   = "FIXED"
+)
+
+var ErrSeatNotInSchedule = errors.New("one or more seats do not belong to this schedule")
+
+// PriceRule adjusts the base fare of seats and showtimes it matches
+type PriceRule struct {
+	RuleID         int     `json:"rule_id"`
+	RuleSetID      int     `json:"rule_set_id"`
+	Name           string  `json:"name"`
+	RuleType       string  `json:"rule_type"`
+	MatchValue     string  `json:"match_value"`
+	AdjustmentType string  `json:"adjustment_type"`
+	Amount         float64 `json:"amount"` // Percentage for PERCENT, minor currency units for FIXED
+}
+
+// PriceRuleSet groups pricing rules. A set without a theater applies to every theater.
+type PriceRuleSet struct {
+	RuleSetID int         `json:"rule_set_id"`
+	Name      string      `json:"name"`
+	TheaterID *int        `json:"theater_id"`
+	Active    bool        `json:"active"`
+	Rules     []PriceRule `json:"rules"`
+}
+
+// Holiday is one date of a holiday calendar, matched by HOLIDAY rules
+type Holiday struct {
+	HolidayID int    `json:"holiday_id"`
+	Calendar  string `json:"calendar"`
+	Date      string `json:"date"` // YYYY-MM-DD
+	Name      string `json:"name"`
+}
+
+// PriceAdjustment is one rule applied to a seat's price
+type PriceAdjustment struct {
+	RuleID int    `json:"rule_id"`
+	Name   string `json:"name"`
+	Amount Money  `json:"amount"`
+}
+
+// SeatPrice is the price breakdown of one seat
+type SeatPrice struct {
+	SeatID      int               `json:"seat_id"`
+	SeatType    string            `json:"seat_type"`
+	Category    string            `json:"category"`
+	BaseFare    Money             `json:"base_fare"`
+	Adjustments []PriceAdjustment `json:"adjustments"`
+	Price       Money             `json:"price"`
+}
+
+// PriceBreakdown is what a booking costs, in minor currency units. VAT is charged on the
+// discounted ticket subtotal plus concessions and booking fees.
+type PriceBreakdown struct {
+	Subtotal           Money `json:"subtotal"`            // Tickets only
+	MembershipDiscount Money `json:"membership_discount"` // Included tickets and the plan's discount
+	Discount           Money `json:"discount"`            // Promo code
+	PointsDiscount     Money `json:"points_discount"`     // Loyalty points redeemed against the tickets
+	Concessions        Money `json:"concessions"`
+	BookingFee         Money `json:"booking_fee"` // For all tickets together
+	VATRate            int   `json:"vat_rate"`    // Basis points
+	Tax                Money `json:"tax"`
+	Total              Money `json:"total"`
+}
+
+// ticketsDue is what is left to pay for the tickets after every discount
+func (b *PriceBreakdown) ticketsDue() Money {
+	return b.Subtotal - b.MembershipDiscount - b.Discount - b.PointsDiscount
+}
+
+// settle works out the tax and total from the other amounts
+func (b *PriceBreakdown) settle() {
+	taxable := b.ticketsDue() + b.Concessions + b.BookingFee
+	b.Tax = basisPointsOf(taxable, b.VATRate)
+	b.Total = taxable + b.Tax
+}
+
+// PriceQuote is the price of a selection of seats for one schedule
+type PriceQuote struct {
+	ScheduleID      int               `json:"schedule_id"`
+	Seats           []SeatPrice       `json:"seats"`
+	ConcessionItems []ConcessionPrice `json:"concession_items,omitempty"`
+	PromoCode       string            `json:"promo_code,omitempty"`
+	Dynamic         bool              `json:"dynamic,omitempty"`          // The fare follows demand; a seat hold keeps this quote
+	MembershipID    int               `json:"membership_id,omitempty"`    // The member's plan applied to this quote
+	MembershipFilms int               `json:"membership_films,omitempty"` // Tickets taken from the month's allowance
+	PointsRedeemed  int               `json:"points_redeemed,omitempty"`
+	PriceBreakdown
+}
+
+// honour keeps the seat prices and charges of an earlier quote for the same seats and categories,
+// and its concession prices for the same items, so customers pay what they were shown while their
+// seat hold lasts
+func (quote *PriceQuote) honour(earlier *PriceQuote) {
+	if earlier == nil || len(earlier.Seats) != len(quote.Seats) {
+		return
+	}
+	for i, seat := range quote.Seats {
+		if earlier.Seats[i].SeatID != seat.SeatID || earlier.Seats[i].Category != seat.Category {
+			return
+		}
+	}
+	quote.Seats = earlier.Seats
+	quote.Subtotal = earlier.Subtotal
+	quote.BookingFee = earlier.BookingFee
+	quote.VATRate = earlier.VATRate
+
+	sameItems := len(earlier.ConcessionItems) == len(quote.ConcessionItems)
+	for i := 0; sameItems && i < len(quote.ConcessionItems); i++ {
+		sameItems = earlier.ConcessionItems[i].ItemID == quote.ConcessionItems[i].ItemID &&
+			earlier.ConcessionItems[i].Quantity == quote.ConcessionItems[i].Quantity
+	}
+	if sameItems {
+		quote.ConcessionItems = earlier.ConcessionItems
+		quote.Concessions = earlier.Concessions
+	}
+	quote.settle()
+}
+
+func (quote *PriceQuote) applyDiscount(code string, discount Money) {
+	quote.PromoCode = code
+	quote.Discount = discount
+	quote.settle()
+}
+
+// validate normalizes the rule and checks that its match value can be understood
+func (rule *PriceRule) validate() error {
+	rule.RuleType = strings.ToUpper(strings.TrimSpace(rule.RuleType))
+	rule.AdjustmentType = strings.ToUpper(strings.TrimSpace(rule.AdjustmentType))
+	rule.MatchValue = strings.ToUpper(strings.TrimSpace(rule.MatchValue))
+
+	if rule.AdjustmentType != AdjustmentPercent && rule.AdjustmentType != AdjustmentFixed {
+		return fmt.Errorf("invalid adjustment type %q", rule.AdjustmentType)
+	}
+	if rule.MatchValue == "" {
+		return fmt.Errorf("rule %q needs a match value", rule.Name)
+	}
+
+	switch rule.RuleType {
+	case PriceRuleSeatType:
+		if !ValidSeatType(rule.MatchValue) {
+			return fmt.Errorf("invalid seat type %q", rule.MatchValue)
+		}
+	case PriceRuleTimeOfDay:
+		if _, _, err := parseTimeRange(rule.MatchValue); err != nil {
+			return err
+		}
+	case PriceRuleWeekday:
+		for _, day := range strings.Split(rule.MatchValue, ",") {
+			if _, ok := weekdays[strings.TrimSpace(day)]; !ok {
+				return fmt.Errorf("invalid weekday %q", day)
+			}
+		}
+	case PriceRuleCategory:
+		if !ValidTicketCategory(rule.MatchValue) {
+			return fmt.Errorf("invalid ticket category %q", rule.MatchValue)
+		}
+	case PriceRuleHoliday, PriceRuleFormat:
+	default:
+		return fmt.Errorf("invalid rule type %q", rule.RuleType)
+	}
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"SUN": time.Sunday, "MON": time.Monday, "TUE": time.Tuesday, "WED": time.Wednesday,
+	"THU": time.Thursday, "FRI": time.Friday, "SAT": time.Saturday,
+}
+
+// parseTimeRange reads "HH:MM-HH:MM" into minutes after midnight
+func parseTimeRange(value string) (int, int, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", value)
+	}
+	var minutes [2]int
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", value)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	return minutes[0], minutes[1], nil
+}
+
+// pricingContext holds everything needed to price seats of one schedule
+type pricingContext struct {
+	scheduleID int
+	movieID    int
+	theaterID  int
+	baseFare   Money // The schedule fare, or its current dynamic fare
+	dynamic    bool
+	taxRate    TaxRate
+	showTime   time.Time
+	format     string
+	calendars  map[string]bool // Holiday calendars containing the show date
+	rules      []PriceRule
+}
+
+// matches reports whether a rule applies to a seat of the given type and ticket category in this showtime
+func (pc *pricingContext) matches(rule PriceRule, seatType, category string) bool {
+	switch rule.RuleType {
+	case PriceRuleSeatType:
+		return strings.EqualFold(rule.MatchValue, seatType)
+	case PriceRuleCategory:
+		return strings.EqualFold(rule.MatchValue, category)
+	case PriceRuleFormat:
+		return strings.EqualFold(rule.MatchValue, pc.format)
+	case PriceRuleHoliday:
+		return pc.calendars[strings.ToUpper(rule.MatchValue)]
+	case PriceRuleWeekday:
+		for _, day := range strings.Split(rule.MatchValue, ",") {
+			if weekday, ok := weekdays[strings.ToUpper(strings.TrimSpace(day))]; ok && weekday == pc.showTime.Weekday() {
+				return true
+			}
+		}
+	case PriceRuleTimeOfDay:
+		start, end, err := parseTimeRange(rule.MatchValue)
+		if err != nil {
+			return false
+		}
+		minute := pc.showTime.Hour()*60 + pc.showTime.Minute()
+		if start <= end {
+			return minute >= start && minute < end
+		}
+		return minute >= start || minute < end // Range across midnight
+	}
+	return false
+}
+
+// priceSeat applies every matching rule to the base fare. Percentages are taken from the
+// base fare rather than compounded, and the price never drops below zero.
+func (pc *pricingContext) priceSeat(seatID int, seatType, category string) SeatPrice {
+	price := SeatPrice{SeatID: seatID, SeatType: seatType, Category: category, BaseFare: pc.baseFare, Adjustments: []PriceAdjustment{}}
+	total := pc.baseFare
+	for _, rule := range pc.rules {
+		if !pc.matches(rule, seatType, category) {
+			continue
+		}
+		amount := Money(math.Round(rule.Amount))
+		if rule.AdjustmentType == AdjustmentPercent {
+			amount = percentOf(pc.baseFare, rule.Amount)
+		}
+		price.Adjustments = append(price.Adjustments, PriceAdjustment{RuleID: rule.RuleID, Name: rule.Name, Amount: amount})
+		total += amount
+	}
+	if total < 0 {
+		total = 0
+	}
+	price.Price = total
+	return price
+}
+
+// loadPricingContext reads the schedule, its theater's active rules and tax rate, and the holidays of its show date
+func loadPricingContext(q querier, scheduleID int) (*pricingContext, error) {
+	pc := &pricingContext{scheduleID: scheduleID, calendars: make(map[string]bool)}
+	var showTime, pricingMode string
+	var floor, ceiling Money
+	err := q.QueryRow(`
+        SELECT s.movieID, s.fare, s.showTime, COALESCE(s.format, '2D'), COALESCE(r.theaterID, 0),
+            COALESCE(s.pricingMode, 'FIXED'), COALESCE(s.fareFloor, 0), COALESCE(s.fareCeiling, 0)
+        FROM SCHEDULE s
+        LEFT JOIN SCREEN sc ON sc.screenID = s.screenID
+        LEFT JOIN ROOM r ON r.roomID = sc.roomID
+        WHERE s.scheduleID = ?
+    `, scheduleID).Scan(&pc.movieID, &pc.baseFare, &showTime, &pc.format, &pc.theaterID, &pricingMode, &floor, &ceiling)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("schedule not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	pc.showTime, err = ParseShowTime(showTime)
+	if err != nil {
+		return nil, err
+	}
+	if pricingMode == PricingModeDynamic {
+		sold, capacity, err := scheduleDemand(q, scheduleID)
+		if err != nil {
+			return nil, err
+		}
+		pc.baseFare = dynamicFare(floor, ceiling, sold, capacity, time.Until(pc.showTime))
+		pc.dynamic = true
+	}
+
+	rows, err := q.Query(`
+        SELECT pr.ruleID, pr.ruleSetID, pr.name, pr.ruleType, pr.matchValue, pr.adjustmentType, pr.amount
+        FROM PRICE_RULE pr
+        JOIN PRICE_RULE_SET rs ON rs.ruleSetID = pr.ruleSetID
+        WHERE rs.active = TRUE AND (rs.theaterID IS NULL OR rs.theaterID = ?)
+        ORDER BY pr.ruleSetID, pr.ruleID
+    `, pc.theaterID)
+	if err != nil {
+		return nil, err
+	}
+	pc.rules, err = scanPriceRules(rows)
+	if err != nil {
+		return nil, err
+	}
+	if pc.taxRate, err = theaterTaxRate(q, pc.theaterID); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query("SELECT calendar FROM HOLIDAY WHERE holidayDate = ?", pc.showTime.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var calendar string
+		if err := rows.Scan(&calendar); err != nil {
+			return nil, err
+		}
+		pc.calendars[strings.ToUpper(calendar)] = true
+	}
+	return pc, rows.Err()
+}
+
+// QuoteSeats prices the selected seats and concessions of a schedule without booking them,
+// previewing any membership, promo code and loyalty points for the user (0 when unknown)
+func QuoteSeats(userID, scheduleID int, seatIDs []int, options CheckoutOptions) (*PriceQuote, error) {
+	quote, pc, err := priceSeats(config.DB, scheduleID, seatIDs, options.Categories)
+	if err != nil {
+		return nil, err
+	}
+	if err := quote.addConcessions(config.DB, pc.theaterID, options.Concessions); err != nil {
+		return nil, err
+	}
+	if userID > 0 {
+		membership, err := currentMembership(config.DB, userID, false)
+		if err != nil {
+			return nil, err
+		}
+		quote.applyMembership(membership)
+	}
+	if options.PromoCode != "" {
+		if err := previewPromo(config.DB, quote, pc, options.PromoCode, userID); err != nil {
+			return nil, err
+		}
+	}
+	if options.redeemsPoints() {
+		if userID == 0 {
+			return nil, errors.New("sign in to redeem loyalty points")
+		}
+		balance, err := pointsBalance(config.DB, userID, false)
+		if err != nil {
+			return nil, err
+		}
+		if err := quote.applyPoints(options, balance); err != nil {
+			return nil, err
+		}
+	}
+	return quote, nil
+}
+
+// priceSeats prices each seat in its ticket category, adds the theater's fees and tax, and returns
+// the pricing context used, for discounts
+func priceSeats(q querier, scheduleID int, seatIDs []int, requested map[int]string) (*PriceQuote, *pricingContext, error) {
+	if len(seatIDs) == 0 {
+		return nil, nil, errors.New("no seats selected")
+	}
+	categories, err := seatCategories(seatIDs, requested)
+	if err != nil {
+		return nil, nil, err
+	}
+	pc, err := loadPricingContext(q, scheduleID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quote := &PriceQuote{ScheduleID: scheduleID, Dynamic: pc.dynamic}
+	quote.VATRate = pc.taxRate.VATRate
+	quote.BookingFee = pc.taxRate.BookingFee * Money(len(seatIDs))
+	for _, seatID := range seatIDs {
+		var seatType string
+		err := q.QueryRow(`
+            SELECT COALESCE(st.seatType, 'STANDARD')
+            FROM SEAT st
+            JOIN SCHEDULE s ON s.screenID = st.screenID
+            WHERE st.seatID = ? AND s.scheduleID = ?
+        `, seatID, scheduleID).Scan(&seatType)
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrSeatNotInSchedule
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		price := pc.priceSeat(seatID, seatType, categories[seatID])
+		quote.Seats = append(quote.Seats, price)
+		quote.Subtotal += price.Price
+	}
+	quote.settle()
+	return quote, pc, nil
+}
+
+func scanPriceRules(rows *sql.Rows) ([]PriceRule, error) {
+	defer rows.Close()
+	var rules []PriceRule
+	for rows.Next() {
+		var rule PriceRule
+		if err := rows.Scan(&rule.RuleID, &rule.RuleSetID, &rule.Name, &rule.RuleType, &rule.MatchValue, &rule.AdjustmentType, &rule.Amount); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
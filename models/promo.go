@@ -0,0 +1,360 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"my-app/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPromoNotFound      = errors.New("promo code not found")
+	ErrPromoNotApplicable = errors.New("promo code cannot be applied")
+	ErrPromoExhausted     = errors.New("promo code usage limit reached")
+)
+
+// PromoCode is a discount customers apply at checkout. Empty restriction lists allow everything.
+type PromoCode struct {
+	PromoID        int        `json:"promo_id"`
+	Code           string     `json:"code"`
+	DiscountType   string     `json:"discount_type"` // PERCENT of the subtotal or FIXED amount
+	Amount         float64    `json:"amount"`        // Percentage for PERCENT, minor currency units for FIXED
+	MinSpend       Money      `json:"min_spend"`
+	ValidFrom      *time.Time `json:"valid_from"`
+	ValidUntil     *time.Time `json:"valid_until"`
+	MaxUses        int        `json:"max_uses"`          // 0 means unlimited
+	MaxUsesPerUser int        `json:"max_uses_per_user"` // 0 means unlimited
+	UsedCount      int        `json:"used_count"`
+	MovieIDs       []int      `json:"movie_ids"`
+	TheaterIDs     []int      `json:"theater_ids"`
+	Weekdays       []string   `json:"weekdays"` // e.g. ["MON", "TUE"], matched against the show date
+	Active         bool       `json:"active"`
+}
+
+const promoColumns = "promoID, code, discountType, amount, minSpend, validFrom, validUntil, maxUses, maxUsesPerUser, usedCount, COALESCE(movieIDs, ''), COALESCE(theaterIDs, ''), COALESCE(weekdays, ''), active"
+
+func scanPromo(row rowScanner) (*PromoCode, error) {
+	var promo PromoCode
+	var validFrom, validUntil sql.NullString
+	var movieIDs, theaterIDs, weekdayList string
+	err := row.Scan(&promo.PromoID, &promo.Code, &promo.DiscountType, &promo.Amount, &promo.MinSpend, &validFrom, &validUntil,
+		&promo.MaxUses, &promo.MaxUsesPerUser, &promo.UsedCount, &movieIDs, &theaterIDs, &weekdayList, &promo.Active)
+	if err != nil {
+		return nil, err
+	}
+	if promo.ValidFrom, err = parseNullTime(validFrom); err != nil {
+		return nil, err
+	}
+	if promo.ValidUntil, err = parseNullTime(validUntil); err != nil {
+		return nil, err
+	}
+	if promo.MovieIDs, err = splitIDs(movieIDs); err != nil {
+		return nil, err
+	}
+	if promo.TheaterIDs, err = splitIDs(theaterIDs); err != nil {
+		return nil, err
+	}
+	if weekdayList != "" {
+		promo.Weekdays = strings.Split(weekdayList, ",")
+	}
+	return &promo, nil
+}
+
+// validate normalizes the promo code and checks its settings
+func (promo *PromoCode) validate() error {
+	promo.Code = strings.ToUpper(strings.TrimSpace(promo.Code))
+	promo.DiscountType = strings.ToUpper(strings.TrimSpace(promo.DiscountType))
+	if promo.Code == "" {
+		return errors.New("code is required")
+	}
+	switch promo.DiscountType {
+	case AdjustmentPercent:
+		if promo.Amount <= 0 || promo.Amount > 100 {
+			return errors.New("percentage discounts must be between 0 and 100")
+		}
+	case AdjustmentFixed:
+		if promo.Amount <= 0 {
+			return errors.New("fixed discounts must be positive")
+		}
+	default:
+		return fmt.Errorf("invalid discount type %q", promo.DiscountType)
+	}
+	if promo.MinSpend < 0 || promo.MaxUses < 0 || promo.MaxUsesPerUser < 0 {
+		return errors.New("limits cannot be negative")
+	}
+	if promo.ValidFrom != nil && promo.ValidUntil != nil && promo.ValidUntil.Before(*promo.ValidFrom) {
+		return errors.New("valid_until is before valid_from")
+	}
+	for i, day := range promo.Weekdays {
+		promo.Weekdays[i] = strings.ToUpper(strings.TrimSpace(day))
+		if _, ok := weekdays[promo.Weekdays[i]]; !ok {
+			return fmt.Errorf("invalid weekday %q", day)
+		}
+	}
+	return nil
+}
+
+// discountFor checks the promo's dates, restrictions and minimum spend against a showtime and
+// returns the discount on the given subtotal. Usage caps are checked by lockPromo.
+func (promo *PromoCode) discountFor(pc *pricingContext, subtotal Money, now time.Time) (Money, error) {
+	if !promo.Active {
+		return 0, fmt.Errorf("%w: code is no longer active", ErrPromoNotApplicable)
+	}
+	if promo.ValidFrom != nil && now.Before(*promo.ValidFrom) {
+		return 0, fmt.Errorf("%w: code is not valid yet", ErrPromoNotApplicable)
+	}
+	if promo.ValidUntil != nil && now.After(*promo.ValidUntil) {
+		return 0, fmt.Errorf("%w: code has expired", ErrPromoNotApplicable)
+	}
+	if len(promo.MovieIDs) > 0 && !containsInt(promo.MovieIDs, pc.movieID) {
+		return 0, fmt.Errorf("%w: not valid for this movie", ErrPromoNotApplicable)
+	}
+	if len(promo.TheaterIDs) > 0 && !containsInt(promo.TheaterIDs, pc.theaterID) {
+		return 0, fmt.Errorf("%w: not valid at this theater", ErrPromoNotApplicable)
+	}
+	if len(promo.Weekdays) > 0 {
+		valid := false
+		for _, day := range promo.Weekdays {
+			if weekdays[day] == pc.showTime.Weekday() {
+				valid = true
+			}
+		}
+		if !valid {
+			return 0, fmt.Errorf("%w: not valid on this day", ErrPromoNotApplicable)
+		}
+	}
+	if subtotal < promo.MinSpend {
+		return 0, fmt.Errorf("%w: minimum spend is %s", ErrPromoNotApplicable, promo.MinSpend)
+	}
+
+	discount := Money(math.Round(promo.Amount))
+	if promo.DiscountType == AdjustmentPercent {
+		discount = percentOf(subtotal, promo.Amount)
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+	return discount, nil
+}
+
+// lockPromo locks a promo code row for the rest of the transaction and checks its usage caps,
+// so concurrent bookings cannot redeem a code beyond its limits.
+func lockPromo(tx *sql.Tx, code string, userID int) (*PromoCode, error) {
+	promo, err := scanPromo(tx.QueryRow("SELECT "+promoColumns+" FROM PROMO_CODE WHERE code = ? FOR UPDATE", strings.ToUpper(strings.TrimSpace(code))))
+	if err == sql.ErrNoRows {
+		return nil, ErrPromoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPromoUsage(tx, promo, userID); err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+// checkPromoUsage checks a code's caps; guests share the per-user cap with the other guest
+// checkouts made with their email or phone
+func checkPromoUsage(q querier, promo *PromoCode, userID int) error {
+	if promo.MaxUses > 0 && promo.UsedCount >= promo.MaxUses {
+		return ErrPromoExhausted
+	}
+	if promo.MaxUsesPerUser > 0 && userID > 0 {
+		customerIDs, err := customerUserIDs(q, userID)
+		if err != nil {
+			return err
+		}
+		inIDs, idArgs := inList(customerIDs)
+		var used int
+		err = q.QueryRow("SELECT COUNT(*) FROM PROMO_REDEMPTION WHERE promoID = ? AND userID IN ("+inIDs+") AND released = FALSE", append([]interface{}{promo.PromoID}, idArgs...)...).Scan(&used)
+		if err != nil {
+			return err
+		}
+		if used >= promo.MaxUsesPerUser {
+			return fmt.Errorf("%w: already used %d time(s)", ErrPromoExhausted, used)
+		}
+	}
+	return nil
+}
+
+// redeemPromo records a code's use by a booking inside the transaction that locked it
+func redeemPromo(tx *sql.Tx, promo *PromoCode, userID int, bookingID int64, discount Money) error {
+	if _, err := tx.Exec("UPDATE PROMO_CODE SET usedCount = usedCount + 1 WHERE promoID = ?", promo.PromoID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(
+		"INSERT INTO PROMO_REDEMPTION (promoID, userID, bookingID, discount, redeemedAt, released) VALUES (?, ?, ?, ?, ?, FALSE)",
+		promo.PromoID, userID, bookingID, discount, time.Now(),
+	)
+	return err
+}
+
+// releasePromoRedemption gives a code's use back when its booking was never paid
+func releasePromoRedemption(tx *sql.Tx, bookingID int64) error {
+	var redemptionID, promoID int
+	err := tx.QueryRow("SELECT redemptionID, promoID FROM PROMO_REDEMPTION WHERE bookingID = ? AND released = FALSE FOR UPDATE", bookingID).Scan(&redemptionID, &promoID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE PROMO_REDEMPTION SET released = TRUE WHERE redemptionID = ?", redemptionID); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE PROMO_CODE SET usedCount = usedCount - 1 WHERE promoID = ? AND usedCount > 0", promoID)
+	return err
+}
+
+// previewPromo applies a code to a quote without locking it, for quotes and seat holds
+func previewPromo(q querier, quote *PriceQuote, pc *pricingContext, code string, userID int) error {
+	promo, err := scanPromo(q.QueryRow("SELECT "+promoColumns+" FROM PROMO_CODE WHERE code = ?", strings.ToUpper(strings.TrimSpace(code))))
+	if err == sql.ErrNoRows {
+		return ErrPromoNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkPromoUsage(q, promo, userID); err != nil {
+		return err
+	}
+	discount, err := promo.discountFor(pc, quote.ticketsDue(), time.Now())
+	if err != nil {
+		return err
+	}
+	quote.applyDiscount(promo.Code, discount)
+	return nil
+}
+
+// GetPromoCodes retrieves every promo code
+func GetPromoCodes() ([]PromoCode, error) {
+	rows, err := config.DB.Query("SELECT " + promoColumns + " FROM PROMO_CODE ORDER BY promoID")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promos []PromoCode
+	for rows.Next() {
+		promo, err := scanPromo(rows)
+		if err != nil {
+			return nil, err
+		}
+		promos = append(promos, *promo)
+	}
+	return promos, rows.Err()
+}
+
+// GetPromoCode retrieves a promo code by ID
+func GetPromoCode(promoID int) (*PromoCode, error) {
+	promo, err := scanPromo(config.DB.QueryRow("SELECT "+promoColumns+" FROM PROMO_CODE WHERE promoID = ?", promoID))
+	if err == sql.ErrNoRows {
+		return nil, ErrPromoNotFound
+	}
+	return promo, err
+}
+
+// CreatePromoCode saves a new promo code
+func CreatePromoCode(promo *PromoCode) error {
+	if err := promo.validate(); err != nil {
+		return err
+	}
+	result, err := config.DB.Exec(`
+        INSERT INTO PROMO_CODE (code, discountType, amount, minSpend, validFrom, validUntil, maxUses, maxUsesPerUser, usedCount, movieIDs, theaterIDs, weekdays, active)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?)
+    `, promo.Code, promo.DiscountType, promo.Amount, promo.MinSpend, promo.ValidFrom, promo.ValidUntil, promo.MaxUses, promo.MaxUsesPerUser,
+		joinIDs(promo.MovieIDs), joinIDs(promo.TheaterIDs), strings.Join(promo.Weekdays, ","), promo.Active)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	promo.PromoID = int(id)
+	promo.UsedCount = 0
+	return nil
+}
+
+// UpdatePromoCode changes a promo code's settings. Its usage count is kept.
+func UpdatePromoCode(promo *PromoCode) error {
+	if err := promo.validate(); err != nil {
+		return err
+	}
+	result, err := config.DB.Exec(`
+        UPDATE PROMO_CODE SET code = ?, discountType = ?, amount = ?, minSpend = ?, validFrom = ?, validUntil = ?,
+            maxUses = ?, maxUsesPerUser = ?, movieIDs = ?, theaterIDs = ?, weekdays = ?, active = ?
+        WHERE promoID = ?
+    `, promo.Code, promo.DiscountType, promo.Amount, promo.MinSpend, promo.ValidFrom, promo.ValidUntil, promo.MaxUses, promo.MaxUsesPerUser,
+		joinIDs(promo.MovieIDs), joinIDs(promo.TheaterIDs), strings.Join(promo.Weekdays, ","), promo.Active, promo.PromoID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		if _, err := GetPromoCode(promo.PromoID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeactivatePromoCode stops a code from being used. Redemptions are kept for reporting.
+func DeactivatePromoCode(promoID int) error {
+	result, err := config.DB.Exec("UPDATE PROMO_CODE SET active = FALSE WHERE promoID = ?", promoID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		if _, err := GetPromoCode(promoID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseNullTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value.String, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func splitIDs(list string) ([]int, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var ids []int
+	for _, part := range strings.Split(list, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID list %q", list)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
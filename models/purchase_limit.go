@@ -0,0 +1,227 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+)
+
+var ErrPurchaseLimit = errors.New("purchase limit exceeded")
+
+// PurchaseLimits caps how much one user may buy. A limit of 0 means unlimited.
+type PurchaseLimits struct {
+	MaxSeatsPerBooking  int `json:"max_seats_per_booking"`
+	MaxSeatsPerSchedule int `json:"max_seats_per_schedule"`
+	MaxActiveBookings   int `json:"max_active_bookings"`
+}
+
+// PurchaseLimitOverride replaces some of the default limits for one user, e.g. for group sales.
+// Nil fields keep the default.
+type PurchaseLimitOverride struct {
+	UserID              int    `json:"user_id"`
+	MaxSeatsPerBooking  *int   `json:"max_seats_per_booking"`
+	MaxSeatsPerSchedule *int   `json:"max_seats_per_schedule"`
+	MaxActiveBookings   *int   `json:"max_active_bookings"`
+	Note                string `json:"note"`
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	queryRower
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// DefaultPurchaseLimits returns the limits configured for every user
+func DefaultPurchaseLimits() PurchaseLimits {
+	return PurchaseLimits{
+		MaxSeatsPerBooking:  config.MaxSeatsPerBooking,
+		MaxSeatsPerSchedule: config.MaxSeatsPerSchedule,
+		MaxActiveBookings:   config.MaxActiveBookings,
+	}
+}
+
+// GetPurchaseLimits returns the limits in force for a user, applying any admin override
+func GetPurchaseLimits(userID int) (PurchaseLimits, error) {
+	return purchaseLimits(config.DB, userID)
+}
+
+func purchaseLimits(q queryRower, userID int) (PurchaseLimits, error) {
+	limits := DefaultPurchaseLimits()
+	override, err := purchaseLimitOverride(q, userID)
+	if err != nil || override == nil {
+		return limits, err
+	}
+	if override.MaxSeatsPerBooking != nil {
+		limits.MaxSeatsPerBooking = *override.MaxSeatsPerBooking
+	}
+	if override.MaxSeatsPerSchedule != nil {
+		limits.MaxSeatsPerSchedule = *override.MaxSeatsPerSchedule
+	}
+	if override.MaxActiveBookings != nil {
+		limits.MaxActiveBookings = *override.MaxActiveBookings
+	}
+	return limits, nil
+}
+
+// GetPurchaseLimitOverride returns a user's override, or nil when the defaults apply
+func GetPurchaseLimitOverride(userID int) (*PurchaseLimitOverride, error) {
+	return purchaseLimitOverride(config.DB, userID)
+}
+
+func purchaseLimitOverride(q queryRower, userID int) (*PurchaseLimitOverride, error) {
+	override := PurchaseLimitOverride{UserID: userID}
+	var perBooking, perSchedule, active sql.NullInt64
+	err := q.QueryRow(
+		"SELECT maxSeatsPerBooking, maxSeatsPerSchedule, maxActiveBookings, COALESCE(note, '') FROM PURCHASE_LIMIT_OVERRIDE WHERE userID = ?", userID,
+	).Scan(&perBooking, &perSchedule, &active, &override.Note)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	override.MaxSeatsPerBooking = nullIntPtr(perBooking)
+	override.MaxSeatsPerSchedule = nullIntPtr(perSchedule)
+	override.MaxActiveBookings = nullIntPtr(active)
+	return &override, nil
+}
+
+// SavePurchaseLimitOverride creates or replaces a user's override
+func SavePurchaseLimitOverride(override PurchaseLimitOverride) error {
+	for _, limit := range []*int{override.MaxSeatsPerBooking, override.MaxSeatsPerSchedule, override.MaxActiveBookings} {
+		if limit != nil && *limit < 0 {
+			return errors.New("limits cannot be negative")
+		}
+	}
+	if _, err := GetUserByID(override.UserID); err != nil {
+		return err
+	}
+
+	_, err := config.DB.Exec(`
+        INSERT INTO PURCHASE_LIMIT_OVERRIDE (userID, maxSeatsPerBooking, maxSeatsPerSchedule, maxActiveBookings, note)
+        VALUES (?, ?, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE maxSeatsPerBooking = VALUES(maxSeatsPerBooking), maxSeatsPerSchedule = VALUES(maxSeatsPerSchedule),
+            maxActiveBookings = VALUES(maxActiveBookings), note = VALUES(note)
+    `, override.UserID, override.MaxSeatsPerBooking, override.MaxSeatsPerSchedule, override.MaxActiveBookings, override.Note)
+	return err
+}
+
+// DeletePurchaseLimitOverride puts a user back on the default limits
+func DeletePurchaseLimitOverride(userID int) error {
+	_, err := config.DB.Exec("DELETE FROM PURCHASE_LIMIT_OVERRIDE WHERE userID = ?", userID)
+	return err
+}
+
+// checkPurchaseLimits verifies that a user may take seatCount more seats of a schedule. Guests are
+// counted together with the other guest checkouts made with their email or phone.
+// holds are the customer's active seat holds, which count as bookings in the making.
+func checkPurchaseLimits(q querier, userID, scheduleID, seatCount int, holds []SeatHold) error {
+	limits, err := purchaseLimits(q, userID)
+	if err != nil {
+		return err
+	}
+	customerIDs, err := customerUserIDs(q, userID)
+	if err != nil {
+		return err
+	}
+	inIDs, idArgs := inList(customerIDs)
+
+	if limits.MaxSeatsPerBooking > 0 && seatCount > limits.MaxSeatsPerBooking {
+		return fmt.Errorf("%w: at most %d seats per booking", ErrPurchaseLimit, limits.MaxSeatsPerBooking)
+	}
+
+	if limits.MaxSeatsPerSchedule > 0 {
+		var taken int
+		err := q.QueryRow(
+			"SELECT COALESCE(SUM(seatsBooked), 0) FROM BOOKING WHERE userID IN ("+inIDs+") AND scheduleID = ? AND status IN (?, ?, ?)",
+			append(idArgs, scheduleID, BookingStatusPending, BookingStatusConfirmed, BookingStatusCheckedIn)...,
+		).Scan(&taken)
+		if err != nil {
+			return err
+		}
+		for _, hold := range holds {
+			if hold.ScheduleID == scheduleID {
+				taken += len(hold.SeatIDs)
+			}
+		}
+		if taken+seatCount > limits.MaxSeatsPerSchedule {
+			return fmt.Errorf("%w: at most %d seats per showtime, %d already taken", ErrPurchaseLimit, limits.MaxSeatsPerSchedule, taken)
+		}
+	}
+
+	if limits.MaxActiveBookings > 0 {
+		var active int
+		err := q.QueryRow(`
+            SELECT COUNT(*) FROM BOOKING b
+            JOIN SCHEDULE s ON s.scheduleID = b.scheduleID
+            WHERE b.userID IN (`+inIDs+`) AND b.status IN (?, ?) AND s.showTime > NOW()
+        `, append(idArgs, BookingStatusPending, BookingStatusConfirmed)...).Scan(&active)
+		if err != nil {
+			return err
+		}
+		if active+len(holds) >= limits.MaxActiveBookings {
+			return fmt.Errorf("%w: at most %d active bookings", ErrPurchaseLimit, limits.MaxActiveBookings)
+		}
+	}
+	return nil
+}
+
+// customerActiveHolds returns the unexpired holds of a user and, for a guest, of the other guest
+// checkouts made with the same email or phone
+func customerActiveHolds(userID int) ([]SeatHold, error) {
+	customerIDs, err := customerUserIDs(config.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var holds []SeatHold
+	for _, id := range customerIDs {
+		userHolds, err := userActiveHolds(id)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, userHolds...)
+	}
+	return holds, nil
+}
+
+// userActiveHolds returns the user's holds that have not expired yet
+func userActiveHolds(userID int) ([]SeatHold, error) {
+	if config.RedisClient == nil {
+		return nil, nil
+	}
+	ctx := context.Background()
+	holdIDs, err := config.RedisClient.SMembers(ctx, userHoldsKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error listing holds: %v", err)
+	}
+
+	var holds []SeatHold
+	for _, holdID := range holdIDs {
+		hold, err := GetHold(holdID)
+		if errors.Is(err, ErrHoldNotFound) {
+			config.RedisClient.SRem(ctx, userHoldsKey(userID), holdID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, *hold)
+	}
+	return holds, nil
+}
+
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
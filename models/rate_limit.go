@@ -0,0 +1,27 @@
+package models
+
+import (
+	"context"
+	"log"
+	"my-app/config"
+	"time"
+)
+
+// AllowRequest counts one request against key in a fixed window and reports whether the count is
+// still within limit. Requests are let through while Redis is unavailable.
+func AllowRequest(key string, limit int, window time.Duration) bool {
+	if config.RedisClient == nil {
+		return true
+	}
+	ctx := context.Background()
+	redisKey := "ratelimit:" + key
+	count, err := config.RedisClient.Incr(ctx, redisKey).Result()
+	if err != nil {
+		log.Printf("Error counting requests for %s: %v", key, err)
+		return true
+	}
+	if count == 1 {
+		config.RedisClient.Expire(ctx, redisKey, window)
+	}
+	return count <= int64(limit)
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"my-app/config"
+	"strconv"
+	"time"
+)
+
+// ReceiptLine is one ticket on a receipt
+type ReceiptLine struct {
+	SeatID    int    `json:"seat_id"`
+	SeatLabel string `json:"seat_label"`
+	Category  string `json:"category"`
+	Price     Money  `json:"price"`
+}
+
+// Receipt summarizes what a booking bought and what it cost
+type Receipt struct {
+	BookingID   int64             `json:"booking_id"`
+	Status      string            `json:"status"`
+	MovieTitle  string            `json:"movie_title"`
+	ShowTime    string            `json:"show_time"`
+	BookingDate time.Time         `json:"booking_date"`
+	Lines       []ReceiptLine     `json:"lines"`
+	Concessions []ConcessionPrice `json:"concession_items,omitempty"`
+	PickupCode  string            `json:"pickup_code,omitempty"` // Shown at the counter to collect the concessions
+	PromoCode   string            `json:"promo_code,omitempty"`
+	PriceBreakdown
+}
+
+// GetReceipt builds the receipt of a booking
+func GetReceipt(bookingID int64) (*Receipt, error) {
+	booking, err := GetBookingDetails(bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &Receipt{
+		BookingID:      booking.BookingID,
+		Status:         booking.Status,
+		BookingDate:    booking.BookingDate,
+		Lines:          []ReceiptLine{},
+		PriceBreakdown: booking.PriceBreakdown,
+	}
+
+	err = config.DB.QueryRow(`
+        SELECT COALESCE(m.title, ''), COALESCE(s.showTime, '')
+        FROM BOOKING b
+        LEFT JOIN MOVIE m ON m.movieID = b.movieID
+        LEFT JOIN SCHEDULE s ON s.scheduleID = b.scheduleID
+        WHERE b.bookingID = ?
+    `, bookingID).Scan(&receipt.MovieTitle, &receipt.ShowTime)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.DB.QueryRow(`
+        SELECT pc.code FROM PROMO_REDEMPTION pr
+        JOIN PROMO_CODE pc ON pc.promoID = pr.promoID
+        WHERE pr.bookingID = ? AND pr.released = FALSE
+    `, bookingID).Scan(&receipt.PromoCode)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	order, err := GetConcessionOrder(bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if order != nil {
+		receipt.Concessions = order.Lines
+		receipt.PickupCode = order.PickupCode
+	}
+
+	seats, err := GetBookingSeats(bookingID)
+	if err != nil {
+		return nil, err
+	}
+	for _, seat := range seats {
+		line := ReceiptLine{SeatID: seat.SeatID, Category: seat.Category, Price: seat.Price}
+		var rowLabel string
+		var columnNumber, seatNumber int
+		err := config.DB.QueryRow(
+			"SELECT COALESCE(rowLabel, ''), COALESCE(columnNumber, 0), seatNumber FROM SEAT WHERE seatID = ?", seat.SeatID,
+		).Scan(&rowLabel, &columnNumber, &seatNumber)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if rowLabel != "" && columnNumber > 0 {
+			line.SeatLabel = fmt.Sprintf("%s%d", rowLabel, columnNumber)
+		} else {
+			line.SeatLabel = strconv.Itoa(seatNumber)
+		}
+		receipt.Lines = append(receipt.Lines, line)
+	}
+
+	// Bookings made before prices were stored on the booking
+	if receipt.Subtotal == 0 {
+		for _, line := range receipt.Lines {
+			receipt.Subtotal += line.Price
+		}
+		receipt.settle()
+	}
+	return receipt, nil
+}
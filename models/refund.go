@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"my-app/config"
+	"time"
+)
+
+var (
+	// ErrCancellationClosed is returned when a customer tries to cancel after the cutoff
+	ErrCancellationClosed = errors.New("booking can no longer be cancelled this close to showtime")
+	// ErrBookingUnscheduled is returned for bookings made before showtimes were recorded, whose
+	// cutoff cannot be checked; staff cancel them through the booking status
+	ErrBookingUnscheduled = errors.New("booking has no showtime on record, ask the theater to cancel it")
+)
+
+// Refund records money given back for a cancelled booking
+type Refund struct {
+	RefundID    int64     `json:"refund_id"`
+	BookingID   int64     `json:"booking_id"`
+	Amount      Money     `json:"amount"`
+	StoredValue Money     `json:"stored_value"` // Part of the amount credited to the customer's wallet
+	Percent     int       `json:"percent"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// RefundPercent returns the share of the paid amount refunded when cancelling with the given notice
+func RefundPercent(notice time.Duration) int {
+	for _, tier := range config.RefundTiers {
+		if notice >= tier.Before {
+			return tier.Percent
+		}
+	}
+	return 0
+}
+
+// CancelBookingWithRefund cancels a customer's booking before the cutoff, frees its seats
+// and records a refund following config.RefundTiers. Unpaid bookings are cancelled without a refund.
+// What was paid with gift cards or the wallet is refunded to the customer's wallet.
+func CancelBookingWithRefund(bookingID int64, actor Actor) (*Refund, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var showTime sql.NullString
+	var ownerID int
+	err = tx.QueryRow(`
+        SELECT COALESCE(b.status, ?), s.showTime, b.userID
+        FROM BOOKING b
+        LEFT JOIN SCHEDULE s ON s.scheduleID = b.scheduleID
+        WHERE b.bookingID = ?
+        FOR UPDATE
+    `, BookingStatusPending, bookingID).Scan(&status, &showTime, &ownerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrBookingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if (actor.Role == "user" || actor.Role == RoleGuest) && actor.UserID != ownerID {
+		return nil, ErrBookingNotOwned
+	}
+	if !showTime.Valid {
+		return nil, ErrBookingUnscheduled
+	}
+
+	start, err := ParseShowTime(showTime.String)
+	if err != nil {
+		return nil, err
+	}
+	notice := time.Until(start)
+	if notice < config.CancellationCutoff {
+		return nil, ErrCancellationClosed
+	}
+
+	release, err := transitionBooking(tx, bookingID, BookingStatusCancelled, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	var refund *Refund
+	if status == BookingStatusConfirmed {
+		var paid, storedValue Money
+		err := tx.QueryRow(
+			"SELECT COALESCE(SUM(amount), 0), COALESCE(SUM(CASE WHEN method IN (?, ?) THEN amount ELSE 0 END), 0) FROM PAYMENT WHERE bookingID = ? AND paymentStatus = ?",
+			PaymentMethodGiftCard, PaymentMethodWallet, bookingID, PaymentPaid,
+		).Scan(&paid, &storedValue)
+		if err != nil {
+			return nil, err
+		}
+
+		percent := RefundPercent(notice)
+		refund = &Refund{
+			BookingID:   bookingID,
+			Amount:      percentOf(paid, float64(percent)),
+			StoredValue: percentOf(storedValue, float64(percent)),
+			Percent:     percent,
+			CreatedAt:   time.Now(),
+		}
+		if err := createRefund(tx, refund); err != nil {
+			return nil, err
+		}
+		if refund.StoredValue > 0 {
+			if _, err := creditWallet(tx, ownerID, refund.StoredValue, LedgerRefund, &bookingID, actor, ""); err != nil {
+				return nil, err
+			}
+		}
+		if refund.Amount > 0 {
+			if _, err := transitionBooking(tx, bookingID, BookingStatusRefunded, actor); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	release.publish()
+	return refund, nil
+}
+
+func createRefund(tx *sql.Tx, refund *Refund) error {
+	result, err := tx.Exec(
+		"INSERT INTO REFUND (bookingID, amount, storedValue, percent, createdAt) VALUES (?, ?, ?, ?, ?)",
+		refund.BookingID, refund.Amount, refund.StoredValue, refund.Percent, refund.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	refund.RefundID, err = result.LastInsertId()
+	return err
+}
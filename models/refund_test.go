@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"my-app/config"
+	"testing"
+	"time"
+)
+
+func TestCancelBookingWithoutShowtimeIsRefused(t *testing.T) {
+	requireDB(t)
+	userID := createTestUser(t)
+	bookingID := insertTestRow(t, "INSERT INTO BOOKING (userID, movieID, screenID, bookingDate, seatsBooked, status) VALUES (?, 0, 0, ?, 1, ?)",
+		userID, time.Now(), BookingStatusConfirmed)
+
+	_, err := CancelBookingWithRefund(int64(bookingID), Actor{UserID: createTestUser(t), Role: "user"})
+	if !errors.Is(err, ErrBookingNotOwned) {
+		t.Errorf("another user cancelling: got %v, want %v", err, ErrBookingNotOwned)
+	}
+	_, err = CancelBookingWithRefund(int64(bookingID), Actor{UserID: userID, Role: "user"})
+	if !errors.Is(err, ErrBookingUnscheduled) {
+		t.Fatalf("got %v, want %v", err, ErrBookingUnscheduled)
+	}
+	if status := bookingStatus(t, int64(bookingID)); status != BookingStatusConfirmed {
+		t.Errorf("booking is %s, want it left %s", status, BookingStatusConfirmed)
+	}
+}
+
+func TestCancelBookingRefundsWalletPaymentAndFreesSeats(t *testing.T) {
+	requireDB(t)
+	userID := createTestUser(t)
+	scheduleID, seatID := insertTestSchedule(t)
+	if _, err := config.DB.Exec("UPDATE SCHEDULE SET showTime = ? WHERE scheduleID = ?", time.Now().AddDate(0, 0, 3), scheduleID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.DB.Exec("UPDATE SCHEDULE_SEAT SET status = ? WHERE scheduleID = ? AND seatID = ?", SeatStatusBooked, scheduleID, seatID); err != nil {
+		t.Fatal(err)
+	}
+	bookingID := int64(insertTestRow(t,
+		"INSERT INTO BOOKING (userID, movieID, screenID, scheduleID, bookingDate, seatsBooked, status, expiresAt, subtotal, total) VALUES (?, 0, 0, ?, ?, 1, ?, ?, 1000, 1000)",
+		userID, scheduleID, time.Now(), BookingStatusPending, time.Now().Add(time.Hour)))
+	if _, err := config.DB.Exec("INSERT INTO BOOKING_SEAT (bookingID, seatID) VALUES (?, ?)", bookingID, seatID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreditWallet(userID, 1000, Actor{Role: "admin"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProcessPayment(userID, Payment{BookingID: int(bookingID), Splits: []PaymentSplit{{Method: PaymentMethodWallet, Amount: 1000}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	refund, err := CancelBookingWithRefund(bookingID, Actor{UserID: userID, Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund == nil || refund.Percent != 100 || refund.Amount != 1000 || refund.StoredValue != 1000 {
+		t.Fatalf("refund = %+v, want all 1000 back to the wallet", refund)
+	}
+	if balance := walletBalance(t, userID); balance != 1000 {
+		t.Errorf("wallet balance = %s, want %s", balance, Money(1000))
+	}
+	if status := bookingStatus(t, bookingID); status != BookingStatusRefunded {
+		t.Errorf("booking status = %s, want %s", status, BookingStatusRefunded)
+	}
+	var seatStatus string
+	if err := config.DB.QueryRow("SELECT status FROM SCHEDULE_SEAT WHERE scheduleID = ? AND seatID = ?", scheduleID, seatID).Scan(&seatStatus); err != nil {
+		t.Fatal(err)
+	}
+	if seatStatus != SeatStatusAvailable {
+		t.Errorf("seat status = %s, want %s", seatStatus, SeatStatusAvailable)
+	}
+}
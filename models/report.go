@@ -0,0 +1,68 @@
+package models
+
+import (
+	"my-app/config"
+	"time"
+)
+
+// CategoryRevenue is the ticket revenue of one ticket category, before booking discounts
+type CategoryRevenue struct {
+	Category string `json:"category"`
+	Tickets  int    `json:"tickets"`
+	Revenue  Money  `json:"revenue"`
+}
+
+// RevenueReport summarizes paid bookings made in a period
+type RevenueReport struct {
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	Categories  []CategoryRevenue `json:"categories"`
+	Gross       Money             `json:"gross"`
+	Discounts   Money             `json:"discounts"` // Memberships, promo codes and loyalty points
+	Concessions Money             `json:"concessions"`
+	Fees        Money             `json:"fees"`
+	Tax         Money             `json:"tax"`
+	Net         Money             `json:"net"` // Tickets less discounts plus concessions, excluding fees and tax
+}
+
+// GetRevenueReport totals the paid bookings made between from and to, by ticket category
+func GetRevenueReport(from, to time.Time) (*RevenueReport, error) {
+	report := &RevenueReport{From: from, To: to, Categories: []CategoryRevenue{}}
+
+	rows, err := config.DB.Query(`
+        SELECT COALESCE(bs.category, ?), COUNT(*), COALESCE(SUM(COALESCE(bs.price, s.fare, 0)), 0)
+        FROM BOOKING_SEAT bs
+        JOIN BOOKING b ON b.bookingID = bs.bookingID
+        LEFT JOIN SCHEDULE s ON s.scheduleID = b.scheduleID
+        WHERE b.status IN (?, ?) AND b.bookingDate >= ? AND b.bookingDate < ?
+        GROUP BY COALESCE(bs.category, ?)
+        ORDER BY 1
+    `, TicketCategoryAdult, BookingStatusConfirmed, BookingStatusCheckedIn, from, to, TicketCategoryAdult)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line CategoryRevenue
+		if err := rows.Scan(&line.Category, &line.Tickets, &line.Revenue); err != nil {
+			return nil, err
+		}
+		report.Categories = append(report.Categories, line)
+		report.Gross += line.Revenue
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = config.DB.QueryRow(
+		"SELECT COALESCE(SUM(discount + COALESCE(pointsDiscount, 0) + COALESCE(membershipDiscount, 0)), 0), COALESCE(SUM(concessions), 0), COALESCE(SUM(bookingFee), 0), COALESCE(SUM(tax), 0) FROM BOOKING WHERE status IN (?, ?) AND bookingDate >= ? AND bookingDate < ?",
+		BookingStatusConfirmed, BookingStatusCheckedIn, from, to,
+	).Scan(&report.Discounts, &report.Concessions, &report.Fees, &report.Tax)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Net = report.Gross - report.Discounts + report.Concessions
+	return report, nil
+}
@@ -1,39 +1,101 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 	"my-app/config"
+	"strings"
+	"time"
 )
 
 // Schedule - Model for movie schedule
 type Schedule struct {
-	ScheduleID     int     `json:"scheduleID"`     // Unique identifier for the schedule
-	MovieID        int     `json:"movieID"`        // Unique identifier for the movie
-	ScreenID       int     `json:"screenID"`       // Unique identifier for the screen
-	ShowTime       string  `json:"showTime"`       // Show time of the movie
-	AvailableSeats int     `json:"availableSeats"` // Number of available seats
-	Fare           float64 `json:"fare"`           // Price per seat for the schedule
+	ScheduleID     int    `json:"scheduleID"`     // Unique identifier for the schedule
+	MovieID        int    `json:"movieID"`        // Unique identifier for the movie
+	ScreenID       int    `json:"screenID"`       // Unique identifier for the screen
+	ShowTime       string `json:"showTime"`       // Show time of the movie
+	AvailableSeats int    `json:"availableSeats"` // Number of available seats, derived from SCHEDULE_SEAT
+	Fare           Money  `json:"fare"`           // Base price per seat in minor currency units, adjusted by the pricing rules
+	Format         string `json:"format"`         // Projection format such as 2D, 3D or IMAX
+	PricingMode    string `json:"pricingMode"`    // FIXED, or DYNAMIC to move the fare with demand
+	FareFloor      Money  `json:"fareFloor"`      // Lowest dynamic fare
+	FareCeiling    Money  `json:"fareCeiling"`    // Highest dynamic fare
 }
 
-// CreateSchedule - Add a new schedule
+// DefaultScheduleFormat is used for schedules created without a format
+const DefaultScheduleFormat = "2D"
+
+// scheduleColumns selects a schedule's fields in the order expected by scanSchedule
+const scheduleColumns = "scheduleID, movieID, screenID, showTime, availableSeats, fare, COALESCE(format, '2D'), COALESCE(pricingMode, 'FIXED'), COALESCE(fareFloor, 0), COALESCE(fareCeiling, 0)"
+
+func scanSchedule(row rowScanner) (Schedule, error) {
+	var schedule Schedule
+	err := row.Scan(&schedule.ScheduleID, &schedule.MovieID, &schedule.ScreenID, &schedule.ShowTime, &schedule.AvailableSeats, &schedule.Fare,
+		&schedule.Format, &schedule.PricingMode, &schedule.FareFloor, &schedule.FareCeiling)
+	return schedule, err
+}
+
+// normalize fills defaults for the schedule's settings and validates its pricing
+func (schedule *Schedule) normalize() error {
+	schedule.Format = strings.ToUpper(strings.TrimSpace(schedule.Format))
+	if schedule.Format == "" {
+		schedule.Format = DefaultScheduleFormat
+	}
+
+	schedule.PricingMode = strings.ToUpper(strings.TrimSpace(schedule.PricingMode))
+	switch schedule.PricingMode {
+	case "", PricingModeFixed:
+		schedule.PricingMode = PricingModeFixed
+	case PricingModeDynamic:
+		if schedule.FareFloor <= 0 || schedule.FareCeiling < schedule.FareFloor {
+			return errors.New("dynamic pricing needs a positive fareFloor no higher than fareCeiling")
+		}
+	default:
+		return fmt.Errorf("invalid pricing mode %q", schedule.PricingMode)
+	}
+	if schedule.Fare < 0 {
+		return errors.New("fare cannot be negative")
+	}
+	return nil
+}
+
+// CreateSchedule - Add a new schedule and open its seat inventory
 func CreateSchedule(schedule *Schedule) error {
-	query := "INSERT INTO SCHEDULE (movieID, screenID, showTime, availableSeats, fare) VALUES (?, ?, ?, ?, ?)"
-	result, err := config.DB.Exec(query, schedule.MovieID, schedule.ScreenID, schedule.ShowTime, schedule.AvailableSeats, schedule.Fare)
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := schedule.normalize(); err != nil {
+		return err
+	}
+	query := "INSERT INTO SCHEDULE (movieID, screenID, showTime, availableSeats, fare, format, pricingMode, fareFloor, fareCeiling) VALUES (?, ?, ?, 0, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, schedule.MovieID, schedule.ScreenID, schedule.ShowTime, schedule.Fare, schedule.Format,
+		schedule.PricingMode, schedule.FareFloor, schedule.FareCeiling)
 	if err != nil {
 		return err
 	}
 
 	lastInsertID, err := result.LastInsertId()
-	if err == nil {
-		schedule.ScheduleID = int(lastInsertID)
+	if err != nil {
+		return err
+	}
+	schedule.ScheduleID = int(lastInsertID)
+
+	if err := syncScheduleSeats(tx, schedule.ScheduleID); err != nil {
+		return err
+	}
+	if err := tx.QueryRow("SELECT availableSeats FROM SCHEDULE WHERE scheduleID = ?", schedule.ScheduleID).Scan(&schedule.AvailableSeats); err != nil {
+		return err
 	}
-	return err
+
+	return tx.Commit()
 }
 
 // GetSchedules - Retrieve the list of schedules
 func GetSchedules() ([]Schedule, error) {
-	query := "SELECT scheduleID, movieID, screenID, showTime, availableSeats, fare FROM SCHEDULE"
-	rows, err := config.DB.Query(query)
+	rows, err := config.DB.Query("SELECT " + scheduleColumns + " FROM SCHEDULE")
 	if err != nil {
 		return nil, err
 	}
@@ -41,8 +103,8 @@ func GetSchedules() ([]Schedule, error) {
 
 	var schedules []Schedule
 	for rows.Next() {
-		var schedule Schedule
-		if err := rows.Scan(&schedule.ScheduleID, &schedule.MovieID, &schedule.ScreenID, &schedule.ShowTime, &schedule.AvailableSeats, &schedule.Fare); err != nil {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
 			return nil, err
 		}
 		schedules = append(schedules, schedule)
@@ -53,39 +115,94 @@ func GetSchedules() ([]Schedule, error) {
 
 // GetScheduleByID - Retrieve a schedule by ID
 func GetScheduleByID(id int) (Schedule, error) {
-	query := "SELECT scheduleID, movieID, screenID, showTime, availableSeats, fare FROM SCHEDULE WHERE scheduleID = ?"
-	var schedule Schedule
-	err := config.DB.QueryRow(query, id).Scan(&schedule.ScheduleID, &schedule.MovieID, &schedule.ScreenID, &schedule.ShowTime, &schedule.AvailableSeats, &schedule.Fare)
-	return schedule, err
+	return scanSchedule(config.DB.QueryRow("SELECT "+scheduleColumns+" FROM SCHEDULE WHERE scheduleID = ?", id))
 }
 
-// UpdateSchedule - Update a schedule
+// UpdateSchedule - Update a schedule. availableSeats is derived from the seat inventory and is not editable.
 func UpdateSchedule(schedule *Schedule) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldScreenID int
+	if err := tx.QueryRow("SELECT screenID FROM SCHEDULE WHERE scheduleID = ? FOR UPDATE", schedule.ScheduleID).Scan(&oldScreenID); err != nil {
+		return err
+	}
+
+	// Moving a showtime to another screen rebuilds its inventory, which is only safe before any sale
+	if oldScreenID != schedule.ScreenID {
+		var booked int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM SCHEDULE_SEAT WHERE scheduleID = ? AND status = ?", schedule.ScheduleID, SeatStatusBooked).Scan(&booked); err != nil {
+			return err
+		}
+		if booked > 0 {
+			return errors.New("cannot change the screen of a schedule with booked seats")
+		}
+		if _, err := tx.Exec("DELETE FROM SCHEDULE_SEAT WHERE scheduleID = ?", schedule.ScheduleID); err != nil {
+			return err
+		}
+	}
+
 	query := `
         UPDATE SCHEDULE
-        SET movieID = ?, screenID = ?, showTime = ?, availableSeats = ?, fare = ?
+        SET movieID = ?, screenID = ?, showTime = ?, fare = ?, format = ?, pricingMode = ?, fareFloor = ?, fareCeiling = ?
         WHERE scheduleID = ?
     `
+	if err := schedule.normalize(); err != nil {
+		return err
+	}
 
-	// Log the values to be used in the query
-	fmt.Printf("Updating schedule with values: MovieID=%d, ScreenID=%d, ShowTime=%s, AvailableSeats=%d, Fare=%.2f, ScheduleID=%d\n",
-		schedule.MovieID, schedule.ScreenID, schedule.ShowTime, schedule.AvailableSeats, schedule.Fare, schedule.ScheduleID)
+	if _, err := tx.Exec(query, schedule.MovieID, schedule.ScreenID, schedule.ShowTime, schedule.Fare, schedule.Format,
+		schedule.PricingMode, schedule.FareFloor, schedule.FareCeiling, schedule.ScheduleID); err != nil {
+		return err
+	}
+	if err := syncScheduleSeats(tx, schedule.ScheduleID); err != nil {
+		return err
+	}
 
-	_, err := config.DB.Exec(query, schedule.MovieID, schedule.ScreenID, schedule.ShowTime, schedule.AvailableSeats, schedule.Fare, schedule.ScheduleID)
-	return err
+	return tx.Commit()
 }
 
-// DeleteSchedule - Delete a schedule
+// ErrScheduleHasBookings is returned when deleting a schedule that still has booked seats
+var ErrScheduleHasBookings = errors.New("schedule has booked seats, cancel its bookings first")
+
+// DeleteSchedule - Delete a schedule and its seat inventory. Schedules with booked seats are kept,
+// since deleting them would orphan the bookings.
 func DeleteSchedule(id int) error {
-	query := "DELETE FROM SCHEDULE WHERE scheduleID = ?"
-	_, err := config.DB.Exec(query, id)
-	return err
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var scheduleID int
+	if err := tx.QueryRow("SELECT scheduleID FROM SCHEDULE WHERE scheduleID = ? FOR UPDATE", id).Scan(&scheduleID); err != nil {
+		return err
+	}
+	// Locking the inventory keeps a booking in progress from taking a seat after the check
+	var booked int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM SCHEDULE_SEAT WHERE scheduleID = ? AND status = ? FOR UPDATE", id, SeatStatusBooked).Scan(&booked); err != nil {
+		return err
+	}
+	if booked > 0 {
+		return ErrScheduleHasBookings
+	}
+
+	if _, err := tx.Exec("DELETE FROM SCHEDULE_SEAT WHERE scheduleID = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM SCHEDULE WHERE scheduleID = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // GetSchedulesByScreenID - Retrieve schedules by screenID
 func GetSchedulesByScreenID(screenID int) ([]Schedule, error) {
-	query := "SELECT scheduleID, movieID, screenID, showTime, availableSeats, fare FROM SCHEDULE WHERE screenID = ?"
-	rows, err := config.DB.Query(query, screenID)
+	rows, err := config.DB.Query("SELECT "+scheduleColumns+" FROM SCHEDULE WHERE screenID = ?", screenID)
 	if err != nil {
 		return nil, err
 	}
@@ -93,8 +210,8 @@ func GetSchedulesByScreenID(screenID int) ([]Schedule, error) {
 
 	var schedules []Schedule
 	for rows.Next() {
-		var schedule Schedule
-		if err := rows.Scan(&schedule.ScheduleID, &schedule.MovieID, &schedule.ScreenID, &schedule.ShowTime, &schedule.AvailableSeats, &schedule.Fare); err != nil {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
 			return nil, err
 		}
 		schedules = append(schedules, schedule)
@@ -102,3 +219,13 @@ func GetSchedulesByScreenID(screenID int) ([]Schedule, error) {
 
 	return schedules, nil
 }
+
+// ParseShowTime converts a SCHEDULE.showTime value into a time in the server's location
+func ParseShowTime(showTime string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, showTime, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid show time %q", showTime)
+}
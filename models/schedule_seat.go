@@ -0,0 +1,185 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"my-app/config"
+)
+
+// Seat statuses tracked per schedule in SCHEDULE_SEAT
+const (
+	SeatStatusAvailable = "AVAILABLE"
+	SeatStatusBooked    = "BOOKED"
+	SeatStatusBlocked   = "BLOCKED"
+)
+
+// ScheduleSeat is a physical seat together with its state for one showtime
+type ScheduleSeat struct {
+	Seat
+	ScheduleID int    `json:"schedule_id"`
+	Status     string `json:"status"`
+	IsBooked   bool   `json:"is_booked"`
+}
+
+// syncScheduleSeats creates missing SCHEDULE_SEAT rows for every seat on the
+// schedule's screen and refreshes SCHEDULE.availableSeats
+func syncScheduleSeats(tx *sql.Tx, scheduleID int) error {
+	_, err := tx.Exec(`
+        INSERT IGNORE INTO SCHEDULE_SEAT (scheduleID, seatID, status)
+        SELECT s.scheduleID, st.seatID, ?
+        FROM SCHEDULE s
+        JOIN SEAT st ON st.screenID = s.screenID
+        WHERE s.scheduleID = ?
+    `, SeatStatusAvailable, scheduleID)
+	if err != nil {
+		return err
+	}
+	return refreshAvailableSeats(tx, scheduleID)
+}
+
+// syncScreenSchedules adds inventory rows for new seats to every schedule on a screen
+func syncScreenSchedules(tx *sql.Tx, screenID int) error {
+	rows, err := tx.Query("SELECT scheduleID FROM SCHEDULE WHERE screenID = ?", screenID)
+	if err != nil {
+		return err
+	}
+	var scheduleIDs []int
+	for rows.Next() {
+		var scheduleID int
+		if err := rows.Scan(&scheduleID); err != nil {
+			rows.Close()
+			return err
+		}
+		scheduleIDs = append(scheduleIDs, scheduleID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, scheduleID := range scheduleIDs {
+		if err := syncScheduleSeats(tx, scheduleID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshAvailableSeats derives SCHEDULE.availableSeats from the seat inventory
+func refreshAvailableSeats(tx *sql.Tx, scheduleID int) error {
+	_, err := tx.Exec(`
+        UPDATE SCHEDULE
+        SET availableSeats = (SELECT COUNT(*) FROM SCHEDULE_SEAT WHERE scheduleID = ? AND status = ?)
+        WHERE scheduleID = ?
+    `, scheduleID, SeatStatusAvailable, scheduleID)
+	return err
+}
+
+// GetSeatsByScheduleID retrieves the seats of a schedule's screen with their availability for that showtime
+func GetSeatsByScheduleID(scheduleID int) ([]ScheduleSeat, error) {
+	rows, err := config.DB.Query(`
+        SELECT `+seatColumns+`, s.scheduleID, COALESCE(ss.status, ?)
+        FROM SCHEDULE s
+        JOIN SEAT st ON st.screenID = s.screenID
+        LEFT JOIN SCHEDULE_SEAT ss ON ss.scheduleID = s.scheduleID AND ss.seatID = st.seatID
+        WHERE s.scheduleID = ?
+        ORDER BY st.posY, st.posX, st.seatNumber
+    `, SeatStatusAvailable, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seats []ScheduleSeat
+	for rows.Next() {
+		var seat ScheduleSeat
+		if err := scanSeat(rows, &seat.Seat, &seat.ScheduleID, &seat.Status); err != nil {
+			return nil, err
+		}
+		seats = append(seats, seat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Seats locked by an unexpired hold are not available either
+	var free []int
+	for _, seat := range seats {
+		if seat.Status == SeatStatusAvailable {
+			free = append(free, seat.SeatID)
+		}
+	}
+	held, err := heldSeatIDs(scheduleID, free)
+	if err != nil {
+		log.Printf("Could not read seat holds for schedule %d: %v", scheduleID, err)
+	}
+	for i := range seats {
+		if held[seats[i].SeatID] {
+			seats[i].Status = SeatStatusHeld
+		}
+		seats[i].IsBooked = seats[i].Status != SeatStatusAvailable
+	}
+
+	return seats, nil
+}
+
+// SetScheduleSeatStatus lets an admin block or free a seat for a single showtime.
+// Seats of a live booking can only be freed by cancelling the booking.
+func SetScheduleSeatStatus(scheduleID, seatID int, status string) error {
+	if status != SeatStatusAvailable && status != SeatStatusBlocked {
+		return fmt.Errorf("invalid seat status %q", status)
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := syncScheduleSeats(tx, scheduleID); err != nil {
+		return err
+	}
+
+	var current string
+	err = tx.QueryRow("SELECT status FROM SCHEDULE_SEAT WHERE scheduleID = ? AND seatID = ? FOR UPDATE", scheduleID, seatID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("seat %d does not belong to schedule %d", seatID, scheduleID)
+	}
+	if err != nil {
+		return err
+	}
+	if current == SeatStatusBooked {
+		// Seats sold under the old screen-wide flag have no booking behind them, so staff can free them
+		var booked bool
+		err := tx.QueryRow(`
+            SELECT EXISTS(
+                SELECT 1 FROM BOOKING_SEAT bs
+                JOIN BOOKING b ON b.bookingID = bs.bookingID
+                WHERE b.scheduleID = ? AND bs.seatID = ? AND b.status IN (?, ?, ?)
+            )
+        `, scheduleID, seatID, BookingStatusPending, BookingStatusConfirmed, BookingStatusCheckedIn).Scan(&booked)
+		if err != nil {
+			return err
+		}
+		if booked {
+			return errors.New("seat is booked for this schedule")
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE SCHEDULE_SEAT SET status = ? WHERE scheduleID = ? AND seatID = ?", status, scheduleID, seatID); err != nil {
+		return err
+	}
+	if err := refreshAvailableSeats(tx, scheduleID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	publishSeatChanges(scheduleID, []int{seatID}, status)
+	return nil
+}
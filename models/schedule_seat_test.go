@@ -0,0 +1,41 @@
+package models
+
+import (
+	"my-app/config"
+	"testing"
+	"time"
+)
+
+func TestSetScheduleSeatStatusFreesBookedSeatWithoutLiveBooking(t *testing.T) {
+	requireDB(t)
+	scheduleID, seatID := insertTestSchedule(t)
+	markBooked := func() {
+		t.Helper()
+		if _, err := config.DB.Exec("UPDATE SCHEDULE_SEAT SET status = ? WHERE scheduleID = ? AND seatID = ?", SeatStatusBooked, scheduleID, seatID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Sold under the old screen-wide flag, with no booking behind it
+	markBooked()
+	if err := SetScheduleSeatStatus(scheduleID, seatID, SeatStatusAvailable); err != nil {
+		t.Fatalf("freeing a booked seat without a booking: %v", err)
+	}
+
+	markBooked()
+	bookingID := insertTestRow(t, "INSERT INTO BOOKING (userID, movieID, screenID, scheduleID, bookingDate, seatsBooked, status) VALUES (?, 0, 0, ?, ?, 1, ?)",
+		createTestUser(t), scheduleID, time.Now(), BookingStatusConfirmed)
+	if _, err := config.DB.Exec("INSERT INTO BOOKING_SEAT (bookingID, seatID) VALUES (?, ?)", bookingID, seatID); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetScheduleSeatStatus(scheduleID, seatID, SeatStatusAvailable); err == nil {
+		t.Fatal("freed the seat of a confirmed booking")
+	}
+
+	if _, err := config.DB.Exec("UPDATE BOOKING SET status = ? WHERE bookingID = ?", BookingStatusCancelled, bookingID); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetScheduleSeatStatus(scheduleID, seatID, SeatStatusAvailable); err != nil {
+		t.Fatalf("freeing the seat of a cancelled booking: %v", err)
+	}
+}
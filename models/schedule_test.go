@@ -0,0 +1,48 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"my-app/config"
+	"testing"
+	"time"
+)
+
+// insertTestSchedule adds a showing tomorrow on a new screen with one available seat, and returns
+// the schedule and seat IDs
+func insertTestSchedule(t *testing.T) (int, int) {
+	t.Helper()
+	screenID := insertTestRow(t, "INSERT INTO SCREEN (roomID, screenNumber) VALUES (0, 1)")
+	seatID := insertTestRow(t, "INSERT INTO SEAT (screenID, seatNumber) VALUES (?, 1)", screenID)
+	scheduleID := insertTestRow(t, "INSERT INTO SCHEDULE (movieID, screenID, showTime, availableSeats) VALUES (0, ?, ?, 1)",
+		screenID, time.Now().AddDate(0, 0, 1))
+	if _, err := config.DB.Exec("INSERT INTO SCHEDULE_SEAT (scheduleID, seatID, status) VALUES (?, ?, ?)", scheduleID, seatID, SeatStatusAvailable); err != nil {
+		t.Fatal(err)
+	}
+	return scheduleID, seatID
+}
+
+func TestDeleteScheduleRefusesBookedSchedule(t *testing.T) {
+	requireDB(t)
+	scheduleID, seatID := insertTestSchedule(t)
+	if _, err := config.DB.Exec("UPDATE SCHEDULE_SEAT SET status = ? WHERE scheduleID = ? AND seatID = ?", SeatStatusBooked, scheduleID, seatID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteSchedule(scheduleID); !errors.Is(err, ErrScheduleHasBookings) {
+		t.Fatalf("deleting a schedule with a booked seat: got %v, want %v", err, ErrScheduleHasBookings)
+	}
+	if _, err := GetScheduleByID(scheduleID); err != nil {
+		t.Fatalf("schedule with a booked seat was deleted: %v", err)
+	}
+
+	if _, err := config.DB.Exec("UPDATE SCHEDULE_SEAT SET status = ? WHERE scheduleID = ? AND seatID = ?", SeatStatusAvailable, scheduleID, seatID); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteSchedule(scheduleID); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteSchedule(scheduleID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleting a missing schedule: got %v, want %v", err, sql.ErrNoRows)
+	}
+}
@@ -4,17 +4,65 @@ import (
 	"my-app/config"
 )
 
+// Seat types
+const (
+	SeatTypeStandard   = "STANDARD"
+	SeatTypeVIP        = "VIP"
+	SeatTypeCouple     = "COUPLE"
+	SeatTypeWheelchair = "WHEELCHAIR"
+	SeatTypeCompanion  = "COMPANION"
+)
+
 type Seat struct {
-	SeatID     int  `json:"seat_id"`
-	ScreenID   int  `json:"screen_id"`
-	SeatNumber int  `json:"seat_number"`
-	IsBooked   bool `json:"is_booked"`
+	SeatID       int    `json:"seat_id"`
+	ScreenID     int    `json:"screen_id"`
+	SeatNumber   int    `json:"seat_number"`
+	RowLabel     string `json:"row_label"`     // Row as printed on the ticket, e.g. "A"
+	ColumnNumber int    `json:"column_number"` // Seat number within its row
+	PosX         int    `json:"pos_x"`         // Grid column, counting aisles
+	PosY         int    `json:"pos_y"`         // Grid row, 0 is closest to the screen
+	SeatType     string `json:"seat_type"`
+}
+
+// seatColumns selects a seat's fields in the order expected by scanSeat
+const seatColumns = "st.seatID, st.screenID, st.seatNumber, COALESCE(st.rowLabel, ''), COALESCE(st.columnNumber, 0), COALESCE(st.posX, 0), COALESCE(st.posY, 0), COALESCE(st.seatType, 'STANDARD')"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSeat reads a row selected with seatColumns, followed by any extra destinations
+func scanSeat(row rowScanner, seat *Seat, extra ...interface{}) error {
+	dest := []interface{}{&seat.SeatID, &seat.ScreenID, &seat.SeatNumber, &seat.RowLabel, &seat.ColumnNumber, &seat.PosX, &seat.PosY, &seat.SeatType}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// ValidSeatType reports whether t is a known seat type
+func ValidSeatType(t string) bool {
+	switch t {
+	case SeatTypeStandard, SeatTypeVIP, SeatTypeCouple, SeatTypeWheelchair, SeatTypeCompanion:
+		return true
+	}
+	return false
 }
 
 // CreateSeat adds a new seat to the database
 func CreateSeat(seat *Seat) error {
-	query := "INSERT INTO SEAT (screenID, seatNumber, isBooked) VALUES (?, ?, ?)"
-	result, err := config.DB.Exec(query, seat.ScreenID, seat.SeatNumber, seat.IsBooked)
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if seat.SeatType == "" {
+		seat.SeatType = SeatTypeStandard
+	}
+	if !ValidSeatType(seat.SeatType) {
+		return fmt.Errorf("invalid seat type %q", seat.SeatType)
+	}
+
+	query := "INSERT INTO SEAT (screenID, seatNumber, rowLabel, columnNumber, posX, posY, seatType) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, seat.ScreenID, seat.SeatNumber, seat.RowLabel, seat.ColumnNumber, seat.PosX, seat.PosY, seat.SeatType)
 	if err != nil {
 		return err
 	}
@@ -24,13 +72,22 @@ func CreateSeat(seat *Seat) error {
 		return err
 	}
 
+	// Make the new seat sellable for schedules already running on this screen
+	if err := syncScreenSchedules(tx, seat.ScreenID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	seat.SeatID = int(seatID)
 	return nil
 }
 
 // GetAllSeats retrieves all seats from the database
 func GetAllSeats() ([]Seat, error) {
-	rows, err := config.DB.Query("SELECT seatID, screenID, seatNumber, isBooked FROM SEAT")
+	rows, err := config.DB.Query("SELECT " + seatColumns + " FROM SEAT st")
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +96,7 @@ func GetAllSeats() ([]Seat, error) {
 	var seats []Seat
 	for rows.Next() {
 		var seat Seat
-		err := rows.Scan(&seat.SeatID, &seat.ScreenID, &seat.SeatNumber, &seat.IsBooked)
+		err := scanSeat(rows, &seat)
 		if err != nil {
 			return nil, err
 		}
@@ -49,37 +106,89 @@ func GetAllSeats() ([]Seat, error) {
 	return seats, nil
 }
 
-// UpdateSeat updates an existing seat
 // UpdateSeat updates an existing seat in the database
 func UpdateSeat(seat *Seat) error {
-	query := "UPDATE SEAT SET screenID = ?, seatNumber = ?, isBooked = ? WHERE seatID = ?"
-	result, err := config.DB.Exec(query, seat.ScreenID, seat.SeatNumber, seat.IsBooked, seat.SeatID)
+	tx, err := config.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	var oldScreenID int
+	if err := tx.QueryRow("SELECT screenID FROM SEAT WHERE seatID = ?", seat.SeatID).Scan(&oldScreenID); err != nil {
+		return fmt.Errorf("no rows were updated; seatID %d might not exist", seat.SeatID)
+	}
+
+	if seat.SeatType == "" {
+		seat.SeatType = SeatTypeStandard
+	}
+	if !ValidSeatType(seat.SeatType) {
+		return fmt.Errorf("invalid seat type %q", seat.SeatType)
+	}
+
+	query := "UPDATE SEAT SET screenID = ?, seatNumber = ?, rowLabel = ?, columnNumber = ?, posX = ?, posY = ?, seatType = ? WHERE seatID = ?"
+	if _, err := tx.Exec(query, seat.ScreenID, seat.SeatNumber, seat.RowLabel, seat.ColumnNumber, seat.PosX, seat.PosY, seat.SeatType, seat.SeatID); err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("no rows were updated; seatID %d might not exist", seat.SeatID)
+	// A seat moved to another screen leaves the old screen's unsold inventory
+	if oldScreenID != seat.ScreenID {
+		_, err := tx.Exec(`
+            DELETE FROM SCHEDULE_SEAT
+            WHERE seatID = ? AND status <> ?
+              AND scheduleID IN (SELECT scheduleID FROM SCHEDULE WHERE screenID = ?)
+        `, seat.SeatID, SeatStatusBooked, oldScreenID)
+		if err != nil {
+			return err
+		}
+		if err := syncScreenSchedules(tx, oldScreenID); err != nil {
+			return err
+		}
+		if err := syncScreenSchedules(tx, seat.ScreenID); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 // DeleteSeat removes a seat from the database by its ID
 func DeleteSeat(seatID int) error {
-	query := "DELETE FROM SEAT WHERE seatID = ?"
-	_, err := config.DB.Exec(query, seatID)
-	return err
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var screenID int
+	if err := tx.QueryRow("SELECT screenID FROM SEAT WHERE seatID = ?", seatID).Scan(&screenID); err != nil {
+		return fmt.Errorf("seatID %d might not exist", seatID)
+	}
+
+	var booked int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM SCHEDULE_SEAT WHERE seatID = ? AND status = ?", seatID, SeatStatusBooked).Scan(&booked); err != nil {
+		return err
+	}
+	if booked > 0 {
+		return fmt.Errorf("seatID %d is booked for %d schedule(s)", seatID, booked)
+	}
+
+	if _, err := tx.Exec("DELETE FROM SCHEDULE_SEAT WHERE seatID = ?", seatID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM SEAT WHERE seatID = ?", seatID); err != nil {
+		return err
+	}
+	if err := syncScreenSchedules(tx, screenID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Example function to get seats by screen ID
 func GetSeatsByScreenID(screenID int) ([]Seat, error) {
-	rows, err := config.DB.Query("SELECT seatID, screenID, seatNumber, isBooked FROM SEAT WHERE screenID = ?", screenID)
+	rows, err := config.DB.Query("SELECT "+seatColumns+" FROM SEAT st WHERE st.screenID = ? ORDER BY posY, posX, seatNumber", screenID)
 	if err != nil {
 		return nil, err
 	}
@@ -88,7 +197,7 @@ func GetSeatsByScreenID(screenID int) ([]Seat, error) {
 	var seats []Seat
 	for rows.Next() {
 		var seat Seat
-		err := rows.Scan(&seat.SeatID, &seat.ScreenID, &seat.SeatNumber, &seat.IsBooked)
+		err := scanSeat(rows, &seat)
 		if err != nil {
 			return nil, err
 		}
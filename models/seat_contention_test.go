@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"my-app/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createTestSchedule adds a screen with one seat and a showing on it tomorrow, and returns the
+// schedule and seat IDs
+func createTestSchedule(t *testing.T) (int, int) {
+	t.Helper()
+	theaterID := insertTestRow(t, "INSERT INTO THEATER (name, location) VALUES ('Test theater', '')")
+	roomID := insertTestRow(t, "INSERT INTO ROOM (theaterID, roomNumber) VALUES (?, 1)", theaterID)
+	screenID := insertTestRow(t, "INSERT INTO SCREEN (roomID, screenNumber) VALUES (?, 1)", roomID)
+	movieID := insertTestRow(t, "INSERT INTO MOVIE (title) VALUES ('Test movie')")
+
+	seat := &Seat{ScreenID: screenID, SeatNumber: 1, RowLabel: "A", ColumnNumber: 1}
+	if err := CreateSeat(seat); err != nil {
+		t.Fatal(err)
+	}
+	schedule := &Schedule{MovieID: movieID, ScreenID: screenID, ShowTime: time.Now().AddDate(0, 0, 1).Format("2006-01-02 15:04:05"), Fare: 1000}
+	if err := CreateSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	return schedule.ScheduleID, seat.SeatID
+}
+
+// Customers race for one seat, half through a seat hold paid by card and half booking it
+// directly; exactly one of them gets it.
+func TestSeatContentionOneCustomerWins(t *testing.T) {
+	requireRedis(t)
+	useFakePaymentProvider(t)
+	scheduleID, seatID := createTestSchedule(t)
+
+	const customers = 20
+	users := make([]int, customers)
+	for i := range users {
+		users[i] = createTestUser(t)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		winners  []int64
+		failures []error
+	)
+	start := make(chan struct{})
+	for i, userID := range users {
+		wg.Add(1)
+		go func(userID int, viaHold bool) {
+			defer wg.Done()
+			<-start
+
+			var bookingID int64
+			var err error
+			if viaHold {
+				var hold *SeatHold
+				if hold, err = HoldSeats(userID, scheduleID, []int{seatID}, CheckoutOptions{}); err == nil {
+					var result *PaymentResult
+					if result, err = ProcessPayment(userID, Payment{HoldID: hold.HoldID}); err == nil {
+						bookingID = result.BookingID
+					}
+				}
+			} else {
+				bookingID, err = BookSeats(userID, scheduleID, []int{seatID})
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				winners = append(winners, bookingID)
+			case !errors.Is(err, ErrSeatsUnavailable):
+				failures = append(failures, err)
+			}
+		}(userID, i%2 == 0)
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range failures {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(winners) != 1 {
+		t.Fatalf("%d customers got the seat, want 1", len(winners))
+	}
+
+	var booked int
+	err := config.DB.QueryRow(`
+        SELECT COUNT(*) FROM BOOKING_SEAT bs JOIN BOOKING b ON b.bookingID = bs.bookingID
+        WHERE b.scheduleID = ? AND bs.seatID = ?
+    `, scheduleID, seatID).Scan(&booked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booked != 1 {
+		t.Fatalf("seat is on %d bookings, want 1", booked)
+	}
+}
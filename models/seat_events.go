@@ -0,0 +1,121 @@
+package models
+
+import (
+	"context"
+	"log"
+	"my-app/config"
+	"strconv"
+	"strings"
+)
+
+// SeatStatusChange is one seat moving to a new status within a schedule
+type SeatStatusChange struct {
+	SeatID int    `json:"seat_id"`
+	Status string `json:"status"`
+}
+
+// SeatEventPublisher receives every seat status change so it can be pushed to seat-picker
+// clients. It is set by main; when nil, changes are not published.
+var SeatEventPublisher func(scheduleID int, changes []SeatStatusChange)
+
+// publishSeatChanges announces that the given seats of a schedule now have the given status.
+// Call it only after the change is committed.
+func publishSeatChanges(scheduleID int, seatIDs []int, status string) {
+	if SeatEventPublisher == nil || len(seatIDs) == 0 {
+		return
+	}
+	changes := make([]SeatStatusChange, len(seatIDs))
+	for i, seatID := range seatIDs {
+		changes[i] = SeatStatusChange{SeatID: seatID, Status: status}
+	}
+	SeatEventPublisher(scheduleID, changes)
+}
+
+// WatchHoldExpiry publishes seats going back on sale when their Redis hold lock expires.
+// It relies on Redis keyspace notifications for expired keys and runs until ctx is cancelled.
+func WatchHoldExpiry(ctx context.Context) {
+	if config.RedisClient == nil {
+		return
+	}
+	if err := enableExpiryNotifications(ctx); err != nil {
+		log.Printf("Could not enable Redis expiry notifications, hold expiry will not be pushed unless notify-keyspace-events includes \"Ex\": %v", err)
+	}
+
+	pubsub := config.RedisClient.PSubscribe(ctx, "__keyevent@*__:expired")
+	defer pubsub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-pubsub.Channel():
+			if !ok {
+				return
+			}
+			scheduleID, seatID, ok := parseSeatHoldKey(msg.Payload)
+			if !ok {
+				continue
+			}
+			announceExpiredSeatHold(scheduleID, seatID)
+		}
+	}
+}
+
+// enableExpiryNotifications turns on keyevent notifications for expired keys. The setting is
+// server-wide, so the flags already configured are kept.
+func enableExpiryNotifications(ctx context.Context) error {
+	current, err := config.RedisClient.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return err
+	}
+	flags := ""
+	if len(current) == 2 {
+		flags, _ = current[1].(string)
+	}
+	if merged := withExpiryNotifications(flags); merged != flags {
+		return config.RedisClient.ConfigSet(ctx, "notify-keyspace-events", merged).Err()
+	}
+	return nil
+}
+
+// withExpiryNotifications adds keyevent (E) notifications of expired keys (x, or A for all) to
+// a notify-keyspace-events value
+func withExpiryNotifications(flags string) string {
+	if !strings.Contains(flags, "E") {
+		flags += "E"
+	}
+	if !strings.ContainsAny(flags, "xA") {
+		flags += "x"
+	}
+	return flags
+}
+
+// announceExpiredSeatHold publishes a seat whose hold ran out, unless it was booked meanwhile
+func announceExpiredSeatHold(scheduleID, seatID int) {
+	var status string
+	err := config.DB.QueryRow("SELECT status FROM SCHEDULE_SEAT WHERE scheduleID = ? AND seatID = ?", scheduleID, seatID).Scan(&status)
+	if err != nil {
+		log.Printf("Could not read seat %d of schedule %d after hold expiry: %v", seatID, scheduleID, err)
+		return
+	}
+	if status == SeatStatusAvailable {
+		publishSeatChanges(scheduleID, []int{seatID}, SeatStatusAvailable)
+	}
+}
+
+// parseSeatHoldKey extracts the schedule and seat from a "seat_hold:<schedule>:<seat>" key
+func parseSeatHoldKey(key string) (int, int, bool) {
+	parts := strings.Split(key, ":")
+	if len(parts) != 3 || parts[0] != "seat_hold" {
+		return 0, 0, false
+	}
+	scheduleID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	seatID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, false
+	}
+	return scheduleID, seatID, true
+}
@@ -0,0 +1,20 @@
+package models
+
+import "testing"
+
+func TestWithExpiryNotificationsKeepsConfiguredFlags(t *testing.T) {
+	tests := []struct {
+		flags, want string
+	}{
+		{"", "Ex"},
+		{"Ex", "Ex"},
+		{"Kg", "KgEx"},
+		{"KEA", "KEA"},
+		{"Ksx", "KsxE"},
+	}
+	for _, tt := range tests {
+		if got := withExpiryNotifications(tt.flags); got != tt.want {
+			t.Errorf("withExpiryNotifications(%q) = %q, want %q", tt.flags, got, tt.want)
+		}
+	}
+}
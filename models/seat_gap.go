@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrSeatGap is returned when a selection would strand a single empty seat and the theater enforces the rule
+var ErrSeatGap = errors.New("selection would leave a single empty seat")
+
+// CheckSeatGaps applies the theater's seat gap policy to a selection. Under WARN it returns the
+// warnings to show the customer; under ENFORCE an offending selection fails with ErrSeatGap.
+func CheckSeatGaps(scheduleID int, seatIDs []int) ([]string, error) {
+	theater, err := GetTheaterBySchedule(scheduleID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if theater.SeatGapPolicy == SeatGapPolicyOff {
+		return nil, nil
+	}
+
+	seats, err := GetSeatsByScheduleID(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasSeatPositions(seats) {
+		return nil, nil // neighbours are unknown without a seat map
+	}
+	orphans := findOrphanSeats(seats, seatIDs)
+	if len(orphans) == 0 {
+		return nil, nil
+	}
+
+	var warnings []string
+	for _, seat := range orphans {
+		warnings = append(warnings, fmt.Sprintf("seat %s%d would be left empty on its own", seat.RowLabel, seat.ColumnNumber))
+	}
+	if theater.SeatGapPolicy == SeatGapPolicyEnforce {
+		return nil, fmt.Errorf("%w: %s", ErrSeatGap, warnings[0])
+	}
+	return warnings, nil
+}
+
+// findOrphanSeats lists the free seats that the selection would leave boxed in on both sides
+// by taken seats, aisles or the end of the row. Seats that were already isolated are ignored.
+func findOrphanSeats(seats []ScheduleSeat, selected []int) []ScheduleSeat {
+	picked := make(map[int]bool, len(selected))
+	for _, seatID := range selected {
+		picked[seatID] = true
+	}
+
+	type position struct{ x, y int }
+	byPosition := make(map[position]ScheduleSeat, len(seats))
+	for _, seat := range seats {
+		byPosition[position{seat.PosX, seat.PosY}] = seat
+	}
+
+	// closed reports whether the neighbour at (x, y) stops a customer from sitting next to the
+	// seat, and whether that is because of the selection
+	closed := func(x, y int) (bool, bool) {
+		neighbour, ok := byPosition[position{x, y}]
+		if !ok {
+			return true, false // aisle, blocked cell or end of row
+		}
+		if picked[neighbour.SeatID] {
+			return true, true
+		}
+		return neighbour.Status != SeatStatusAvailable, false
+	}
+
+	var orphans []ScheduleSeat
+	for _, seat := range seats {
+		if seat.Status != SeatStatusAvailable || picked[seat.SeatID] {
+			continue
+		}
+		leftClosed, leftPicked := closed(seat.PosX-1, seat.PosY)
+		rightClosed, rightPicked := closed(seat.PosX+1, seat.PosY)
+		if leftClosed && rightClosed && (leftPicked || rightPicked) {
+			orphans = append(orphans, seat)
+		}
+	}
+
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].SeatNumber < orphans[j].SeatNumber })
+	return orphans
+}
+
+// hasSeatPositions reports whether the seats have been placed on a seat map
+func hasSeatPositions(seats []ScheduleSeat) bool {
+	for _, seat := range seats {
+		if seat.PosX != 0 || seat.PosY != 0 {
+			return true
+		}
+	}
+	return false
+}
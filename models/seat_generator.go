@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"my-app/config"
+)
+
+// SeatGridTemplate describes a rectangular auditorium to generate seats for
+type SeatGridTemplate struct {
+	FirstRow      string `json:"first_row"`     // e.g. "A"
+	LastRow       string `json:"last_row"`      // e.g. "M"
+	SeatsPerRow   int    `json:"seats_per_row"` // e.g. 18
+	AislesAfter   []int  `json:"aisles_after"`  // Seat numbers followed by an aisle, e.g. [6, 12]
+	CoupleLastRow bool   `json:"couple_last_row"`
+	Regenerate    bool   `json:"regenerate"` // Replace the existing seats of the screen
+}
+
+// Build turns the template into a seat layout for a screen
+func (t SeatGridTemplate) Build(screenID int) (*ScreenLayout, error) {
+	if len(t.FirstRow) != 1 || len(t.LastRow) != 1 {
+		return nil, errors.New("first_row and last_row must be single letters")
+	}
+	first, last := t.FirstRow[0], t.LastRow[0]
+	if first < 'A' || last > 'Z' || first > last {
+		return nil, errors.New("rows must be letters from A to Z in order")
+	}
+	if t.SeatsPerRow <= 0 {
+		return nil, errors.New("seats_per_row must be positive")
+	}
+
+	aisleAfter := make(map[int]bool)
+	for _, n := range t.AislesAfter {
+		if n <= 0 || n >= t.SeatsPerRow {
+			return nil, fmt.Errorf("aisle after seat %d is outside the row", n)
+		}
+		aisleAfter[n] = true
+	}
+
+	layout := &ScreenLayout{
+		ScreenID: screenID,
+		Rows:     int(last-first) + 1,
+		Columns:  t.SeatsPerRow + len(aisleAfter),
+	}
+
+	seatNumber := 0
+	for row := first; row <= last; row++ {
+		posY := int(row - first)
+		seatType := SeatTypeStandard
+		if t.CoupleLastRow && row == last {
+			seatType = SeatTypeCouple
+		}
+
+		posX := 0
+		for column := 1; column <= t.SeatsPerRow; column++ {
+			seatNumber++
+			layout.Seats = append(layout.Seats, Seat{
+				ScreenID:     screenID,
+				SeatNumber:   seatNumber,
+				RowLabel:     string(row),
+				ColumnNumber: column,
+				PosX:         posX,
+				PosY:         posY,
+				SeatType:     seatType,
+			})
+			posX++
+			if aisleAfter[column] {
+				layout.Cells = append(layout.Cells, LayoutCell{PosX: posX, PosY: posY, CellType: CellTypeAisle})
+				posX++
+			}
+		}
+	}
+
+	return layout, nil
+}
+
+// GenerateSeatGrid creates all seats of a screen from a template in one transaction.
+// A screen that already has seats is only regenerated on request, and only while none of its
+// upcoming schedules has bookings.
+func GenerateSeatGrid(screenID int, template SeatGridTemplate) (*ScreenLayout, error) {
+	layout, err := template.Build(screenID)
+	if err != nil {
+		return nil, err
+	}
+	if err := layout.validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM SEAT WHERE screenID = ?", screenID).Scan(&existing); err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		if !template.Regenerate {
+			return nil, errors.New("screen already has seats; set regenerate to replace them")
+		}
+
+		var upcoming int
+		err := tx.QueryRow(`
+            SELECT COUNT(*)
+            FROM SCHEDULE_SEAT ss
+            JOIN SCHEDULE s ON s.scheduleID = ss.scheduleID
+            WHERE s.screenID = ? AND ss.status = ? AND s.showTime > NOW()
+        `, screenID, SeatStatusBooked).Scan(&upcoming)
+		if err != nil {
+			return nil, err
+		}
+		if upcoming > 0 {
+			return nil, ErrLayoutHasBookings
+		}
+	}
+
+	if err := saveScreenLayout(tx, layout); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return layout, nil
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"my-app/config"
+)
+
+// TaxRate is what a theater charges on top of its ticket prices
+type TaxRate struct {
+	TheaterID  int   `json:"theater_id"`
+	VATRate    int   `json:"vat_rate"`    // Basis points, e.g. 2000 for 20%
+	BookingFee Money `json:"booking_fee"` // Per ticket
+}
+
+// DefaultTaxRate returns the rates configured for theaters without their own
+func DefaultTaxRate() TaxRate {
+	return TaxRate{VATRate: config.VATRate, BookingFee: Money(config.BookingFee)}
+}
+
+// GetTheaterTaxRate returns the rates in force at a theater
+func GetTheaterTaxRate(theaterID int) (TaxRate, error) {
+	return theaterTaxRate(config.DB, theaterID)
+}
+
+func theaterTaxRate(q queryRower, theaterID int) (TaxRate, error) {
+	rate := TaxRate{TheaterID: theaterID}
+	err := q.QueryRow("SELECT vatRate, bookingFee FROM THEATER_TAX_RATE WHERE theaterID = ?", theaterID).Scan(&rate.VATRate, &rate.BookingFee)
+	if err == sql.ErrNoRows {
+		rate = DefaultTaxRate()
+		rate.TheaterID = theaterID
+		return rate, nil
+	}
+	return rate, err
+}
+
+// SaveTheaterTaxRate creates or replaces a theater's own rates
+func SaveTheaterTaxRate(rate TaxRate) error {
+	if rate.VATRate < 0 || rate.VATRate > 10000 {
+		return errors.New("vat_rate must be between 0 and 10000 basis points")
+	}
+	if rate.BookingFee < 0 {
+		return errors.New("booking_fee cannot be negative")
+	}
+	var exists bool
+	if err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM THEATER WHERE theaterID = ?)", rate.TheaterID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("theater not found")
+	}
+
+	_, err := config.DB.Exec(`
+        INSERT INTO THEATER_TAX_RATE (theaterID, vatRate, bookingFee)
+        VALUES (?, ?, ?)
+        ON DUPLICATE KEY UPDATE vatRate = VALUES(vatRate), bookingFee = VALUES(bookingFee)
+    `, rate.TheaterID, rate.VATRate, rate.BookingFee)
+	return err
+}
+
+// DeleteTheaterTaxRate puts a theater back on the default rates
+func DeleteTheaterTaxRate(theaterID int) error {
+	_, err := config.DB.Exec("DELETE FROM THEATER_TAX_RATE WHERE theaterID = ?", theaterID)
+	return err
+}
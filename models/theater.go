@@ -1,19 +1,52 @@
 package models
 
-import "my-app/config"
+import (
+	"errors"
+	"fmt"
+	"my-app/config"
+	"strings"
+)
+
+// Seat gap policies decide what happens when a selection would leave a single empty seat
+const (
+	SeatGapPolicyOff     = "OFF"
+	SeatGapPolicyWarn    = "WARN"
+	SeatGapPolicyEnforce = "ENFORCE"
+)
 
 // Theater struct represents the theater entity
 type Theater struct {
-	TheaterID int    `json:"theater_id"`
-	Name      string `json:"name"`
-	Location  string `json:"location"`
+	TheaterID     int    `json:"theater_id"`
+	Name          string `json:"name"`
+	Location      string `json:"location"`
+	SeatGapPolicy string `json:"seat_gap_policy"` // OFF, WARN or ENFORCE
+}
+
+// ErrInvalidSeatGapPolicy is returned for a seat gap policy other than OFF, WARN or ENFORCE
+var ErrInvalidSeatGapPolicy = errors.New("invalid seat gap policy, use OFF, WARN or ENFORCE")
+
+// normalize validates the theater's settings and upper-cases the seat gap policy. An empty policy
+// is left as it is: a new theater starts with OFF and an update keeps the stored policy.
+func (theater *Theater) normalize() error {
+	theater.SeatGapPolicy = strings.ToUpper(strings.TrimSpace(theater.SeatGapPolicy))
+	switch theater.SeatGapPolicy {
+	case "", SeatGapPolicyOff, SeatGapPolicyWarn, SeatGapPolicyEnforce:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidSeatGapPolicy, theater.SeatGapPolicy)
 }
 
 // CreateTheater inserts a new theater into the database
 func CreateTheater(theater Theater) error {
+	if err := theater.normalize(); err != nil {
+		return err
+	}
+	if theater.SeatGapPolicy == "" {
+		theater.SeatGapPolicy = SeatGapPolicyOff
+	}
 	_, err := config.DB.Exec(
-		"INSERT INTO THEATER (name, location) VALUES (?, ?)",
-		theater.Name, theater.Location,
+		"INSERT INTO THEATER (name, location, seatGapPolicy) VALUES (?, ?, ?)",
+		theater.Name, theater.Location, theater.SeatGapPolicy,
 	)
 	if err != nil {
 		return err
@@ -23,7 +56,7 @@ func CreateTheater(theater Theater) error {
 
 // GetAllTheaters retrieves all theaters from the database
 func GetAllTheaters() ([]Theater, error) {
-	rows, err := config.DB.Query("SELECT theaterID, name, location FROM THEATER")
+	rows, err := config.DB.Query("SELECT theaterID, name, location, COALESCE(seatGapPolicy, 'OFF') FROM THEATER")
 	if err != nil {
 		return nil, err
 	}
@@ -32,7 +65,7 @@ func GetAllTheaters() ([]Theater, error) {
 	var theaters []Theater
 	for rows.Next() {
 		var theater Theater
-		err := rows.Scan(&theater.TheaterID, &theater.Name, &theater.Location)
+		err := rows.Scan(&theater.TheaterID, &theater.Name, &theater.Location, &theater.SeatGapPolicy)
 		if err != nil {
 			return nil, err
 		}
@@ -48,9 +81,12 @@ func GetAllTheaters() ([]Theater, error) {
 
 // UpdateTheater updates the details of an existing theater in the database
 func UpdateTheater(theater Theater) error {
+	if err := theater.normalize(); err != nil {
+		return err
+	}
 	// Update the theater details based on the ID
-	query := "UPDATE THEATER SET name = ?, location = ? WHERE theaterID = ?"
-	_, err := config.DB.Exec(query, theater.Name, theater.Location, theater.TheaterID)
+	query := "UPDATE THEATER SET name = ?, location = ?, seatGapPolicy = COALESCE(NULLIF(?, ''), seatGapPolicy) WHERE theaterID = ?"
+	_, err := config.DB.Exec(query, theater.Name, theater.Location, theater.SeatGapPolicy, theater.TheaterID)
 	return err
 }
 
@@ -62,3 +98,17 @@ func DeleteTheater(theaterID int) error {
 	}
 	return nil
 }
+
+// GetTheaterBySchedule retrieves the theater a schedule is shown in
+func GetTheaterBySchedule(scheduleID int) (Theater, error) {
+	var theater Theater
+	err := config.DB.QueryRow(`
+        SELECT t.theaterID, t.name, t.location, COALESCE(t.seatGapPolicy, 'OFF')
+        FROM SCHEDULE s
+        JOIN SCREEN sc ON sc.screenID = s.screenID
+        JOIN ROOM r ON r.roomID = sc.roomID
+        JOIN THEATER t ON t.theaterID = r.theaterID
+        WHERE s.scheduleID = ?
+    `, scheduleID).Scan(&theater.TheaterID, &theater.Name, &theater.Location, &theater.SeatGapPolicy)
+	return theater, err
+}
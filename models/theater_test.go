@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"my-app/config"
+	"testing"
+)
+
+func TestUpdateTheaterKeepsSeatGapPolicyWhenOmitted(t *testing.T) {
+	requireDB(t)
+	theaterID := insertTestRow(t, "INSERT INTO THEATER (name, location, seatGapPolicy) VALUES ('Test theater', '', ?)", SeatGapPolicyEnforce)
+
+	if err := UpdateTheater(Theater{TheaterID: theaterID, Name: "Renamed", Location: "Downtown"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var name, policy string
+	if err := config.DB.QueryRow("SELECT name, seatGapPolicy FROM THEATER WHERE theaterID = ?", theaterID).Scan(&name, &policy); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Renamed" || policy != SeatGapPolicyEnforce {
+		t.Fatalf("got name %q and policy %s, want %q and %s", name, policy, "Renamed", SeatGapPolicyEnforce)
+	}
+}
+
+func TestTheaterNormalizeSeatGapPolicy(t *testing.T) {
+	for raw, want := range map[string]string{" warn ": SeatGapPolicyWarn, "Enforce": SeatGapPolicyEnforce, "": ""} {
+		theater := Theater{SeatGapPolicy: raw}
+		if err := theater.normalize(); err != nil || theater.SeatGapPolicy != want {
+			t.Errorf("%q: got %q, %v, want %q", raw, theater.SeatGapPolicy, err, want)
+		}
+	}
+	for _, raw := range []string{"allow", "Block"} {
+		theater := Theater{SeatGapPolicy: raw}
+		if err := theater.normalize(); !errors.Is(err, ErrInvalidSeatGapPolicy) {
+			t.Errorf("%q: got %v, want %v", raw, err, ErrInvalidSeatGapPolicy)
+		}
+	}
+}
@@ -121,6 +121,7 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -134,28 +135,33 @@ type Booking struct {
 	ScreenID    int
 	BookingDate time.Time
 	SeatsBooked int
+	Status      string
 }
 
 type Ticket struct {
-	TicketID  int          `json:"ticket_id"`
-	BookingID int          `json:"booking_id"`
-	SeatID    int          `json:"seat_id"`
-	Fare      float64      `json:"fare"`
-	IssuedAt  sql.NullTime `json:"issued_at"`
-	QRCode    string       `json:"qr_code"`
+	TicketID        int          `json:"ticket_id"`
+	BookingID       int          `json:"booking_id"`
+	SeatID          int          `json:"seat_id"`
+	Category        string       `json:"category"`
+	EligibilityNote string       `json:"eligibility_note,omitempty"` // What door staff check for the category
+	Fare            Money        `json:"fare"`                       // Minor currency units
+	IssuedAt        sql.NullTime `json:"issued_at"`
+	QRCode          string       `json:"qr_code"`
 }
 
 // GetBookingByID retrieves the booking details by bookingID
 func GetBookingByID(bookingID int) (*Booking, error) {
 	var booking Booking
-	query := "SELECT bookingID, userID, movieID, screenID, bookingDate, seatsBooked FROM BOOKING WHERE bookingID = ?"
-	err := config.DB.QueryRow(query, bookingID).Scan(
+	var bookingDate sql.NullString
+	query := "SELECT bookingID, userID, movieID, screenID, bookingDate, seatsBooked, COALESCE(status, ?) FROM BOOKING WHERE bookingID = ?"
+	err := config.DB.QueryRow(query, BookingStatusPending, bookingID).Scan(
 		&booking.BookingID,
 		&booking.UserID,
 		&booking.MovieID,
 		&booking.ScreenID,
-		&booking.BookingDate,
+		&bookingDate,
 		&booking.SeatsBooked,
+		&booking.Status,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -163,20 +169,132 @@ func GetBookingByID(bookingID int) (*Booking, error) {
 		}
 		return nil, fmt.Errorf("error retrieving booking: %v", err)
 	}
+	if bookingDate.Valid {
+		booking.BookingDate, err = time.Parse("2006-01-02 15:04:05", bookingDate.String)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing booking date: %v", err)
+		}
+	}
 	return &booking, nil
 }
 
-// CreateTicket inserts a new ticket record into the TICKET table
-func CreateTicket(ticket *Ticket) (int, error) {
+// ticketColumns selects a ticket's fields in the order expected by scanTicket.
+// The category placeholder takes the default category.
+const ticketColumns = "ticketID, bookingID, seatID, COALESCE(category, ?), fare, issuedAt, qrCode"
+
+func scanTicket(row rowScanner) (*Ticket, error) {
+	var ticket Ticket
+	var issuedAt sql.NullString
+	if err := row.Scan(&ticket.TicketID, &ticket.BookingID, &ticket.SeatID, &ticket.Category, &ticket.Fare, &issuedAt, &ticket.QRCode); err != nil {
+		return nil, err
+	}
+	if issuedAt.Valid {
+		t, err := time.Parse("2006-01-02 15:04:05", issuedAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing issue date: %v", err)
+		}
+		ticket.IssuedAt = sql.NullTime{Time: t, Valid: true}
+	}
+	ticket.EligibilityNote = EligibilityNote(ticket.Category)
+	return &ticket, nil
+}
+
+// GetTicketsByBookingID retrieves the tickets issued for a booking
+func GetTicketsByBookingID(bookingID int) ([]Ticket, error) {
+	return getTickets(config.DB, bookingID)
+}
+
+func getTickets(q querier, bookingID int) ([]Ticket, error) {
+	rows, err := q.Query("SELECT "+ticketColumns+" FROM TICKET WHERE bookingID = ? ORDER BY ticketID", TicketCategoryAdult, bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tickets: %v", err)
+	}
+	defer rows.Close()
+
+	tickets := []Ticket{}
+	for rows.Next() {
+		ticket, err := scanTicket(rows)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, *ticket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// IssueTickets returns a booking's tickets, creating one for each seat recorded on the booking the
+// first time. The booking is locked while they are issued, so concurrent first downloads get the
+// same tickets.
+func IssueTickets(booking *Booking) ([]Ticket, error) {
+	seats, err := GetBookingSeats(int64(booking.BookingID))
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	if err := tx.QueryRow("SELECT bookingID FROM BOOKING WHERE bookingID = ? FOR UPDATE", booking.BookingID).Scan(&locked); err != nil {
+		return nil, err
+	}
+	tickets, err := getTickets(tx, booking.BookingID)
+	if err != nil || len(tickets) > 0 {
+		return tickets, err
+	}
+
+	for _, seat := range seats {
+		// QR codes are random, so a ticket cannot be made up from its booking and seat numbers
+		qrCode, err := newCode(20)
+		if err != nil {
+			return nil, err
+		}
+		ticket := Ticket{
+			BookingID:       booking.BookingID,
+			SeatID:          seat.SeatID,
+			Category:        seat.Category,
+			EligibilityNote: EligibilityNote(seat.Category),
+			Fare:            seat.Price,
+			IssuedAt:        sql.NullTime{Time: time.Now(), Valid: true},
+			QRCode:          qrCode,
+		}
+
+		ticketID, err := createTicket(tx, &ticket)
+		if err != nil {
+			return nil, fmt.Errorf("error creating ticket for seat ID %d: %v", seat.SeatID, err)
+		}
+		ticket.TicketID = ticketID
+		tickets = append(tickets, ticket)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// createTicket inserts a new ticket record into the TICKET table
+func createTicket(tx *sql.Tx, ticket *Ticket) (int, error) {
 	query := `
-        INSERT INTO TICKET (bookingID, seatID, fare, issuedAt, qrCode)
-        VALUES (?, ?, ?, ?, ?)
+        INSERT INTO TICKET (bookingID, seatID, category, fare, issuedAt, qrCode)
+        VALUES (?, ?, ?, ?, ?, ?)
     `
-	result, err := config.DB.Exec(query,
+	if ticket.Category == "" {
+		ticket.Category = TicketCategoryAdult
+	}
+	result, err := tx.Exec(query,
 		ticket.BookingID,
 		ticket.SeatID,
+		ticket.Category,
 		ticket.Fare,
-		sql.NullTime{Time: time.Now(), Valid: true},
+		ticket.IssuedAt,
 		ticket.QRCode,
 	)
 	if err != nil {
@@ -190,3 +308,57 @@ func CreateTicket(ticket *Ticket) (int, error) {
 
 	return int(ticketID), nil
 }
+
+var (
+	ErrTicketNotFound       = errors.New("ticket not found")
+	ErrTicketAlreadyScanned = errors.New("ticket has already been scanned")
+	ErrTicketNotValid       = errors.New("ticket's booking is not valid for entry")
+)
+
+// ScanTicket admits a ticket at the door and checks its booking in. The returned ticket carries
+// the eligibility note staff must check for its category.
+func ScanTicket(qrCode string, actor Actor) (*Ticket, error) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ticket, err := scanTicket(tx.QueryRow("SELECT "+ticketColumns+" FROM TICKET WHERE qrCode = ? FOR UPDATE", TicketCategoryAdult, qrCode))
+	if err == sql.ErrNoRows {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var status string
+	if err := tx.QueryRow("SELECT COALESCE(status, ?) FROM BOOKING WHERE bookingID = ?", BookingStatusPending, ticket.BookingID).Scan(&status); err != nil {
+		return nil, err
+	}
+	switch status {
+	case BookingStatusConfirmed:
+		if _, err := transitionBooking(tx, int64(ticket.BookingID), BookingStatusCheckedIn, actor); err != nil {
+			return nil, err
+		}
+	case BookingStatusCheckedIn:
+		// Another ticket of the booking was scanned first
+	default:
+		return nil, fmt.Errorf("%w: booking is %s", ErrTicketNotValid, status)
+	}
+
+	result, err := tx.Exec("UPDATE TICKET SET scannedAt = ? WHERE ticketID = ? AND scannedAt IS NULL", time.Now(), ticket.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	if scanned, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if scanned == 0 {
+		return nil, ErrTicketAlreadyScanned
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ticket, nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ticket categories. Each may be priced with CATEGORY rules; seats without a category are ADULT.
+const (
+	TicketCategoryAdult   = "ADULT"
+	TicketCategoryChild   = "CHILD"
+	TicketCategorySenior  = "SENIOR"
+	TicketCategoryStudent = "STUDENT"
+)
+
+// TicketCategory describes a category and what door staff must check when the ticket is scanned
+type TicketCategory struct {
+	Code            string `json:"code"`
+	Name            string `json:"name"`
+	EligibilityNote string `json:"eligibility_note,omitempty"`
+}
+
+var ticketCategories = []TicketCategory{
+	{Code: TicketCategoryAdult, Name: "Adult"},
+	{Code: TicketCategoryChild, Name: "Child", EligibilityNote: "Under 12 years old. Must be accompanied by an adult."},
+	{Code: TicketCategorySenior, Name: "Senior", EligibilityNote: "65 years or older. Check photo ID."},
+	{Code: TicketCategoryStudent, Name: "Student", EligibilityNote: "Check a valid student card."},
+}
+
+// TicketCategories lists every ticket category
+func TicketCategories() []TicketCategory {
+	return append([]TicketCategory(nil), ticketCategories...)
+}
+
+// ValidTicketCategory reports whether code is a known ticket category
+func ValidTicketCategory(code string) bool {
+	for _, category := range ticketCategories {
+		if category.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// EligibilityNote returns what door staff must check for a category, if anything
+func EligibilityNote(code string) string {
+	for _, category := range ticketCategories {
+		if category.Code == code {
+			return category.EligibilityNote
+		}
+	}
+	return ""
+}
+
+// seatCategories resolves the category of every selected seat, defaulting to ADULT
+func seatCategories(seatIDs []int, requested map[int]string) (map[int]string, error) {
+	selected := make(map[int]bool, len(seatIDs))
+	for _, seatID := range seatIDs {
+		selected[seatID] = true
+	}
+
+	categories := make(map[int]string, len(seatIDs))
+	for _, seatID := range seatIDs {
+		categories[seatID] = TicketCategoryAdult
+	}
+	for seatID, code := range requested {
+		if !selected[seatID] {
+			return nil, fmt.Errorf("ticket category given for seat %d, which is not selected", seatID)
+		}
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if !ValidTicketCategory(code) {
+			return nil, fmt.Errorf("invalid ticket category %q", code)
+		}
+		categories[seatID] = code
+	}
+	return categories, nil
+}
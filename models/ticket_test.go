@@ -0,0 +1,56 @@
+package models
+
+import (
+	"my-app/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIssueTicketsConcurrentlyIssuesOneSet(t *testing.T) {
+	requireDB(t)
+	userID := createTestUser(t)
+	bookingID := insertTestRow(t, "INSERT INTO BOOKING (userID, movieID, screenID, bookingDate, seatsBooked, status, subtotal, total) VALUES (?, 0, 0, ?, 2, ?, 2000, 2000)",
+		userID, time.Now(), BookingStatusConfirmed)
+	for _, seatID := range []int{1, 2} {
+		if _, err := config.DB.Exec("INSERT INTO BOOKING_SEAT (bookingID, seatID, category, price) VALUES (?, ?, ?, 1000)", bookingID, seatID, TicketCategoryAdult); err != nil {
+			t.Fatal(err)
+		}
+	}
+	booking, err := GetBookingByID(bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const downloads = 5
+	results := make([][]Ticket, downloads)
+	errs := make([]error, downloads)
+	var wg sync.WaitGroup
+	for i := 0; i < downloads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = IssueTickets(booking)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("download %d: %v", i, errs[i])
+		}
+		if len(results[i]) != 2 || results[i][0].TicketID != results[0][0].TicketID || results[i][1].TicketID != results[0][1].TicketID {
+			t.Fatalf("download %d got tickets %+v, want %+v", i, results[i], results[0])
+		}
+	}
+	tickets, err := GetTicketsByBookingID(bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tickets) != 2 {
+		t.Fatalf("%d tickets stored, want 2", len(tickets))
+	}
+	if tickets[0].QRCode == tickets[1].QRCode {
+		t.Fatalf("both tickets have QR code %s", tickets[0].QRCode)
+	}
+}
@@ -18,13 +18,29 @@ type User struct {
 	Gender   string `json:"gender"` // Male, Female, Other
 }
 
-// Register a new user
-func RegisterUser(user User) error {
+// Register a new user. guestID names a guest checkout the customer has proved is theirs with its
+// booking-access token; that guest becomes the account and keeps its bookings. Without it the account
+// starts empty, even when guests have checked out with the same email.
+func RegisterUser(user User, guestID int) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 
+	if guestID != 0 {
+		result, err := config.DB.Exec("UPDATE users SET name = ?, password = ?, phone = ?, role = ?, gender = ? WHERE id = ? AND email = ? AND role = ?",
+			user.Name, hashedPassword, user.Phone, user.Role, user.Gender, guestID, user.Email, RoleGuest)
+		if err != nil {
+			return err
+		}
+		if upgraded, err := result.RowsAffected(); err != nil {
+			return err
+		} else if upgraded == 0 {
+			return ErrGuestNotClaimed
+		}
+		return nil
+	}
+
 	_, err = config.DB.Exec("INSERT INTO users (email, name, password, phone, role, gender) VALUES (?, ?, ?, ?, ?, ?)",
 		user.Email, user.Name, hashedPassword, user.Phone, user.Role, user.Gender)
 	return err
@@ -34,7 +50,8 @@ func RegisterUser(user User) error {
 func AuthenticateUser(email, password string) (User, bool, error) {
 	var user User
 	var hashedPassword string
-	err := config.DB.QueryRow("SELECT id, email, name, password, role FROM users WHERE email = ?", email).
+	// Guests have no password to log in with
+	err := config.DB.QueryRow("SELECT id, email, name, password, role FROM users WHERE email = ? AND role <> ?", email, RoleGuest).
 		Scan(&user.ID, &user.Email, &user.Name, &hashedPassword, &user.Role)
 	if err == sql.ErrNoRows {
 		return user, false, errors.New("user not found")
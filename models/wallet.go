@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"my-app/config"
+)
+
+// Wallet is a user's stored-value balance, topped up by admins and by refunds
+type Wallet struct {
+	UserID  int           `json:"user_id"`
+	Balance Money         `json:"balance"`
+	Ledger  []LedgerEntry `json:"ledger"`
+}
+
+// GetWallet retrieves a user's wallet with its ledger. Users without a wallet have a zero balance.
+func GetWallet(userID int) (*Wallet, error) {
+	wallet := Wallet{UserID: userID}
+	err := config.DB.QueryRow("SELECT balance FROM WALLET WHERE userID = ?", userID).Scan(&wallet.Balance)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if wallet.Ledger, err = getLedger("userID", userID); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// CreditWallet lets an admin add to a user's wallet, e.g. as a goodwill gesture
+func CreditWallet(userID int, amount Money, actor Actor, note string) (Money, error) {
+	if amount <= 0 {
+		return 0, errors.New("amount must be positive")
+	}
+	if _, err := GetUserByID(userID); err != nil {
+		return 0, err
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	balance, err := creditWallet(tx, userID, amount, LedgerCredit, nil, actor, note)
+	if err != nil {
+		return 0, err
+	}
+	return balance, tx.Commit()
+}
+
+// lockWallet locks a user's wallet row, creating an empty wallet on first use
+func lockWallet(tx *sql.Tx, userID int) (Money, error) {
+	if _, err := tx.Exec("INSERT IGNORE INTO WALLET (userID, balance) VALUES (?, 0)", userID); err != nil {
+		return 0, err
+	}
+	var balance Money
+	err := tx.QueryRow("SELECT balance FROM WALLET WHERE userID = ? FOR UPDATE", userID).Scan(&balance)
+	return balance, err
+}
+
+func creditWallet(tx *sql.Tx, userID int, amount Money, reason string, bookingID *int64, actor Actor, note string) (Money, error) {
+	balance, err := lockWallet(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	balance += amount
+	if _, err := tx.Exec("UPDATE WALLET SET balance = ? WHERE userID = ?", balance, userID); err != nil {
+		return 0, err
+	}
+	entry := LedgerEntry{Account: AccountWallet, UserID: &userID, Amount: amount, BalanceAfter: balance, Reason: reason, BookingID: bookingID, ActorID: actor.UserID, Note: note}
+	if err := recordLedgerEntry(tx, &entry); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// debitWallet spends from a user's wallet on a booking inside the payment's transaction
+func debitWallet(tx *sql.Tx, userID int, amount Money, bookingID int64, actor Actor) error {
+	balance, err := lockWallet(tx, userID)
+	if err != nil {
+		return err
+	}
+	if balance < amount {
+		return fmt.Errorf("%w: wallet balance is %s", ErrInsufficientBalance, balance)
+	}
+	balance -= amount
+	if _, err := tx.Exec("UPDATE WALLET SET balance = ? WHERE userID = ?", balance, userID); err != nil {
+		return err
+	}
+	entry := LedgerEntry{Account: AccountWallet, UserID: &userID, Amount: -amount, BalanceAfter: balance, Reason: LedgerPayment, BookingID: &bookingID, ActorID: actor.UserID}
+	return recordLedgerEntry(tx, &entry)
+}
@@ -1,8 +1,10 @@
 package routes
 
 import (
+	"my-app/config"
 	"my-app/controllers"
 	"my-app/middlewares"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -39,6 +41,11 @@ func SetupRoutes(r *gin.Engine) {
 	user.Use(middlewares.JWTAuthMiddleware("user"))
 	{
 		user.GET("/profile", controllers.UserProfile)
+		user.GET("/wallet", controllers.GetMyWallet)
+		user.GET("/membership", controllers.GetMyMembership)
+		user.POST("/membership", controllers.SubscribeMembership)
+		user.POST("/membership/renew", controllers.RenewMembership)
+		user.POST("/membership/cancel", controllers.CancelMembership)
 
 	}
 
@@ -46,18 +53,52 @@ func SetupRoutes(r *gin.Engine) {
 	booking := r.Group("/user")
 	booking.Use(middlewares.JWTAuthMiddleware("user", "admin"))
 	{
-		booking.POST("/book", controllers.BookTickets)
-		booking.POST("/payment", controllers.ProcessPayment)
+		booking.POST("/book", middlewares.IdempotencyMiddleware(), controllers.BookTickets)
+		booking.POST("/payment", middlewares.IdempotencyMiddleware(), controllers.ProcessPayment)
+		booking.GET("/holds/:hold_id", controllers.GetHold)
+		booking.DELETE("/holds/:hold_id", controllers.ReleaseHold)
 		booking.GET("/bookings/:user_id", controllers.GetBookingsByUserID)
 		booking.GET("/bookings", controllers.GetAllBookings)
-		booking.DELETE("/bookings/:booking_id", controllers.DeleteBooking)
+		booking.DELETE("/bookings/:booking_id", controllers.CancelBooking)
+		booking.POST("/bookings/:booking_id/cancel", controllers.CancelBooking)
+		booking.GET("/booking/:booking_id/history", controllers.GetBookingHistory)
+		booking.GET("/booking/:booking_id/receipt", controllers.GetBookingReceipt)
 	}
+	// Guest checkout: hold seats with an email and phone, then pay with the guest token
+	guest := r.Group("/guest")
+	{
+		guest.POST("/book", middlewares.RateLimitByIP("guest-book", config.GuestBookingsPerIP, time.Hour), controllers.GuestBookTickets)
+	}
+	guestCheckout := r.Group("/guest")
+	guestCheckout.Use(middlewares.JWTAuthMiddleware("guest"))
+	{
+		guestCheckout.POST("/payment", middlewares.IdempotencyMiddleware(), controllers.GuestPayment)
+		guestCheckout.GET("/holds/:hold_id", controllers.GetHold)
+		guestCheckout.DELETE("/holds/:hold_id", controllers.ReleaseHold)
+	}
+	// A guest's booking, reached with the booking-access token issued at payment
+	guestBooking := r.Group("/guest/booking")
+	guestBooking.Use(middlewares.BookingAccessMiddleware())
+	{
+		guestBooking.GET("", controllers.GetGuestBooking)
+		guestBooking.GET("/tickets", controllers.GetGuestTickets)
+		guestBooking.GET("/receipt", controllers.GetGuestReceipt)
+		guestBooking.POST("/cancel", controllers.CancelGuestBooking)
+	}
+
 	// Ticket management routes
 	ticket := r.Group("/tickets")
 	ticket.Use(middlewares.JWTAuthMiddleware("user", "admin"))
 	{
-		ticket.GET("/booking/:bookingID", controllers.CreateTicketsForBookingHandler)
-		ticket.POST("/create-for-booking", controllers.CreateTicketsForBookingHandler)
+		ticket.GET("/booking/:bookingID", controllers.GetTicketsByBookingIDHandler)
+		ticket.POST("/create-for-booking", middlewares.IdempotencyMiddleware(), controllers.CreateTicketsForBookingHandler)
+	}
+
+	// Door staff check tickets in by QR code
+	ticketAdmin := r.Group("/tickets")
+	ticketAdmin.Use(middlewares.JWTAuthMiddleware("admin"))
+	{
+		ticketAdmin.POST("/scan", controllers.ScanTicket)
 	}
 
 	// Public routes for theaters
@@ -73,6 +114,9 @@ func SetupRoutes(r *gin.Engine) {
 		theaterAdmin.POST("/", controllers.CreateTheater)      // Create a new theater
 		theaterAdmin.PUT("/:id", controllers.UpdateTheater)    // Update a theater by ID
 		theaterAdmin.DELETE("/:id", controllers.DeleteTheater) // Delete a theater by ID
+		theaterAdmin.GET("/:id/tax-rate", controllers.GetTheaterTaxRate)
+		theaterAdmin.PUT("/:id/tax-rate", controllers.SetTheaterTaxRate)
+		theaterAdmin.DELETE("/:id/tax-rate", controllers.DeleteTheaterTaxRate)
 	}
 	// Public routes for schedules
 	schedulePublic := r.Group("/schedule")
@@ -97,6 +141,96 @@ func SetupRoutes(r *gin.Engine) {
 	seats.Use(middlewares.JWTAuthMiddleware()) // Dành cho người dùng đã đăng nhập
 	{
 		seats.GET("/:screenID", controllers.GetSeatsByScreenID)
+		seats.GET("/layout/:screenID", controllers.GetScreenLayout)
+	}
+
+	// Public price quotes
+	pricingPublic := r.Group("/pricing")
+	{
+		pricingPublic.POST("/quote", controllers.QuotePrice)
+		pricingPublic.GET("/categories", controllers.GetTicketCategories)
+	}
+
+	// Admin routes for pricing rules and holiday calendars
+	pricingAdmin := r.Group("/pricing")
+	pricingAdmin.Use(middlewares.JWTAuthMiddleware("admin"))
+	{
+		pricingAdmin.GET("/rule-sets", controllers.GetPriceRuleSets)
+		pricingAdmin.POST("/rule-sets", controllers.CreatePriceRuleSet)
+		pricingAdmin.GET("/rule-sets/:id", controllers.GetPriceRuleSet)
+		pricingAdmin.PUT("/rule-sets/:id", controllers.UpdatePriceRuleSet)
+		pricingAdmin.DELETE("/rule-sets/:id", controllers.DeletePriceRuleSet)
+		pricingAdmin.GET("/holidays", controllers.GetHolidays)
+		pricingAdmin.POST("/holidays", controllers.CreateHoliday)
+		pricingAdmin.DELETE("/holidays/:id", controllers.DeleteHoliday)
+	}
+
+	// Admin routes for promo codes
+	promoAdmin := r.Group("/promos")
+	promoAdmin.Use(middlewares.JWTAuthMiddleware("admin"))
+	{
+		promoAdmin.GET("/", controllers.GetPromoCodes)
+		promoAdmin.POST("/", controllers.CreatePromoCode)
+		promoAdmin.GET("/:id", controllers.GetPromoCode)
+		promoAdmin.PUT("/:id", controllers.UpdatePromoCode)
+		promoAdmin.DELETE("/:id", controllers.DeactivatePromoCode)
+	}
+
+	// Public concession menus
+	concessionPublic := r.Group("/concessions")
+	{
+		concessionPublic.GET("/theater/:theaterID", controllers.GetConcessionMenu)
+	}
+
+	// Admin routes for the concession catalogue and counter pickups
+	concessionAdmin := r.Group("/concessions")
+	concessionAdmin.Use(middlewares.JWTAuthMiddleware("admin"))
+	{
+		concessionAdmin.GET("/theater/:theaterID/all", controllers.GetConcessionCatalogue)
+		concessionAdmin.POST("/", controllers.CreateConcessionItem)
+		concessionAdmin.GET("/:id", controllers.GetConcessionItem)
+		concessionAdmin.PUT("/:id", controllers.UpdateConcessionItem)
+		concessionAdmin.DELETE("/:id", controllers.DeactivateConcessionItem)
+		concessionAdmin.POST("/redeem", controllers.RedeemPickupCode)
+	}
+
+	// Admin routes for gift cards
+	giftCardAdmin := r.Group("/gift-cards")
+	giftCardAdmin.Use(middlewares.JWTAuthMiddleware("admin"))
+	{
+		giftCardAdmin.GET("/", controllers.GetGiftCards)
+		giftCardAdmin.POST("/", controllers.IssueGiftCard)
+		giftCardAdmin.GET("/:id", controllers.GetGiftCard)
+		giftCardAdmin.POST("/:id/void", controllers.VoidGiftCard)
+	}
+
+	// Payment provider callbacks, authenticated by their signature
+	r.POST("/payments/callback", controllers.PaymentCallback)
+
+	// Public routes for membership plans
+	membershipPublic := r.Group("/memberships")
+	{
+		membershipPublic.GET("/plans", controllers.GetMembershipPlans)
+	}
+
+	// Admin routes for membership plans
+	membershipAdmin := r.Group("/memberships")
+	membershipAdmin.Use(middlewares.JWTAuthMiddleware("admin"))
+	{
+		membershipAdmin.GET("/plans/all", controllers.GetAllMembershipPlans)
+		membershipAdmin.POST("/plans", controllers.CreateMembershipPlan)
+		membershipAdmin.PUT("/plans/:id", controllers.UpdateMembershipPlan)
+		membershipAdmin.DELETE("/plans/:id", controllers.DeactivateMembershipPlan)
+	}
+
+	// Admin routes for loyalty earn rates
+	loyaltyAdmin := r.Group("/loyalty")
+	loyaltyAdmin.Use(middlewares.JWTAuthMiddleware("admin"))
+	{
+		loyaltyAdmin.GET("/earn-rates", controllers.GetEarnRates)
+		loyaltyAdmin.POST("/earn-rates", controllers.CreateEarnRate)
+		loyaltyAdmin.PUT("/earn-rates/:id", controllers.UpdateEarnRate)
+		loyaltyAdmin.DELETE("/earn-rates/:id", controllers.DeleteEarnRate)
 	}
 
 	// Admin-only routes for seats
@@ -107,6 +241,8 @@ func SetupRoutes(r *gin.Engine) {
 		seatsAdmin.GET("/", controllers.GetAllSeats)
 		seatsAdmin.PUT("/:id", controllers.UpdateSeatHandler)
 		seatsAdmin.DELETE("/:id", controllers.DeleteSeat)
+		seatsAdmin.PUT("/layout/:screenID", controllers.SaveScreenLayout)
+		seatsAdmin.POST("/generate/:screenID", controllers.GenerateSeatGrid)
 	}
 
 	// Room management routes (Admin only)
@@ -146,6 +282,7 @@ func SetupRoutes(r *gin.Engine) {
 	r.GET("/chat/messages", controllers.GetMessagesHandler) // Lấy tất cả tin nhắn
 	r.POST("/chat/messages", controllers.AddMessageHandler) // Thêm tin nhắn mới
 	r.GET("/ws", controllers.ChatWebSocketHandler)
+	r.GET("/ws/schedule/:scheduleID", controllers.ScheduleSeatsWebSocketHandler) // Live seat updates
 	// Movie management routes (Admin only)
 	moviePublic := r.Group("/movie")
 	{
@@ -169,6 +306,18 @@ func SetupRoutes(r *gin.Engine) {
 		admin.GET("/users", controllers.GetAllUsers)
 		admin.PUT("/update/:id", controllers.UpdateUserByID)
 		admin.DELETE("/delete/:id", controllers.DeleteUserByID)
+		admin.PUT("/bookings/:booking_id/status", controllers.UpdateBookingStatus)
+		admin.GET("/bookings/:booking_id/history", controllers.GetBookingHistory)
+		admin.GET("/jobs", controllers.GetJobMetrics)
+		admin.GET("/users/:id/purchase-limits", controllers.GetUserPurchaseLimits)
+		admin.PUT("/users/:id/purchase-limits", controllers.SetUserPurchaseLimits)
+		admin.DELETE("/users/:id/purchase-limits", controllers.DeleteUserPurchaseLimits)
+		admin.GET("/reports/revenue", controllers.GetRevenueReport)
+		admin.GET("/users/:id/wallet", controllers.GetUserWallet)
+		admin.POST("/users/:id/wallet/credit", controllers.CreditUserWallet)
+		admin.GET("/users/:id/loyalty", controllers.GetUserLoyalty)
+		admin.GET("/bookings/:booking_id/payments", controllers.GetBookingPayments)
+		admin.PUT("/payments/:id/status", controllers.SettlePayment)
 	}
 
 }
@@ -3,6 +3,7 @@ package sockets
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"my-app/models"
 	"net/http"
@@ -13,12 +14,13 @@ import (
 )
 
 type Client struct {
-	ID      string
-	Conn    *websocket.Conn
-	Send    chan []byte
-	Room    string
-	IsAdmin bool
-	UserID  int // Có thể lưu userID nếu người dùng đã đăng nhập
+	ID         string
+	Conn       *websocket.Conn
+	Send       chan []byte
+	Room       string
+	IsAdmin    bool
+	UserID     int  // Có thể lưu userID nếu người dùng đã đăng nhập
+	ListenOnly bool // Clients of seat rooms only receive updates
 }
 
 type Message struct {
@@ -28,10 +30,24 @@ type Message struct {
 	IsAdmin bool   `json:"isAdmin"`
 }
 
+// RoomEvent is a pre-encoded payload sent to every client of a room
+type RoomEvent struct {
+	Room    string
+	Payload []byte
+}
+
+// SeatStatusEvent tells seat-picker clients which seats of a schedule changed status
+type SeatStatusEvent struct {
+	Type       string                    `json:"type"`
+	ScheduleID int                       `json:"schedule_id"`
+	Seats      []models.SeatStatusChange `json:"seats"`
+}
+
 type Hub struct {
 	Clients    map[*Client]bool
 	Rooms      map[string]map[*Client]bool
 	Broadcast  chan Message
+	Events     chan RoomEvent
 	Register   chan *Client
 	Unregister chan *Client
 }
@@ -42,11 +58,35 @@ func NewHub() *Hub {
 		Clients:    make(map[*Client]bool),
 		Rooms:      make(map[string]map[*Client]bool),
 		Broadcast:  make(chan Message),
+		Events:     make(chan RoomEvent, 256),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
 	}
 }
 
+// clientSendBuffer lets a client fall a little behind before the hub drops it
+const clientSendBuffer = 64
+
+// ScheduleRoom is the room name for live seat updates of a schedule
+func ScheduleRoom(scheduleID int) string {
+	return fmt.Sprintf("schedule:%d", scheduleID)
+}
+
+// PublishSeatChanges pushes a seat status delta to everyone watching the schedule
+func PublishSeatChanges(scheduleID int, changes []models.SeatStatusChange) {
+	payload, err := json.Marshal(SeatStatusEvent{Type: "seat_status", ScheduleID: scheduleID, Seats: changes})
+	if err != nil {
+		log.Println("Failed to marshal seat event:", err)
+		return
+	}
+
+	select {
+	case HubInstance.Events <- RoomEvent{Room: ScheduleRoom(scheduleID), Payload: payload}:
+	default:
+		log.Printf("Seat event queue full, dropping update for schedule %d", scheduleID)
+	}
+}
+
 // Exported HubInstance for access in main.go
 var HubInstance = NewHub()
 
@@ -87,7 +127,7 @@ func ServeWs(w http.ResponseWriter, r *http.Request) {
 	client := &Client{
 		ID:      clientID,
 		Conn:    conn,
-		Send:    make(chan []byte),
+		Send:    make(chan []byte, clientSendBuffer),
 		Room:    room,
 		IsAdmin: isAdmin,
 		UserID:  userID,
@@ -100,6 +140,28 @@ func ServeWs(w http.ResponseWriter, r *http.Request) {
 	go client.writePump()
 }
 
+// ServeScheduleWs subscribes a client to live seat updates of one schedule
+func ServeScheduleWs(w http.ResponseWriter, r *http.Request, scheduleID int) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Upgrade error:", err)
+		return
+	}
+
+	client := &Client{
+		ID:         uuid.NewString(),
+		Conn:       conn,
+		Send:       make(chan []byte, clientSendBuffer),
+		Room:       ScheduleRoom(scheduleID),
+		ListenOnly: true,
+	}
+
+	HubInstance.Register <- client
+
+	go client.readPump()
+	go client.writePump()
+}
+
 // readPump pumps messages from the WebSocket connection to the Hub
 func (c *Client) readPump() {
 	defer func() {
@@ -117,6 +179,11 @@ func (c *Client) readPump() {
 			break
 		}
 
+		// Seat rooms are one-way; anything the client sends is ignored
+		if c.ListenOnly {
+			continue
+		}
+
 		// Gắn thêm thông tin vào tin nhắn
 		msg.Room = c.Room
 		msg.IsAdmin = c.IsAdmin
@@ -183,21 +250,30 @@ func (h *Hub) Run() {
 				log.Printf("Client %s disconnected\n", client.ID)
 			}
 		case message := <-h.Broadcast:
-			if clients, ok := h.Rooms[message.Room]; ok {
-				msgBytes, err := json.Marshal(message)
-				if err != nil {
-					log.Println("Failed to marshal message:", err)
-					continue
-				}
-				for client := range clients {
-					select {
-					case client.Send <- msgBytes:
-					default:
-						close(client.Send)
-						delete(h.Clients, client)
-					}
-				}
+			msgBytes, err := json.Marshal(message)
+			if err != nil {
+				log.Println("Failed to marshal message:", err)
+				continue
 			}
+			h.sendToRoom(message.Room, msgBytes)
+		case event := <-h.Events:
+			h.sendToRoom(event.Room, event.Payload)
 		}
 	}
 }
+
+// sendToRoom delivers a payload to every client of a room, dropping clients that cannot keep up
+func (h *Hub) sendToRoom(room string, payload []byte) {
+	for client := range h.Rooms[room] {
+		select {
+		case client.Send <- payload:
+		default:
+			close(client.Send)
+			delete(h.Clients, client)
+			delete(h.Rooms[room], client)
+		}
+	}
+	if len(h.Rooms[room]) == 0 {
+		delete(h.Rooms, room)
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// BookingAccessTokenTTL is how long a guest can use the link to their booking
+const BookingAccessTokenTTL = 90 * 24 * time.Hour
+
+// bookingAccessKey signs booking-access tokens. It differs from secretKey so that a booking-access
+// token can never be used as a login token, or the other way round.
+var bookingAccessKey = append([]byte("booking-access:"), secretKey...)
+
+// BookingAccessClaims grant access to a single booking without logging in
+type BookingAccessClaims struct {
+	BookingID int64 `json:"booking_id"`
+	UserID    uint  `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// GenerateBookingAccessToken creates a signed token that lets a guest view, download or cancel one booking
+func GenerateBookingAccessToken(bookingID int64, userID uint) (string, error) {
+	claims := &BookingAccessClaims{
+		BookingID: bookingID,
+		UserID:    userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(BookingAccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(bookingAccessKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate booking access token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ValidateBookingAccessToken checks a booking-access token and returns its claims
+func ValidateBookingAccessToken(tokenStr string) (*BookingAccessClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &BookingAccessClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return bookingAccessKey, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	claims, ok := token.Claims.(*BookingAccessClaims)
+	if !ok || !token.Valid || claims.BookingID == 0 {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,34 @@
+package workers
+
+import (
+	"context"
+	"my-app/config"
+	"my-app/models"
+	"time"
+)
+
+// ExpiryJob expires unpaid bookings and seat holds past their deadline and frees their seats
+func ExpiryJob() Job {
+	return Job{
+		Name:     "expiry",
+		Interval: config.ExpirySweepInterval,
+		Run:      sweepExpired,
+	}
+}
+
+func sweepExpired(ctx context.Context) (map[string]int, error) {
+	now := time.Now()
+	counts := map[string]int{}
+
+	bookings, bookingSeats, err := models.ExpireOverdueBookings(now)
+	counts["bookings_expired"] = bookings
+	counts["seats_released"] = bookingSeats
+	if err != nil {
+		return counts, err
+	}
+
+	holds, holdSeats, err := models.SweepExpiredHolds(now)
+	counts["holds_released"] = holds
+	counts["seats_released"] += holdSeats
+	return counts, err
+}
@@ -0,0 +1,26 @@
+package workers
+
+import (
+	"context"
+	"my-app/config"
+	"my-app/models"
+	"time"
+)
+
+// MembershipRenewalJob charges memberships for their next month, and ends those that were cancelled
+func MembershipRenewalJob() Job {
+	return Job{
+		Name:     "membership_renewal",
+		Interval: config.MembershipRenewalInterval,
+		Run:      renewMemberships,
+	}
+}
+
+func renewMemberships(ctx context.Context) (map[string]int, error) {
+	renewed, lapsed, ended, err := models.RenewDueMemberships(time.Now())
+	return map[string]int{
+		"memberships_renewed": renewed,
+		"memberships_lapsed":  lapsed,
+		"memberships_ended":   ended,
+	}, err
+}
@@ -0,0 +1,238 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"my-app/config"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Job is a task the scheduler runs every Interval. Run returns counters describing what it did.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) (map[string]int, error)
+}
+
+// JobMetrics describes the runs of one job in this process
+type JobMetrics struct {
+	Name      string         `json:"name"`
+	Interval  string         `json:"interval"`
+	Runs      int            `json:"runs"`
+	Skipped   int            `json:"skipped"` // Runs left to another process holding the lock
+	Failures  int            `json:"failures"`
+	LastRun   time.Time      `json:"last_run"`
+	LastTook  string         `json:"last_took"`
+	LastError string         `json:"last_error,omitempty"`
+	Last      map[string]int `json:"last"`
+	Totals    map[string]int `json:"totals"`
+}
+
+// Scheduler runs registered jobs periodically until its context is cancelled
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    []Job
+	metrics map[string]*JobMetrics
+	wg      sync.WaitGroup
+}
+
+// SchedulerInstance is the scheduler started by main
+var SchedulerInstance = NewScheduler()
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{metrics: make(map[string]*JobMetrics)}
+}
+
+// Register adds a job; it must be called before Start
+func (s *Scheduler) Register(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job)
+	s.metrics[job.Name] = &JobMetrics{
+		Name:     job.Name,
+		Interval: job.Interval.String(),
+		Last:     map[string]int{},
+		Totals:   map[string]int{},
+	}
+}
+
+// Start runs every registered job in its own goroutine
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	jobs := append([]Job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Wait blocks until every job has finished its current run after the context was cancelled
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Metrics returns a snapshot of every job's metrics
+func (s *Scheduler) Metrics() []JobMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var snapshot []JobMetrics
+	for _, job := range s.jobs {
+		m := *s.metrics[job.Name]
+		m.Last = copyCounts(m.Last)
+		m.Totals = copyCounts(m.Totals)
+		snapshot = append(snapshot, m)
+	}
+	return snapshot
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce runs a job if this process wins the job's lock
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	unlock, ok := acquireLock(ctx, job)
+	if !ok {
+		s.record(job.Name, func(m *JobMetrics) { m.Skipped++ })
+		return
+	}
+	defer unlock()
+
+	start := time.Now()
+	counts, err := job.Run(ctx)
+	took := time.Since(start)
+
+	s.record(job.Name, func(m *JobMetrics) {
+		m.Runs++
+		m.LastRun = start
+		m.LastTook = took.String()
+		m.LastError = ""
+		m.Last = copyCounts(counts)
+		for key, n := range counts {
+			m.Totals[key] += n
+		}
+		if err != nil {
+			m.Failures++
+			m.LastError = err.Error()
+		}
+	})
+
+	if err != nil {
+		log.Printf("Job %s failed after %s: %v %v", job.Name, took, counts, err)
+	} else if hasWork(counts) {
+		log.Printf("Job %s finished in %s: %v", job.Name, took, counts)
+	}
+}
+
+func (s *Scheduler) record(name string, update func(m *JobMetrics)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(s.metrics[name])
+}
+
+// unlockScript deletes a lock only if it still belongs to the given owner
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// renewScript extends a lock only if it still belongs to the given owner
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// acquireLock takes a Redis lock so that only one process runs a job at a time. The lock lasts one
+// interval and is extended while the job runs, so a run outlasting its interval is not started twice.
+// Without Redis the job runs anyway; jobs must stay safe to run concurrently.
+func acquireLock(ctx context.Context, job Job) (func(), bool) {
+	if config.RedisClient == nil {
+		return func() {}, true
+	}
+
+	key := fmt.Sprintf("job_lock:%s", job.Name)
+	owner := uuid.NewString()
+	ok, err := config.RedisClient.SetNX(ctx, key, owner, job.Interval).Result()
+	if err != nil {
+		log.Printf("Could not take lock for job %s, running without it: %v", job.Name, err)
+		return func() {}, true
+	}
+	if !ok {
+		return nil, false
+	}
+
+	done := make(chan struct{})
+	go keepLock(key, owner, job, done)
+	return func() {
+		close(done)
+		unlockScript.Run(context.Background(), config.RedisClient, []string{key}, owner)
+	}, true
+}
+
+// keepLock extends a job's lock every third of its interval until done is closed
+func keepLock(key, owner string, job Job, done <-chan struct{}) {
+	ticker := time.NewTicker(job.Interval / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		renewed, err := renewScript.Run(context.Background(), config.RedisClient, []string{key}, owner, job.Interval.Milliseconds()).Int()
+		if err != nil {
+			log.Printf("Could not extend the lock of job %s: %v", job.Name, err)
+			continue
+		}
+		if renewed == 0 {
+			log.Printf("Job %s lost its lock while running", job.Name)
+			return
+		}
+	}
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for key, n := range counts {
+		copied[key] = n
+	}
+	return copied
+}
+
+func hasWork(counts map[string]int) bool {
+	for _, n := range counts {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package workers
+
+import (
+	"context"
+	"my-app/config"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// A run that outlasts its interval keeps the job's lock, so no other process starts the job meanwhile
+func TestJobLockOutlastsInterval(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" && os.Getenv("CI") != "" {
+		t.Fatal("TEST_REDIS_ADDR is not set")
+	}
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+	previous := config.RedisClient
+	config.RedisClient = redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() {
+		config.RedisClient.Close()
+		config.RedisClient = previous
+	})
+
+	job := Job{Name: "test_lock_" + time.Now().Format("150405.000000"), Interval: 300 * time.Millisecond}
+	unlock, ok := acquireLock(context.Background(), job)
+	if !ok {
+		t.Fatal("could not take a free lock")
+	}
+
+	time.Sleep(3 * job.Interval)
+	if _, ok := acquireLock(context.Background(), job); ok {
+		t.Fatal("another process took the lock of a job still running")
+	}
+
+	unlock()
+	second, ok := acquireLock(context.Background(), job)
+	if !ok {
+		t.Fatal("the lock was not released when the run finished")
+	}
+	second()
+}